@@ -2,17 +2,28 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"github.com/MeneDev/dockmoor/dockfmt"
+	_ "github.com/MeneDev/dockmoor/dockfmt/circleci"
+	_ "github.com/MeneDev/dockmoor/dockfmt/compose"
 	_ "github.com/MeneDev/dockmoor/dockfmt/dockerfile"
+	_ "github.com/MeneDev/dockmoor/dockfmt/drone"
+	_ "github.com/MeneDev/dockmoor/dockfmt/gitlabci"
+	_ "github.com/MeneDev/dockmoor/dockfmt/travis"
 	"github.com/MeneDev/dockmoor/dockref"
 	"github.com/jessevdk/go-flags"
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"io"
 	"os"
 	"path"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 type ExitCodeCommander interface {
@@ -24,7 +35,28 @@ type mainOptions struct {
 	LogLevel    string `required:"no" short:"l" long:"log-level" description:"Sets the log-level" choice:"NONE" choice:"ERROR" choice:"WARN" choice:"INFO" choice:"DEBUG" default:"WARN"`
 	ShowVersion bool   `required:"no" long:"version" description:"Show version and exit"`
 
-	Resolver string `required:"no" short:"r" long:"resolver" description:"Strategy to resolve image references" choice:"dockerd" default:"dockerd"`
+	Resolver   string `required:"no" short:"r" long:"resolver" description:"Strategy to resolve image references: dockerd, registry, lock, oci:<dir> for an OCI image layout, docker-archive:<tar>[,<tar>...] for docker save archives or config:<file> to route references to different resolvers" default:"dockerd"`
+	Jobs       int    `required:"no" short:"j" long:"jobs" description:"Maximum number of image references resolved concurrently" default:"8"`
+	LockFile   string `required:"no" long:"lock-file" description:"Lock file written by the lock command and read by the lock resolver" default:"dockmoor.lock"`
+	TagSchemes string `required:"no" long:"tag-schemes" description:"File with regular expressions that split the tags of selected images into version and variant, e.g. 17.0.8_7-jdk-jammy"`
+	Policy     string `required:"no" long:"policy" description:"Update policy file with per-image rules that restrict the versions chosen by the update command and --outdated"`
+	Platform   string `required:"no" long:"platform" description:"Pin the manifest of this platform, e.g. linux/arm64, instead of the manifest list. The --platform flag of a Dockerfile's FROM takes precedence"`
+
+	Cache struct {
+		NoCache      bool          `required:"no" long:"no-cache" description:"Don't use the resolution cache"`
+		RefreshCache bool          `required:"no" long:"refresh-cache" description:"Ignore cached resolution results, but update the cache"`
+		CacheDir     string        `required:"no" long:"cache-dir" description:"Directory of the resolution cache, defaults to dockmoor in the user cache directory"`
+		CacheTTL     time.Duration `required:"no" long:"cache-ttl" description:"Maximum age of cached resolution results, 0 to never expire" default:"24h"`
+	} `group:"Cache Options" description:"Control the persistent cache of image references resolved by registries"`
+
+	Network struct {
+		Timeout        time.Duration `required:"no" long:"timeout" description:"Maximum duration of all resolutions of a command, 0 for no limit" default:"0"`
+		RequestTimeout time.Duration `required:"no" long:"request-timeout" description:"Maximum duration of a single request to a registry, 0 for no limit" default:"30s"`
+		Retries        int           `required:"no" long:"retries" description:"Number of retries of registry requests that failed temporarily or hit a rate limit" default:"3"`
+		CertsDir       string        `required:"no" long:"certs-dir" description:"Directory with CA and client certificates in subdirectories named by registry domain, like the certs.d of the docker daemon" default:"/etc/docker/certs.d"`
+		Insecure       []string      `required:"no" long:"insecure-registry" description:"Registry domain with a self-signed or otherwise unverifiable certificate, can be repeated"`
+		PlainHTTP      []string      `required:"no" long:"plain-http-registry" description:"Registry domain contacted with http instead of https, can be repeated"`
+	} `group:"Network Options" description:"Control how registries are contacted"`
 
 	Help struct {
 		Help          bool `short:"h" long:"help" description:"Show help and exit"`
@@ -39,12 +71,21 @@ type mainOptions struct {
 	formatProvider dockfmt.FormatProvider
 	stdout         io.Writer
 	stdin          io.ReadCloser
+	// noPreciseTags is set by commands that keep the tag, a more precise tag is of no use to them
+	noPreciseTags bool
 
 	resolverFactory func() func() dockref.Resolver
 }
 
+const (
+	ociResolverPrefix     = "oci:"
+	archiveResolverPrefix = "docker-archive:"
+	configResolverPrefix  = "config:"
+)
+
 var osStdout io.Writer = os.Stdout
 var osStdin io.ReadCloser = os.Stdin
+var osUserCacheDir = os.UserCacheDir
 
 func mainOptionsNew() *mainOptions {
 	mainOptions := &mainOptions{}
@@ -129,6 +170,14 @@ func main() {
 		log.Errorf("Could not add pin command: %s", err)
 	}
 
+	if _, err := addLockCommand(mainOptions, AddCommand); err != nil {
+		log.Errorf("Could not add lock command: %s", err)
+	}
+
+	if _, err := addUpdateCommand(mainOptions, AddCommand); err != nil {
+		log.Errorf("Could not add update command: %s", err)
+	}
+
 	exitCode := doMain(mainOptions)
 	osExit(exitCode)
 }
@@ -203,6 +252,44 @@ func CommandFromArgs(mainOptions *mainOptions, args []string) (theCommand flags.
 		return
 	}
 
+	if mainOptions.Jobs < 1 {
+		log.Errorf("Error in parameters: --jobs must be at least 1")
+		theCommand = nil
+		exitCode = ExitInvalidParams
+		return
+	}
+
+	if mainOptions.Platform != "" {
+		if _, platformErr := dockref.ParsePlatform(mainOptions.Platform); platformErr != nil {
+			log.Errorf("Error in parameters: %s", platformErr)
+			theCommand = nil
+			exitCode = ExitInvalidParams
+			return
+		}
+	}
+
+	// go-flags choices cannot express oci:<dir> and friends
+	if resolverErr := mainOptions.verifyResolver(); resolverErr != nil {
+		log.Errorf("Error in parameters: %s", resolverErr)
+		theCommand = nil
+		exitCode = ExitInvalidParams
+		return
+	}
+
+	if _, schemesErr := mainOptions.tagSchemes(); schemesErr != nil {
+		log.Errorf("Error in parameters: %s", schemesErr)
+		theCommand = nil
+		exitCode = ExitInvalidParams
+		return
+	}
+
+	if _, policyErr := mainOptions.updatePolicies(); policyErr != nil {
+		log.Errorf("Error in parameters: %s", policyErr)
+		theCommand = nil
+		exitCode = ExitInvalidParams
+		return
+	}
+
 	level := logrus.WarnLevel
 	log.SetLevel(level)
 	if mainOptions.LogLevel == "NONE" {
@@ -250,11 +337,177 @@ func fmtFprintf(log *logrus.Logger, w io.Writer, format string, a ...interface{}
 
 func (options *mainOptions) DefaultResolverFactory() func() dockref.Resolver {
 	return func() dockref.Resolver {
-		switch options.Resolver {
-		case "dockerd":
-			return dockref.DockerDaemonResolverNew()
+		return options.resolverNamed(options.Resolver)
+	}
+}
+
+func (options *mainOptions) resolverNamed(name string) dockref.Resolver {
+	switch name {
+	case "dockerd":
+		// the daemon is local and its images change with every pull, cached results would pin stale digests
+		return dockref.DockerDaemonResolverNew()
+	case "registry":
+		registryOptions, err := options.registryOptions()
+		if err != nil {
+			return failingResolver{err: err}
+		}
+		registry, err := dockref.RegistryResolverWithOptionsNew(registryOptions)
+		if err != nil {
+			return failingResolver{err: err}
 		}
+		return options.withCache(options.registryCacheNamespace(registryOptions), registry)
+	case "lock":
+		// the lock file is local and authoritative, caching would only hide changes to it
+		return dockref.LockResolverNew(options.LockFile)
+	}
+
+	if dir, ok := resolverArgument(name, ociResolverPrefix); ok {
+		return dockref.OCILayoutResolverNew(dir)
+	}
+
+	if files, ok := resolverArgument(name, archiveResolverPrefix); ok {
+		return dockref.DockerArchiveResolverNew(strings.Split(files, ",")...)
+	}
 
+	if file, ok := resolverArgument(name, configResolverPrefix); ok {
+		resolver, err := options.configuredResolver(file)
+		if err != nil {
+			options.Log().WithField("error", err.Error()).Errorf("Invalid resolver configuration %s", file)
+			return failingResolver{err: err}
+		}
+		return resolver
+	}
+
+	return nil
+}
+
+// resolverArgument returns the argument of resolvers like oci:<dir>
+func resolverArgument(name string, prefix string) (string, bool) {
+	if !strings.HasPrefix(name, prefix) {
+		return "", false
+	}
+
+	argument := strings.TrimPrefix(name, prefix)
+	return argument, argument != ""
+}
+
+func (options *mainOptions) verifyResolver() error {
+	if file, ok := resolverArgument(options.Resolver, configResolverPrefix); ok {
+		_, err := options.configuredResolver(file)
+		return err
+	}
+
+	if options.Resolver == "registry" {
+		registryOptions, err := options.registryOptions()
+		if err != nil {
+			return err
+		}
+		_, err = dockref.RegistryResolverWithOptionsNew(registryOptions)
+		return err
+	}
+
+	return verifyResolverName(options.Resolver)
+}
+
+func verifyResolverName(name string) error {
+	switch name {
+	case "dockerd", "registry", "lock":
 		return nil
 	}
+
+	for _, prefix := range []string{ociResolverPrefix, archiveResolverPrefix} {
+		if _, ok := resolverArgument(name, prefix); ok {
+			return nil
+		}
+	}
+
+	return errors.Errorf("Invalid value `%s' for option `-r, --resolver'. Allowed values are: dockerd, registry, lock, oci:<dir>, docker-archive:<tar>[,<tar>...] or config:<file>", name)
+}
+
+func (options *mainOptions) registryOptions() (dockref.RegistryOptions, error) {
+	registryOptions := dockref.DefaultRegistryOptions()
+	registryOptions.RequestTimeout = options.Network.RequestTimeout
+	registryOptions.Retries = options.Network.Retries
+	registryOptions.CertsDir = options.Network.CertsDir
+
+	registryOptions.Registries = make(map[string]dockref.RegistryConfig)
+	for _, domain := range options.Network.Insecure {
+		config := registryOptions.Registries[domain]
+		config.Insecure = true
+		registryOptions.Registries[domain] = config
+	}
+	for _, domain := range options.Network.PlainHTTP {
+		config := registryOptions.Registries[domain]
+		config.PlainHTTP = true
+		registryOptions.Registries[domain] = config
+	}
+
+	registryOptions.NoPreciseTags = options.noPreciseTags
+	registryOptions.Log = options.Log()
+
+	schemes, err := options.tagSchemes()
+	registryOptions.TagSchemes = schemes
+
+	return registryOptions, err
+}
+
+// resolveContext limits the duration of all resolutions of a command
+func (options *mainOptions) resolveContext() (context.Context, context.CancelFunc) {
+	if options.Network.Timeout > 0 {
+		return context.WithTimeout(context.Background(), options.Network.Timeout)
+	}
+	return context.WithCancel(context.Background())
+}
+
+// registryCacheNamespace separates the results of different registry options: results without more precise tags would hide
+// these tags from other commands, results of other mirrors, registries or tag schemes would be stale
+func (options *mainOptions) registryCacheNamespace(registryOptions dockref.RegistryOptions) string {
+	var tagSchemes []byte
+	if options.TagSchemes != "" {
+		var err error
+		// unreadable tag schemes fail the resolution anyway
+		tagSchemes, err = readTagSchemesFile(options.TagSchemes)
+		deliberatelyUnhandled(err)
+	}
+
+	key, err := json.Marshal(struct {
+		Mirrors       map[string]string
+		Registries    map[string]dockref.RegistryConfig
+		CertsDir      string
+		TagSchemes    []byte
+		NoPreciseTags bool
+	}{
+		Mirrors:       registryOptions.Mirrors,
+		Registries:    registryOptions.Registries,
+		CertsDir:      registryOptions.CertsDir,
+		TagSchemes:    tagSchemes,
+		NoPreciseTags: registryOptions.NoPreciseTags,
+	})
+	deliberatelyUnhandled(err)
+
+	sum := sha256.Sum256(key)
+	return "registry-" + hex.EncodeToString(sum[:8])
+}
+
+func (options *mainOptions) withCache(namespace string, resolver dockref.Resolver) dockref.Resolver {
+	cache := options.Cache
+	if cache.NoCache {
+		return resolver
+	}
+
+	dir := cache.CacheDir
+	if dir == "" {
+		userCacheDir, err := osUserCacheDir()
+		if err != nil {
+			options.Log().WithField("error", err.Error()).Warn("Cannot determine cache directory, resolution results are not cached")
+			return resolver
+		}
+		dir = filepath.Join(userCacheDir, "dockmoor")
+	}
+
+	return dockref.CachingResolverNew(resolver, namespace, dockref.CacheOptions{
+		Dir:     dir,
+		TTL:     cache.CacheTTL,
+		Refresh: cache.RefreshCache,
+	})
 }
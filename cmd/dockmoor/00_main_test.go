@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"github.com/MeneDev/dockmoor/dockfmt"
 	"github.com/MeneDev/dockmoor/dockref"
 	"github.com/jessevdk/go-flags"
 	"github.com/pkg/errors"
@@ -10,8 +11,10 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 var NotADockerfile = "notDocker"
@@ -166,6 +169,7 @@ func TestMainReportsAddingListCommandErrors(t *testing.T) {
 	assert.Contains(t, stdoutBuf.String(), "Could not add list command")
 	assert.Contains(t, stdoutBuf.String(), "Could not add contains command")
 	assert.Contains(t, stdoutBuf.String(), "Could not add pin command")
+	assert.Contains(t, stdoutBuf.String(), "Could not add lock command")
 }
 
 func TestInvalidFlagIsReportedByName(t *testing.T) {
@@ -195,3 +199,194 @@ func TestUsesDockerdSolver(t *testing.T) {
 	assert.Equal(t, po.mainOptions().Resolver, "dockerd")
 	assert.IsType(t, dockref.DockerDaemonResolverNew(), po.mainOptions().resolverFactory()())
 }
+
+func TestUsesRegistrySolver(t *testing.T) {
+	cmd, _, _, _ := testMain([]string{"--resolver", "registry", "--no-cache", "pin", "fileNameIn"}, addPinCommand)
+
+	po, _ := cmd.(*pinOptions)
+	assert.Equal(t, po.mainOptions().Resolver, "registry")
+	assert.IsType(t, dockref.RegistryResolverNew(), po.mainOptions().resolverFactory()())
+}
+
+func TestUsesLockSolverWithoutCache(t *testing.T) {
+	cmd, _, _, _ := testMain([]string{"--resolver", "lock", "--lock-file", "my.lock", "pin", "fileNameIn"}, addPinCommand)
+
+	po, _ := cmd.(*pinOptions)
+	assert.Equal(t, "my.lock", po.mainOptions().LockFile)
+	assert.IsType(t, dockref.LockResolverNew(""), po.mainOptions().resolverFactory()())
+}
+
+func TestNetworkOptions(t *testing.T) {
+	cmd, _, exitCode, _ := testMain([]string{"--timeout", "2m", "--request-timeout", "5s", "--retries", "1", "pin", "fileNameIn"}, addPinCommand)
+
+	po, _ := cmd.(*pinOptions)
+	assert.Equal(t, ExitSuccess, exitCode)
+
+	registryOptions, err := po.mainOptions().registryOptions()
+	assert.Nil(t, err)
+	assert.Equal(t, 5*time.Second, registryOptions.RequestTimeout)
+	assert.Equal(t, 1, registryOptions.Retries)
+
+	ctx, cancel := po.mainOptions().resolveContext()
+	defer cancel()
+	deadline, ok := ctx.Deadline()
+	assert.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(2*time.Minute), deadline, time.Minute)
+}
+
+func TestRegistryOptions(t *testing.T) {
+	cmd, _, exitCode, _ := testMain([]string{"--certs-dir", "certs.d", "--insecure-registry", "registry.corp", "--plain-http-registry", "registry.corp", "--plain-http-registry", "localhost:5000", "pin", "fileNameIn"}, addPinCommand)
+
+	po, _ := cmd.(*pinOptions)
+	assert.Equal(t, ExitSuccess, exitCode)
+
+	registryOptions, err := po.mainOptions().registryOptions()
+	assert.Nil(t, err)
+	assert.Equal(t, "certs.d", registryOptions.CertsDir)
+	assert.Equal(t, map[string]dockref.RegistryConfig{
+		"registry.corp":  {Insecure: true, PlainHTTP: true},
+		"localhost:5000": {PlainHTTP: true},
+	}, registryOptions.Registries)
+}
+
+func TestInvalidRegistryOptionsAreReported(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dockmoor-certs")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	assert.Nil(t, os.Mkdir(filepath.Join(dir, "registry.corp"), 0755))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "registry.corp", "client.cert"), []byte{}, 0644))
+
+	_, _, exitCode, buf := testMain([]string{"--resolver", "registry", "--certs-dir", dir, "--insecure-registry", "registry.corp", "pin", "fileNameIn"}, addPinCommand)
+
+	assert.Equal(t, ExitInvalidParams, exitCode)
+	assert.Contains(t, buf.String(), "missing key")
+}
+
+func TestNoTimeoutByDefault(t *testing.T) {
+	ctx, cancel := mainOptionsTestNew().mainOptions.resolveContext()
+	defer cancel()
+
+	_, ok := ctx.Deadline()
+	assert.False(t, ok)
+}
+
+func TestUsesOCILayoutSolver(t *testing.T) {
+	cmd, _, exitCode, _ := testMain([]string{"--resolver", "oci:/var/lib/images", "pin", "fileNameIn"}, addPinCommand)
+
+	po, _ := cmd.(*pinOptions)
+	assert.Equal(t, ExitSuccess, exitCode)
+	assert.IsType(t, dockref.OCILayoutResolverNew(""), po.mainOptions().resolverFactory()())
+}
+
+func TestUsesDockerArchiveSolver(t *testing.T) {
+	cmd, _, exitCode, _ := testMain([]string{"--resolver", "docker-archive:a.tar,b.tar.gz", "pin", "fileNameIn"}, addPinCommand)
+
+	po, _ := cmd.(*pinOptions)
+	assert.Equal(t, ExitSuccess, exitCode)
+	assert.IsType(t, dockref.DockerArchiveResolverNew(), po.mainOptions().resolverFactory()())
+}
+
+func TestUnknownSolverIsReported(t *testing.T) {
+	for _, resolver := range []string{"oci:", "docker-archive:", "config:", "unknown"} {
+		_, _, exitCode, buf := testMain([]string{"--resolver", resolver, "pin", "fileNameIn"}, addPinCommand)
+
+		assert.Equal(t, ExitInvalidParams, exitCode)
+		assert.Contains(t, buf.String(), "Allowed values are: dockerd, registry, lock, oci:<dir>, docker-archive:<tar>[,<tar>...] or config:<file>")
+	}
+}
+
+func TestCachesResolverByDefault(t *testing.T) {
+	cacheDir, err := ioutil.TempDir("", "dockmoor-cache")
+	assert.Nil(t, err)
+	defer os.RemoveAll(cacheDir)
+
+	cmd, _, _, _ := testMain([]string{"--resolver", "registry", "--cache-dir", cacheDir, "pin", "fileNameIn"}, addPinCommand)
+
+	po, _ := cmd.(*pinOptions)
+	assert.Equal(t, 24*time.Hour, po.mainOptions().Cache.CacheTTL)
+	assert.IsType(t, dockref.CachingResolverNew(nil, "", dockref.CacheOptions{}), po.mainOptions().resolverFactory()())
+}
+
+func TestRegistryCacheNamespaceDependsOnResolverOptions(t *testing.T) {
+	schemes := tagSchemesFile(temurinSchemes)
+	defer os.Remove(schemes)
+
+	options := mainOptionsNew()
+	var mirrors map[string]string
+	namespace := func() string {
+		registryOptions, err := options.registryOptions()
+		assert.Nil(t, err)
+		registryOptions.Mirrors = mirrors
+		return options.registryCacheNamespace(registryOptions)
+	}
+
+	namespaces := []string{namespace()}
+	assert.Equal(t, namespaces[0], namespace(), "the same options share the cache")
+
+	for i, change := range []func(){
+		func() { options.noPreciseTags = true },
+		func() { options.TagSchemes = schemes },
+		func() { options.Network.Insecure = []string{"registry.corp"} },
+		func() { options.Network.CertsDir = "/certs" },
+		func() { mirrors = map[string]string{"docker.io": "mirror.corp"} },
+	} {
+		change()
+		ns := namespace()
+		assert.NotContains(t, namespaces, ns, "change %d", i)
+		namespaces = append(namespaces, ns)
+	}
+}
+
+func TestCacheWithoutCacheDirectory(t *testing.T) {
+	org := osUserCacheDir
+	defer func() {
+		osUserCacheDir = org
+	}()
+	osUserCacheDir = func() (string, error) {
+		return "", errors.Errorf("no cache for you")
+	}
+
+	cmd, _, _, buf := testMain([]string{"--resolver", "registry", "pin", "fileNameIn"}, addPinCommand)
+
+	po, _ := cmd.(*pinOptions)
+	assert.IsType(t, dockref.RegistryResolverNew(), po.mainOptions().resolverFactory()())
+	assert.Contains(t, buf.String(), "Cannot determine cache directory")
+}
+
+func TestRegisteredFormatsIdentifyExamples(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+
+	examples := []struct {
+		filename string
+		content  string
+		format   string
+	}{
+		{"Dockerfile", "FROM nginx\n", "Dockerfile"},
+		{"docker-compose.yml", "version: \"3.8\"\nservices:\n  web:\n    image: nginx\n", "docker-compose"},
+		{".gitlab-ci.yml", "image: nginx\n", "GitLab CI"},
+		{"ci.yml", "build:\n  image: nginx\n  script: make\n", "GitLab CI"},
+		{".circleci/config.yml", "version: 2.1\njobs:\n  run:\n    docker: [{image: nginx}]\n", "CircleCI"},
+		{"config.yml", "version: 2.1\njobs:\n  build:\n    docker: [{image: nginx}]\n", "CircleCI"},
+		{".travis.yml", "language: go\nversion: ~> 1.0\njobs:\n  include:\n    - image: nginx\n", "Travis CI"},
+		{".drone.yml", "kind: pipeline\nsteps:\n  - name: run\n    image: nginx\n---\nkind: pipeline\nsteps: []\n", "Drone"},
+		{".woodpecker.yml", "steps:\n  run:\n    image: nginx\nservices:\n  db:\n    image: postgres\n", "Woodpecker"},
+	}
+
+	for _, example := range examples {
+		format, err := dockfmt.IdentifyFormat(logger, dockfmt.DefaultFormatProvider(), strings.NewReader(example.content), example.filename)
+		if assert.NotNil(t, format, "%s: %v", example.filename, err) {
+			assert.Equal(t, example.format, format.Name(), example.filename)
+		}
+	}
+}
+
+func TestRegisteredFormatsDontIdentifyOtherYaml(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+
+	mkdocs := "site_name: docs\ntheme:\n  name: material\n  language: en\nlanguage: en\nnav:\n  - index.md\n"
+	format, err := dockfmt.IdentifyFormat(logger, dockfmt.DefaultFormatProvider(), strings.NewReader(mkdocs), "mkdocs.yml")
+	assert.Nil(t, format)
+	assert.IsType(t, dockfmt.UnknownFormatError{}, err)
+}
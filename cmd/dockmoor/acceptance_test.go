@@ -6,6 +6,7 @@ import (
 	"github.com/MeneDev/dockmoor/docktst/dockreftst"
 	"github.com/jessevdk/go-flags"
 	"github.com/mattn/go-shellwords"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"html/template"
 	"io/ioutil"
@@ -128,6 +129,48 @@ func TestContainsUnpinnedNoMatch(t *testing.T) {
 	assert.Equal(t, ExitNotFound, exitCode)
 }
 
+func TestContainsOutdatedMatches(t *testing.T) {
+	df1 := dockerfile(`FROM nginx:1.15`)
+	defer os.Remove(df1)
+
+	os.Args = []string{"exe", "contains", "--outdated", df1}
+	mainOptions := mainOptionsACNew(addContainsCommand)
+	repo := mainOptions.resolverFactory()().(*dockreftst.MockResolver)
+	repo.OnListTags(dockref.MustParse("nginx:1.15")).Return([]string{"1.15", "1.15.6", "1.16"}, nil)
+
+	exitCode := doMain(mainOptions)
+
+	assert.Equal(t, ExitSuccess, exitCode)
+}
+
+func TestContainsOutdatedNoMatch(t *testing.T) {
+	df1 := dockerfile(`FROM nginx:1.15-alpine`)
+	defer os.Remove(df1)
+
+	os.Args = []string{"exe", "contains", "--outdated", df1}
+	mainOptions := mainOptionsACNew(addContainsCommand)
+	repo := mainOptions.resolverFactory()().(*dockreftst.MockResolver)
+	repo.OnListTags(dockref.MustParse("nginx:1.15-alpine")).Return([]string{"1.15-alpine", "1.15.6", "1.16"}, nil)
+
+	exitCode := doMain(mainOptions)
+
+	assert.Equal(t, ExitNotFound, exitCode)
+}
+
+func TestContainsOutdatedFailsWhenTagsCannotBeListed(t *testing.T) {
+	df1 := dockerfile(`FROM nginx:1.15`)
+	defer os.Remove(df1)
+
+	os.Args = []string{"exe", "contains", "--outdated", df1}
+	mainOptions := mainOptionsACNew(addContainsCommand)
+	repo := mainOptions.resolverFactory()().(*dockreftst.MockResolver)
+	repo.OnListTags(dockref.MustParse("nginx:1.15")).Return([]string(nil), errors.New("registry unavailable"))
+
+	exitCode := doMain(mainOptions)
+
+	assert.Equal(t, ExitUnknownError, exitCode)
+}
+
 func TestContainsInvalidOptions(t *testing.T) {
 	df1 := dockerfile(`FROM nginx`)
 	defer os.Remove(df1)
@@ -297,3 +340,215 @@ func shell(t *testing.T, argsLine string, values interface{}) (stdout string, ex
 	assert.True(t, exitCodeSet, "Expected exitCode to be set (no call to osExit)")
 	return
 }
+
+func TestListForComposeFile(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "dockmoor")
+	defer os.RemoveAll(dir)
+
+	tmpfn := filepath.Join(dir, "docker-compose.yml")
+	compose := "services:\n  web:\n    image: nginx:latest\n  db:\n    image: postgres:13\n"
+
+	if err := ioutil.WriteFile(tmpfn, []byte(compose), 0666); err != nil {
+		log.Fatal(err)
+	}
+
+	stdout, code := shell(t, `dockmoor list {{.Compose}}`, struct {
+		Compose string
+	}{tmpfn})
+
+	assert.Equal(t, "nginx:latest\npostgres:13\n", stdout)
+	assert.Equal(t, ExitSuccess, code, "Exits with code 0")
+}
+
+func TestListForOwnCircleCiConfig(t *testing.T) {
+	stdout, code := shell(t, `dockmoor list ../../.circleci/config.yml`, nil)
+
+	assert.Contains(t, stdout, "Skipping image $CI_PROJECT_PATH-builder:latest in line ", "the images use variables")
+	assert.Equal(t, ExitNotFound, code, "the format is known")
+}
+
+func TestListForOwnTravisConfig(t *testing.T) {
+	stdout, code := shell(t, `dockmoor list ../../.travis.yml`, nil)
+
+	assert.Equal(t, "", stdout, "no job has an image")
+	assert.Equal(t, ExitNotFound, code, "the format is known")
+}
+
+func TestPinComposeFile(t *testing.T) {
+	compose := dockerfile("services:\n  web:\n    # the frontend\n    image: nginx:1.15 # pinned by dockmoor\n    ports: [\"80:80\"]\n")
+	defer os.Remove(compose)
+
+	os.Args = []string{"exe", "pin", compose}
+	mainOptions := mainOptionsACNew(addPinCommand)
+
+	repo := mainOptions.resolverFactory()().(*dockreftst.MockResolver)
+	repo.OnResolve(dockref.MustParse("nginx:1.15")).Return([]dockref.Reference{
+		dockref.MustParse("nginx:1.15.6@sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf"),
+	}, nil)
+
+	exitCode := doMain(mainOptions)
+
+	assert.Equal(t, ExitSuccess, exitCode)
+
+	fileBytes, e := ioutil.ReadFile(compose)
+	assert.Nil(t, e)
+	assert.Equal(t, "services:\n  web:\n    # the frontend\n    image: nginx:1.15.6@sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf # pinned by dockmoor\n    ports: [\"80:80\"]\n", string(fileBytes))
+}
+
+func TestPinComposeFileWithEnvFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "compose")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	compose := filepath.Join(dir, "compose.yml")
+	envFile := filepath.Join(dir, ".env")
+	assert.Nil(t, ioutil.WriteFile(compose, []byte("services:\n  web:\n    image: nginx:${NGINX_TAG}\n  db:\n    image: postgres:${DB_TAG:-13}\n"), 0600))
+	assert.Nil(t, ioutil.WriteFile(envFile, []byte("NGINX_TAG=1.15\n"), 0600))
+
+	os.Args = []string{"exe", "pin", compose}
+	mainOptions := mainOptionsACNew(addPinCommand)
+
+	repo := mainOptions.resolverFactory()().(*dockreftst.MockResolver)
+	repo.OnResolve(dockref.MustParse("nginx:1.15")).Return([]dockref.Reference{
+		dockref.MustParse("nginx:1.15.6@sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf"),
+	}, nil)
+	repo.OnResolve(dockref.MustParse("postgres:13")).Return([]dockref.Reference{
+		dockref.MustParse("postgres:13.2@sha256:3c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf"),
+	}, nil)
+
+	exitCode := doMain(mainOptions)
+
+	assert.Equal(t, ExitSuccess, exitCode)
+
+	fileBytes, e := ioutil.ReadFile(compose)
+	assert.Nil(t, e)
+	assert.Equal(t, "services:\n  web:\n    image: nginx:${NGINX_TAG}\n  db:\n    image: postgres:${DB_TAG:-13.2@sha256:3c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf}\n", string(fileBytes))
+
+	envBytes, e := ioutil.ReadFile(envFile)
+	assert.Nil(t, e)
+	assert.Equal(t, "NGINX_TAG=1.15.6@sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf\n", string(envBytes))
+}
+
+func TestPinComposeFileWithEnvFileFailsWithOutput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "compose")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	compose := filepath.Join(dir, "compose.yml")
+	envFile := filepath.Join(dir, ".env")
+	output := filepath.Join(dir, "pinned.yml")
+	assert.Nil(t, ioutil.WriteFile(compose, []byte("services:\n  web:\n    image: nginx:${NGINX_TAG}\n"), 0600))
+	assert.Nil(t, ioutil.WriteFile(envFile, []byte("NGINX_TAG=1.15\n"), 0600))
+
+	os.Args = []string{"exe", "pin", "--output", output, compose}
+	mainOptions := mainOptionsACNew(addPinCommand)
+
+	repo := mainOptions.resolverFactory()().(*dockreftst.MockResolver)
+	repo.OnResolve(dockref.MustParse("nginx:1.15")).Return([]dockref.Reference{
+		dockref.MustParse("nginx:1.15.6@sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf"),
+	}, nil)
+
+	exitCode := doMain(mainOptions)
+
+	assert.Equal(t, ExitInvalidFormat, exitCode)
+
+	envBytes, e := ioutil.ReadFile(envFile)
+	assert.Nil(t, e)
+	assert.Equal(t, "NGINX_TAG=1.15\n", string(envBytes), "only the output is written")
+
+	_, e = os.Stat(output)
+	assert.True(t, os.IsNotExist(e), "nothing is written when the .env file can't be written")
+}
+
+func TestPinGitlabCiFileWithInclude(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitlab")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	gitlabCi := filepath.Join(dir, ".gitlab-ci.yml")
+	templates := filepath.Join(dir, "templates.yml")
+	assert.Nil(t, ioutil.WriteFile(gitlabCi, []byte("include: /templates.yml\n\nbuild:\n  image:\n    name: nginx:1.15\n  script: make\n"), 0600))
+	assert.Nil(t, ioutil.WriteFile(templates, []byte(".test:\n  services: [postgres:13]\n"), 0600))
+
+	os.Args = []string{"exe", "pin", gitlabCi}
+	mainOptions := mainOptionsACNew(addPinCommand)
+
+	repo := mainOptions.resolverFactory()().(*dockreftst.MockResolver)
+	repo.OnResolve(dockref.MustParse("nginx:1.15")).Return([]dockref.Reference{
+		dockref.MustParse("nginx:1.15.6@sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf"),
+	}, nil)
+	repo.OnResolve(dockref.MustParse("postgres:13")).Return([]dockref.Reference{
+		dockref.MustParse("postgres:13.2@sha256:3c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf"),
+	}, nil)
+
+	exitCode := doMain(mainOptions)
+
+	assert.Equal(t, ExitSuccess, exitCode)
+
+	fileBytes, e := ioutil.ReadFile(gitlabCi)
+	assert.Nil(t, e)
+	assert.Equal(t, "include: /templates.yml\n\nbuild:\n  image:\n    name: nginx:1.15.6@sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf\n  script: make\n", string(fileBytes))
+
+	templateBytes, e := ioutil.ReadFile(templates)
+	assert.Nil(t, e)
+	assert.Equal(t, ".test:\n  services: [postgres:13.2@sha256:3c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf]\n", string(templateBytes))
+}
+
+func TestPinGitlabCiFileWithIncludeFailsWithOutput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitlab")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	gitlabCi := filepath.Join(dir, ".gitlab-ci.yml")
+	templates := filepath.Join(dir, "templates.yml")
+	output := filepath.Join(dir, "pinned.yml")
+	assert.Nil(t, ioutil.WriteFile(gitlabCi, []byte("include: /templates.yml\n\nbuild:\n  image:\n    name: nginx:1.15\n  script: make\n"), 0600))
+	assert.Nil(t, ioutil.WriteFile(templates, []byte(".test:\n  services: [postgres:13]\n"), 0600))
+
+	os.Args = []string{"exe", "pin", "--output", output, gitlabCi}
+	mainOptions := mainOptionsACNew(addPinCommand)
+
+	repo := mainOptions.resolverFactory()().(*dockreftst.MockResolver)
+	repo.OnResolve(dockref.MustParse("nginx:1.15")).Return([]dockref.Reference{
+		dockref.MustParse("nginx:1.15.6@sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf"),
+	}, nil)
+	repo.OnResolve(dockref.MustParse("postgres:13")).Return([]dockref.Reference{
+		dockref.MustParse("postgres:13.2@sha256:3c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf"),
+	}, nil)
+
+	exitCode := doMain(mainOptions)
+
+	assert.Equal(t, ExitInvalidFormat, exitCode)
+
+	templateBytes, e := ioutil.ReadFile(templates)
+	assert.Nil(t, e)
+	assert.Equal(t, ".test:\n  services: [postgres:13]\n", string(templateBytes), "only the output is written")
+
+	_, e = os.Stat(output)
+	assert.True(t, os.IsNotExist(e), "nothing is written when the include can't be written")
+}
+
+func TestPinGitlabCiFileWithPlatformWithoutOs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gitlab")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	gitlabCi := filepath.Join(dir, ".gitlab-ci.yml")
+	assert.Nil(t, ioutil.WriteFile(gitlabCi, []byte("build:\n  image:\n    name: nginx:1.15\n    docker:\n      platform: arm64/v8\n  script: make\n"), 0600))
+
+	os.Args = []string{"exe", "pin", gitlabCi}
+	mainOptions := mainOptionsACNew(addPinCommand)
+
+	repo := mainOptions.resolverFactory()().(*dockreftst.MockResolver)
+	repo.OnResolvePlatform(dockref.MustParse("nginx:1.15"), dockref.MustParsePlatform("linux/arm64/v8")).Return([]dockref.Reference{
+		dockref.MustParse("nginx:1.15.6@sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf"),
+	}, nil)
+
+	exitCode := doMain(mainOptions)
+
+	assert.Equal(t, ExitSuccess, exitCode)
+
+	fileBytes, e := ioutil.ReadFile(gitlabCi)
+	assert.Nil(t, e)
+	assert.Equal(t, "build:\n  image:\n    name: nginx:1.15.6@sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf\n    docker:\n      platform: arm64/v8\n  script: make\n", string(fileBytes))
+}
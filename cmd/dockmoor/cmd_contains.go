@@ -45,7 +45,10 @@ func (co *containsOptions) ExecuteWithExitCode(args []string) (exitCode ExitCode
 		return
 	}
 
-	predicate, err := mopts.getPredicate()
+	ctx, cancel := mopts.mainOptions().resolveContext()
+	defer cancel()
+
+	predicate, err := mopts.getPredicate(ctx)
 	if err != nil {
 		return ExitPredicateInvalid, err
 	}
@@ -57,6 +60,10 @@ func (co *containsOptions) ExecuteWithExitCode(args []string) (exitCode ExitCode
 		return errFormat
 	})
 
+	if err == nil {
+		err = dockproc.PredicateErr(predicate)
+	}
+
 	if exitCode, ok := exitCodeFromError(err); ok {
 		return exitCode, err
 	}
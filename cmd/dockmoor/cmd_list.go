@@ -46,7 +46,10 @@ func (lo *listOptions) ExecuteWithExitCode(args []string) (exitCode ExitCode, er
 		return
 	}
 
-	predicate, err := mopts.getPredicate()
+	ctx, cancel := mopts.mainOptions().resolveContext()
+	defer cancel()
+
+	predicate, err := mopts.getPredicate(ctx)
 	if err != nil {
 		return ExitPredicateInvalid, err
 	}
@@ -57,6 +60,10 @@ func (lo *listOptions) ExecuteWithExitCode(args []string) (exitCode ExitCode, er
 		})
 	})
 
+	if err == nil {
+		err = dockproc.PredicateErr(predicate)
+	}
+
 	if exitCode, ok := exitCodeFromError(err); ok {
 		return exitCode, err
 	}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"github.com/MeneDev/dockmoor/dockfmt"
 	"github.com/MeneDev/dockmoor/dockproc"
 	"github.com/MeneDev/dockmoor/dockref"
@@ -96,16 +97,32 @@ type FormatProcessorMock struct {
 	*mock.Mock
 
 	process func(imageNameProcessor dockfmt.ImageNameProcessor) error
+	line    int
+	file    string
 }
 
 func (d *FormatProcessorMock) WithWriter(writer io.Writer) dockfmt.FormatProcessor {
 	panic("implement me")
 }
 
+func (d *FormatProcessorMock) WithFileWriter(writer dockfmt.FileWriter) dockfmt.FormatProcessor {
+	return d
+}
+
+func (d *FormatProcessorMock) Files() []string {
+	return nil
+}
+
 func (d *FormatProcessorMock) Process(imageNameProcessor dockfmt.ImageNameProcessor) error {
 	return d.process(imageNameProcessor)
 }
 
+func (d *FormatProcessorMock) ProcessOccurrences(occurrenceProcessor dockfmt.OccurrenceProcessor) error {
+	return d.process(func(r dockref.Reference) (dockref.Reference, error) {
+		return occurrenceProcessor(r, dockfmt.Occurrence{Line: d.line, File: d.file})
+	})
+}
+
 func TestListCommandPrints(t *testing.T) {
 	test := listOptionsTestNew()
 	stdout := test.MainOptions().Stdout()
@@ -139,7 +156,7 @@ func TestListOptions_ExecuteWithExitCode(t *testing.T) {
 	lo := listOptionsTestNew()
 	lo.NamePredicates.Names = []string{"/a(b/"}
 
-	predicate, e := lo.getPredicate()
+	predicate, e := lo.getPredicate(context.Background())
 
 	assert.Error(t, e)
 	assert.Nil(t, predicate)
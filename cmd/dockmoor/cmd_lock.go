@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockproc"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/jessevdk/go-flags"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+)
+
+type lockOptions struct {
+	MatchingOptions
+
+	repoFactory func() dockref.Resolver
+	resolutions resolutions
+	schemes     dockref.TagSchemes
+	matches     bool
+
+	// noPlatforms is set when the resolver of the resolutions doesn't support platforms
+	noPlatforms bool
+}
+
+func (lo *lockOptions) Execute(args []string) error {
+	return errors.New("Use ExecuteWithExitCode instead")
+}
+
+func (lo *lockOptions) ExecuteWithExitCode(args []string) (exitCode ExitCode, err error) {
+	mopts := lo.MatchingOptions
+
+	exitCode, err = mopts.Verify()
+	if err != nil {
+		return
+	}
+
+	if lo.mainOptions().Resolver == "lock" {
+		err = errors.New("the lock command cannot use the lock resolver, choose a resolver that contacts a daemon or registry")
+		lo.Log().Error(err.Error())
+		return ExitInvalidParams, err
+	}
+
+	lo.schemes, err = lo.mainOptions().tagSchemes()
+	if err != nil {
+		return ExitInvalidParams, err
+	}
+
+	ctx, cancel := lo.mainOptions().resolveContext()
+	defer cancel()
+
+	predicate, err := mopts.getPredicate(ctx)
+	if err != nil {
+		return ExitPredicateInvalid, err
+	}
+
+	lockFile := lo.mainOptions().LockFile
+	lock, err := dockref.LockReadFile(lockFile)
+	if err != nil {
+		lo.Log().WithField("error", err.Error()).Errorf("Could not read lock file %s", lockFile)
+		return ExitUnknownError, err
+	}
+
+	err = mopts.WithInputDo(func(inputPath string, inputReader io.Reader) error {
+		file := filepath.ToSlash(filepath.Clean(inputPath))
+		lock.RemoveFile(file)
+
+		errFormat := mopts.WithFormatProcessorDo(inputReader, func(processor dockfmt.FormatProcessor) error {
+			processor = processor.WithWriter(ioutil.Discard)
+			// entries of included files are recorded again like the ones of the input
+			for _, other := range processor.Files() {
+				lock.RemoveFile(filepath.ToSlash(filepath.Clean(other)))
+			}
+
+			resolver := lo.Repo()
+			lo.noPlatforms = !dockref.CanResolvePlatform(resolver)
+			resolved, err := resolveMatching(ctx, lo.mainOptions(), predicate, processor, resolver, sameReference)
+			if err != nil {
+				return err
+			}
+			lo.resolutions = resolved
+
+			return lo.applyFormatProcessor(ctx, predicate, processor, lock, file)
+		})
+
+		if errFormat != nil {
+			exitCode = ExitInvalidFormat
+			return errFormat
+		}
+		return nil
+	})
+
+	if err == nil {
+		err = dockproc.PredicateErr(predicate)
+	}
+
+	if exitCode, ok := exitCodeFromError(err); ok {
+		return exitCode, err
+	}
+
+	err = lock.WriteFile(lockFile)
+	if err != nil {
+		lo.Log().WithField("error", err.Error()).Errorf("Could not write lock file %s", lockFile)
+		return ExitUnknownError, err
+	}
+
+	if lo.matches {
+		exitCode = ExitSuccess
+	} else {
+		exitCode = ExitNotFound
+	}
+
+	return exitCode, nil
+}
+
+func (lo *lockOptions) applyFormatProcessor(ctx context.Context, predicate dockproc.Predicate, processor dockfmt.FormatProcessor, lock *dockref.Lock, file string) error {
+
+	return processor.ProcessOccurrences(func(original dockref.Reference, occurrence dockfmt.Occurrence) (dockref.Reference, error) {
+		if !predicate.Matches(original) {
+			return original, nil
+		}
+
+		request, err := lo.mainOptions().resolveRequest(original, occurrence, !lo.noPlatforms)
+		if err != nil {
+			return nil, err
+		}
+
+		rs, err := lo.resolutions.resolve(ctx, lo.repoFactory, request)
+		if err != nil {
+			lo.Log().WithField("error", err.Error()).Errorf("Could not resolve %s", original.Original())
+			return nil, err
+		}
+
+		mostPrecise, err := lo.schemes.For(original).MostPreciseTag(rs, lo.Log())
+		if err != nil {
+			return nil, err
+		}
+
+		lo.matches = true
+		entryFile := file
+		if occurrence.File != "" {
+			entryFile = filepath.ToSlash(filepath.Clean(occurrence.File))
+		}
+
+		entry := dockref.LockEntryNew(entryFile, occurrence.Line, original, mostPrecise)
+		entry.Platform = request.Platform.String()
+		lock.Add(entry)
+
+		return original, nil
+	})
+}
+
+func (lo *lockOptions) Repo() dockref.Resolver {
+	return lo.repoFactory()
+}
+
+func lockOptionsNew(mainOptions *mainOptions, resolverFactory func() dockref.Resolver) *lockOptions {
+	lo := lockOptions{
+		MatchingOptions: MatchingOptions{
+			mainOpts: mainOptions,
+		},
+		repoFactory: resolverFactory,
+		matches:     false,
+	}
+
+	return &lo
+}
+
+func addLockCommand(mainOptions *mainOptions, adder func(opts *mainOptions, command string, shortDescription string, longDescription string, data interface{}) (*flags.Command, error)) (*flags.Command, error) {
+	repoFactory := mainOptions.resolverFactory()
+	lockOptions := lockOptionsNew(mainOptions, repoFactory)
+
+	command, e := adder(mainOptions, "lock",
+		"Record resolved image references in a lock file",
+		"Record the resolved tag and digest of image references in a lock file without changing the input file. Use --resolver lock to resolve from the lock file only.",
+		lockOptions)
+	if e != nil {
+		return nil, e
+	}
+	return command, e
+}
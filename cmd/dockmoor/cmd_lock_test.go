@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockproc"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/MeneDev/dockmoor/docktst/dockreftst"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const lockTestDigest = "sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf"
+
+func lockTestDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "dockmoor-lock")
+	assert.Nil(t, err)
+	return dir
+}
+
+func TestLockCommandRecordsOccurrences(t *testing.T) {
+	mainOptions := mainOptionsTestNew()
+	repo := dockreftst.MockResolverNew()
+	lo := lockOptionsNew(mainOptions.mainOptions, func() dockref.Resolver {
+		return repo
+	})
+
+	repo.OnResolve(dockref.MustParse("nginx")).Return([]dockref.Reference{
+		dockref.MustParse("nginx:1.15.6@" + lockTestDigest),
+	}, nil)
+
+	processorMock := &FormatProcessorMock{line: 7}
+	processorMock.process = func(imageNameProcessor dockfmt.ImageNameProcessor) error {
+		ref, e := imageNameProcessor(dockref.MustParse("nginx"))
+		assert.Nil(t, e)
+		assert.Equal(t, "nginx", ref.Original())
+		return nil
+	}
+	predicate, e := dockproc.AnyPredicateNew()
+	assert.Nil(t, e)
+
+	lock := dockref.LockNew()
+	err := lo.applyFormatProcessor(context.Background(), predicate, processorMock, lock, "Dockerfile")
+
+	assert.Nil(t, err)
+	assert.True(t, lo.matches)
+	assert.Equal(t, []dockref.LockEntry{{
+		File:     "Dockerfile",
+		Line:     7,
+		Original: "nginx",
+		Name:     "docker.io/library/nginx",
+		Tag:      "1.15.6",
+		Digest:   lockTestDigest,
+	}}, lock.References)
+}
+
+func TestLockCommandRecordsFileOfOccurrence(t *testing.T) {
+	mainOptions := mainOptionsTestNew()
+	repo := dockreftst.MockResolverNew()
+	lo := lockOptionsNew(mainOptions.mainOptions, func() dockref.Resolver {
+		return repo
+	})
+
+	repo.OnResolve(dockref.MustParse("nginx")).Return([]dockref.Reference{
+		dockref.MustParse("nginx:1.15.6@" + lockTestDigest),
+	}, nil)
+
+	processorMock := &FormatProcessorMock{line: 3, file: "ci/../ci/templates.yml"}
+	processorMock.process = func(imageNameProcessor dockfmt.ImageNameProcessor) error {
+		_, e := imageNameProcessor(dockref.MustParse("nginx"))
+		return e
+	}
+	predicate, e := dockproc.AnyPredicateNew()
+	assert.Nil(t, e)
+
+	lock := dockref.LockNew()
+	err := lo.applyFormatProcessor(context.Background(), predicate, processorMock, lock, ".gitlab-ci.yml")
+
+	assert.Nil(t, err)
+	if assert.Len(t, lock.References, 1) {
+		assert.Equal(t, "ci/templates.yml", lock.References[0].File, "references of included files are recorded for the included file")
+		assert.Equal(t, 3, lock.References[0].Line)
+	}
+}
+
+func TestLockCommandFailsWhenResolveFails(t *testing.T) {
+	mainOptions := mainOptionsTestNew()
+	repo := dockreftst.MockResolverNew()
+	lo := lockOptionsNew(mainOptions.mainOptions, func() dockref.Resolver {
+		return repo
+	})
+
+	expected := errors.New("offline")
+	repo.OnResolve(dockref.MustParse("nginx")).Return([]dockref.Reference{}, expected)
+
+	processorMock := &FormatProcessorMock{}
+	processorMock.process = func(imageNameProcessor dockfmt.ImageNameProcessor) error {
+		_, e := imageNameProcessor(dockref.MustParse("nginx"))
+		return e
+	}
+	predicate, e := dockproc.AnyPredicateNew()
+	assert.Nil(t, e)
+
+	err := lo.applyFormatProcessor(context.Background(), predicate, processorMock, dockref.LockNew(), "Dockerfile")
+	assert.Equal(t, expected, err)
+}
+
+func TestLockWritesLockFileAndKeepsInputFile(t *testing.T) {
+	dir := lockTestDir(t)
+	defer os.RemoveAll(dir)
+
+	df := dockerfile("FROM img\nRUN true\nFROM other:1")
+	defer os.Remove(df)
+	lockFile := filepath.Join(dir, "dockmoor.lock")
+
+	os.Args = []string{"exe", "--lock-file", lockFile, "lock", "--name", "docker.io/library/img", df}
+	mainOptions := mainOptionsACNew(addLockCommand)
+	repo := mainOptions.resolverFactory()().(*dockreftst.MockResolver)
+	repo.OnResolve(dockref.MustParse("img")).Return([]dockref.Reference{
+		dockref.MustParse("img:1.2.3@" + lockTestDigest),
+	}, nil)
+
+	exitCode := doMain(mainOptions)
+	assert.Equal(t, ExitSuccess, exitCode)
+
+	content, e := ioutil.ReadFile(df)
+	assert.Nil(t, e)
+	assert.Equal(t, "FROM img\nRUN true\nFROM other:1", string(content))
+
+	lock, e := dockref.LockReadFile(lockFile)
+	assert.Nil(t, e)
+	assert.Equal(t, []dockref.LockEntry{{
+		File:     filepath.ToSlash(filepath.Clean(df)),
+		Line:     1,
+		Original: "img",
+		Name:     "docker.io/library/img",
+		Tag:      "1.2.3",
+		Digest:   lockTestDigest,
+	}}, lock.References)
+}
+
+func TestLockRemovesEntriesOfIncludedFiles(t *testing.T) {
+	dir := lockTestDir(t)
+	defer os.RemoveAll(dir)
+
+	gitlabCi := filepath.Join(dir, ".gitlab-ci.yml")
+	templates := filepath.Join(dir, "templates.yml")
+	assert.Nil(t, ioutil.WriteFile(gitlabCi, []byte("include: /templates.yml\n\nbuild:\n  image: img\n  script: make\n"), 0600))
+	assert.Nil(t, ioutil.WriteFile(templates, []byte(".test:\n  script: make\n"), 0600))
+
+	// the image was removed from the included file since it was locked
+	lockFile := filepath.Join(dir, "dockmoor.lock")
+	stale := dockref.LockNew()
+	stale.Add(dockref.LockEntry{File: filepath.ToSlash(templates), Line: 2, Original: "postgres:13", Name: "docker.io/library/postgres", Tag: "13.2", Digest: lockTestDigest})
+	assert.Nil(t, stale.WriteFile(lockFile))
+
+	os.Args = []string{"exe", "--lock-file", lockFile, "lock", gitlabCi}
+	mainOptions := mainOptionsACNew(addLockCommand)
+	repo := mainOptions.resolverFactory()().(*dockreftst.MockResolver)
+	repo.OnResolve(dockref.MustParse("img")).Return([]dockref.Reference{
+		dockref.MustParse("img:1.2.3@" + lockTestDigest),
+	}, nil)
+	assert.Equal(t, ExitSuccess, doMain(mainOptions))
+
+	lock, e := dockref.LockReadFile(lockFile)
+	assert.Nil(t, e)
+	if assert.Len(t, lock.References, 1) {
+		assert.Equal(t, "img", lock.References[0].Original)
+	}
+}
+
+func TestLockThenPinWithLockResolver(t *testing.T) {
+	dir := lockTestDir(t)
+	defer os.RemoveAll(dir)
+
+	df := dockerfile("FROM img")
+	defer os.Remove(df)
+	lockFile := filepath.Join(dir, "dockmoor.lock")
+
+	os.Args = []string{"exe", "--lock-file", lockFile, "lock", df}
+	mainOptions := mainOptionsACNew(addLockCommand)
+	repo := mainOptions.resolverFactory()().(*dockreftst.MockResolver)
+	repo.OnResolve(dockref.MustParse("img")).Return([]dockref.Reference{
+		dockref.MustParse("img:1.2.3@" + lockTestDigest),
+	}, nil)
+	assert.Equal(t, ExitSuccess, doMain(mainOptions))
+
+	os.Args = []string{"exe", "--resolver", "lock", "--lock-file", lockFile, "pin", df}
+	mainOptions = mainOptionsNew()
+	mainOptions.SetStdout(ioutil.Discard)
+	_, err := addPinCommand(mainOptions, AddCommand)
+	assert.Nil(t, err)
+	assert.Equal(t, ExitSuccess, doMain(mainOptions))
+
+	content, e := ioutil.ReadFile(df)
+	assert.Nil(t, e)
+	assert.Equal(t, "FROM img:1.2.3@"+lockTestDigest, string(content))
+}
+
+func TestLockThenPinPlatformWithLockResolver(t *testing.T) {
+	dir := lockTestDir(t)
+	defer os.RemoveAll(dir)
+
+	df := dockerfile("FROM --platform=linux/arm64 img")
+	defer os.Remove(df)
+	lockFile := filepath.Join(dir, "dockmoor.lock")
+
+	os.Args = []string{"exe", "--lock-file", lockFile, "lock", df}
+	mainOptions := mainOptionsACNew(addLockCommand)
+	repo := mainOptions.resolverFactory()().(*dockreftst.MockResolver)
+	repo.OnResolvePlatform(dockref.MustParse("img"), dockref.MustParsePlatform("linux/arm64")).Return([]dockref.Reference{
+		dockref.MustParse("img:1.2.3@" + lockTestDigest),
+	}, nil)
+	assert.Equal(t, ExitSuccess, doMain(mainOptions))
+
+	lock, err := dockref.LockReadFile(lockFile)
+	assert.Nil(t, err)
+	if assert.Len(t, lock.References, 1) {
+		assert.Equal(t, "linux/arm64", lock.References[0].Platform)
+	}
+
+	os.Args = []string{"exe", "--resolver", "lock", "--lock-file", lockFile, "pin", df}
+	mainOptions = mainOptionsNew()
+	mainOptions.SetStdout(ioutil.Discard)
+	_, err = addPinCommand(mainOptions, AddCommand)
+	assert.Nil(t, err)
+	assert.Equal(t, ExitSuccess, doMain(mainOptions))
+
+	content, e := ioutil.ReadFile(df)
+	assert.Nil(t, e)
+	assert.Equal(t, "FROM --platform=linux/arm64 img:1.2.3@"+lockTestDigest, string(content))
+}
+
+func TestPinWithLockResolverFailsForUnlockedReference(t *testing.T) {
+	dir := lockTestDir(t)
+	defer os.RemoveAll(dir)
+
+	lockFile := filepath.Join(dir, "dockmoor.lock")
+	assert.Nil(t, dockref.LockNew().WriteFile(lockFile))
+
+	df := dockerfile("FROM img")
+	defer os.Remove(df)
+
+	os.Args = []string{"exe", "--resolver", "lock", "--lock-file", lockFile, "pin", df}
+	mainOptions := mainOptionsNew()
+	mainOptions.SetStdout(ioutil.Discard)
+	_, err := addPinCommand(mainOptions, AddCommand)
+	assert.Nil(t, err)
+
+	assert.NotEqual(t, ExitSuccess, doMain(mainOptions))
+
+	content, e := ioutil.ReadFile(df)
+	assert.Nil(t, e)
+	assert.Equal(t, "FROM img", string(content))
+}
+
+func TestLockRejectsLockResolver(t *testing.T) {
+	df := dockerfile("FROM img")
+	defer os.Remove(df)
+
+	os.Args = []string{"exe", "--resolver", "lock", "lock", df}
+	mainOptions := mainOptionsACNew(addLockCommand)
+
+	assert.Equal(t, ExitInvalidParams, doMain(mainOptions))
+}
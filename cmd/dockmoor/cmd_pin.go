@@ -2,10 +2,13 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"errors"
+	"fmt"
 	"github.com/MeneDev/dockmoor/dockfmt"
 	"github.com/MeneDev/dockmoor/dockproc"
 	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/docker/distribution/reference"
 	"github.com/jessevdk/go-flags"
 	"io"
 	"io/ioutil"
@@ -16,10 +19,11 @@ type pinOptions struct {
 	MatchingOptions
 
 	ReferenceFormat struct {
-		ForceDomain bool `required:"no" long:"force-domain" description:"Includes domain even in well-known references"`
-		NoName      bool `required:"no" long:"no-name" description:"Formats well-known references as digest only"`
-		NoTag       bool `required:"no" long:"no-tag" description:"Don't include the tag in the reference"`
-		NoDigest    bool `required:"no" long:"no-digest" description:"Don't include the digest in the reference"`
+		ForceDomain bool   `required:"no" long:"force-domain" description:"Includes domain even in well-known references"`
+		NoName      bool   `required:"no" long:"no-name" description:"Formats well-known references as digest only"`
+		NoTag       bool   `required:"no" long:"no-tag" description:"Don't include the tag in the reference"`
+		NoDigest    bool   `required:"no" long:"no-digest" description:"Don't include the digest in the reference"`
+		TagStrategy string `required:"no" long:"tag-strategy" description:"Tag to pin: keep the tag, the most-precise or least-precise version tag, or prefer:<tag>[,<tag>...] the first of the tags that refers to the same image" default:"most-precise"`
 	} `group:"Reference format" description:"Control the format of references, defaults are sensible, changes are not recommended"`
 
 	Output struct {
@@ -27,7 +31,15 @@ type pinOptions struct {
 	} `group:"Output parameters" description:"Output parameters"`
 
 	repoFactory func() dockref.Resolver
+	// target is the reference that is pinned instead of a matching reference
+	target      retarget
+	resolutions resolutions
+	schemes     dockref.TagSchemes
+	strategy    dockref.TagStrategy
 	matches     bool
+
+	// noPlatforms is set when the resolver of the resolutions doesn't support platforms
+	noPlatforms bool
 }
 
 func (po *pinOptions) Execute(args []string) error {
@@ -43,7 +55,21 @@ func (po *pinOptions) ExecuteWithExitCode(args []string) (exitCode ExitCode, err
 		return
 	}
 
-	predicate, err := mopts.getPredicate()
+	po.schemes, err = po.mainOptions().tagSchemes()
+	if err != nil {
+		return ExitInvalidParams, err
+	}
+
+	po.strategy, err = dockref.ParseTagStrategy(po.ReferenceFormat.TagStrategy)
+	if err != nil {
+		return ExitInvalidParams, err
+	}
+	po.mainOptions().noPreciseTags = po.ReferenceFormat.TagStrategy == "keep"
+
+	ctx, cancel := po.mainOptions().resolveContext()
+	defer cancel()
+
+	predicate, err := mopts.getPredicate(ctx)
 	if err != nil {
 		return ExitPredicateInvalid, err
 	}
@@ -53,8 +79,16 @@ func (po *pinOptions) ExecuteWithExitCode(args []string) (exitCode ExitCode, err
 	err = mopts.WithInputDo(func(inputPath string, inputReader io.Reader) error {
 
 		errFormat := mopts.WithFormatProcessorDo(inputReader, func(processor dockfmt.FormatProcessor) error {
-			processor = processor.WithWriter(buffer)
-			return po.applyFormatProcessor(predicate, processor)
+			resolver := po.Repo()
+			po.noPlatforms = !dockref.CanResolvePlatform(resolver)
+			resolved, err := resolveMatching(ctx, po.mainOptions(), predicate, processor.WithWriter(ioutil.Discard), resolver, po.target)
+			if err != nil {
+				return err
+			}
+			po.resolutions = resolved
+
+			processor = processor.WithWriter(buffer).WithFileWriter(po.fileWriter())
+			return po.applyFormatProcessor(ctx, predicate, processor)
 		})
 
 		if errFormat != nil {
@@ -64,6 +98,10 @@ func (po *pinOptions) ExecuteWithExitCode(args []string) (exitCode ExitCode, err
 		return nil
 	})
 
+	if err == nil {
+		err = dockproc.PredicateErr(predicate)
+	}
+
 	if exitCode, ok := exitCodeFromError(err); ok {
 		return exitCode, err
 	}
@@ -90,12 +128,32 @@ func (po *pinOptions) ExecuteWithExitCode(args []string) (exitCode ExitCode, err
 	return exitCode, err
 }
 
-func (po *pinOptions) applyFormatProcessor(predicate dockproc.Predicate, processor dockfmt.FormatProcessor) error {
+// fileWriter refuses to change files other than the input, e.g. the .env file of compose files, when an output is given
+func (po *pinOptions) fileWriter() dockfmt.FileWriter {
+	output := string(po.Output.OutputFile)
+	if output == "" {
+		return nil
+	}
+	return func(filename string, content []byte) error {
+		return fmt.Errorf("cannot change %s, only %s is written when --output is given", filename, output)
+	}
+}
+
+func (po *pinOptions) applyFormatProcessor(ctx context.Context, predicate dockproc.Predicate, processor dockfmt.FormatProcessor) error {
 
-	return processor.Process(func(original dockref.Reference) (dockref.Reference, error) {
+	return processor.ProcessOccurrences(func(original dockref.Reference, occurrence dockfmt.Occurrence) (dockref.Reference, error) {
 		if predicate.Matches(original) {
-			repo := po.Repo()
-			rs, err := repo.Resolve(original)
+			reference, err := po.target(ctx, original)
+			if err != nil {
+				return nil, err
+			}
+
+			request, err := po.mainOptions().resolveRequest(reference, occurrence, !po.noPlatforms)
+			if err != nil {
+				return nil, err
+			}
+
+			rs, err := po.resolutions.resolve(ctx, po.repoFactory, request)
 			if err != nil {
 				po.Log().WithField("error", err.Error()).Errorf("Could not resolve %s", original.Original())
 				return nil, err
@@ -106,23 +164,48 @@ func (po *pinOptions) applyFormatProcessor(predicate dockproc.Predicate, process
 				return nil, err
 			}
 
-			mostPrecise, err := dockref.MostPreciseTag(rs, po.Log())
+			chosen, err := po.strategy(ctx, dockref.TagRequest{
+				Original:   reference,
+				References: rs,
+				Scheme:     po.schemes.For(reference),
+				ResolveTag: po.tagResolver(request),
+				Log:        po.Log(),
+			})
 
 			if err == nil {
 				po.matches = true
-				reference, e := mostPrecise.WithRequestedFormat(format)
+				if chosen.Tag() == "" {
+					// e.g. the keep strategy for references without tag
+					format &= ^dockref.FormatHasTag
+				}
+				reference, e := chosen.WithRequestedFormat(format)
 				if e != nil {
 					return nil, e
 				}
-				mostPrecise = reference
-				return mostPrecise, err
+				chosen = reference
+				return chosen, err
 			}
-			return mostPrecise, err
+			return chosen, err
 		}
 		return original, nil
 	})
 }
 
+// tagResolver resolves other tags of the request's repository for the same platform
+func (po *pinOptions) tagResolver(request dockref.ResolveRequest) func(ctx context.Context, tag string) ([]dockref.Reference, error) {
+	if request.Reference.Named() == nil {
+		return nil
+	}
+
+	return func(ctx context.Context, tag string) ([]dockref.Reference, error) {
+		tagged, err := dockref.Parse(reference.FamiliarName(request.Reference.Named()) + ":" + tag)
+		if err != nil {
+			return nil, err
+		}
+		return po.resolutions.resolve(ctx, po.repoFactory, dockref.ResolveRequest{Reference: tagged, Platform: request.Platform})
+	}
+}
+
 func (po *pinOptions) Repo() dockref.Resolver {
 	return po.repoFactory()
 }
@@ -133,8 +216,11 @@ func pinOptionsNew(mainOptions *mainOptions, resolverFactory func() dockref.Reso
 			mainOpts: mainOptions,
 		},
 		repoFactory: resolverFactory,
+		target:      sameReference,
+		strategy:    dockref.MostPreciseTagStrategy,
 		matches:     false,
 	}
+	po.ReferenceFormat.TagStrategy = "most-precise"
 
 	return &po
 }
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"github.com/MeneDev/dockmoor/dockfmt"
 	"github.com/MeneDev/dockmoor/dockproc"
@@ -212,7 +213,7 @@ func TestPinCommand_applyFormatProcessor_FailsWithInvalidFormattingFlags(t *test
 
 	predicate, e := dockproc.AnyPredicateNew()
 	assert.Nil(t, e)
-	err := po.applyFormatProcessor(predicate, processorMock)
+	err := po.applyFormatProcessor(context.Background(), predicate, processorMock)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "invalid Reference Format")
 }
@@ -275,7 +276,7 @@ func TestPinCommandPins(t *testing.T) {
 		predicate, e := dockproc.AnyPredicateNew()
 		assert.Nil(t, e)
 
-		po.applyFormatProcessor(predicate, processorMock)
+		po.applyFormatProcessor(context.Background(), predicate, processorMock)
 		assert.True(t, ran)
 	}
 
@@ -426,7 +427,211 @@ func TestPinOptions_applyFormatProcessor_ReturnsError(t *testing.T) {
 	predicate, e := dockproc.AnyPredicateNew()
 	assert.Nil(t, e)
 
-	err := po.applyFormatProcessor(predicate, processorMock)
+	err := po.applyFormatProcessor(context.Background(), predicate, processorMock)
 
 	assert.Equal(t, expected, err)
 }
+
+func TestPinResolvesDuplicateReferencesOnce(t *testing.T) {
+	df1 := dockerfile("FROM img AS a\nFROM other\nFROM img AS b")
+	defer os.Remove(df1)
+
+	os.Args = []string{"exe", "--jobs", "2", "pin", df1}
+	mainOptions := mainOptionsACNew(addPinCommand)
+
+	repo := mainOptions.resolverFactory()().(*dockreftst.MockResolver)
+	repo.OnResolve(dockref.MustParse("img")).Return([]dockref.Reference{
+		dockref.MustParse("img:1.2.3@sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf"),
+	}, nil)
+	repo.OnResolve(dockref.MustParse("other")).Return([]dockref.Reference{
+		dockref.MustParse("other:1@sha256:31b8e90a349d1fce7621f5a5a08e4fc519b634f7d3feb09d53fac9b12aa4d991"),
+	}, nil)
+
+	exitCode := doMain(mainOptions)
+
+	assert.Equal(t, ExitSuccess, exitCode)
+	repo.AssertNumberOfCalls(t, "Resolve", 2)
+
+	fileBytes, e := ioutil.ReadFile(df1)
+	assert.Nil(t, e)
+	assert.Equal(t, "FROM img:1.2.3@sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf AS a\n"+
+		"FROM other:1@sha256:31b8e90a349d1fce7621f5a5a08e4fc519b634f7d3feb09d53fac9b12aa4d991\n"+
+		"FROM img:1.2.3@sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf AS b", string(fileBytes))
+}
+
+func TestPinRequiresAtLeastOneJob(t *testing.T) {
+	_, _, exitCode, stdout := testMain([]string{"--jobs", "0", "pin", "fileName"}, addPinCommand)
+
+	assert.Equal(t, ExitInvalidParams, exitCode)
+	assert.Contains(t, stdout.String(), "--jobs must be at least 1")
+}
+
+func TestPinUsesPlatformOfDockerfileBeforePlatformOption(t *testing.T) {
+	df1 := dockerfile("FROM --platform=linux/arm64 img AS a\nFROM img AS b\nFROM --platform=$BUILDPLATFORM img AS c")
+	defer os.Remove(df1)
+
+	os.Args = []string{"exe", "--platform", "linux/amd64", "pin", df1}
+	mainOptions := mainOptionsACNew(addPinCommand)
+
+	repo := mainOptions.resolverFactory()().(*dockreftst.MockResolver)
+	repo.OnResolvePlatform(dockref.MustParse("img"), dockref.MustParsePlatform("linux/arm64")).Return([]dockref.Reference{
+		dockref.MustParse("img:1.2.3@sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf"),
+	}, nil)
+	repo.OnResolvePlatform(dockref.MustParse("img"), dockref.MustParsePlatform("linux/amd64")).Return([]dockref.Reference{
+		dockref.MustParse("img:1.2.3@sha256:31b8e90a349d1fce7621f5a5a08e4fc519b634f7d3feb09d53fac9b12aa4d991"),
+	}, nil)
+
+	exitCode := doMain(mainOptions)
+
+	assert.Equal(t, ExitSuccess, exitCode)
+	repo.AssertNumberOfCalls(t, "ResolvePlatform", 2)
+	repo.AssertNotCalled(t, "Resolve", mock.Anything)
+
+	fileBytes, e := ioutil.ReadFile(df1)
+	assert.Nil(t, e)
+	assert.Equal(t, "FROM --platform=linux/arm64 img:1.2.3@sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf AS a\n"+
+		"FROM img:1.2.3@sha256:31b8e90a349d1fce7621f5a5a08e4fc519b634f7d3feb09d53fac9b12aa4d991 AS b\n"+
+		"FROM --platform=$BUILDPLATFORM img:1.2.3@sha256:31b8e90a349d1fce7621f5a5a08e4fc519b634f7d3feb09d53fac9b12aa4d991 AS c", string(fileBytes))
+}
+
+// resolverWithoutPlatforms hides the platform support of the mock, like the dockerd resolver
+type resolverWithoutPlatforms struct {
+	dockref.Resolver
+}
+
+func mainOptionsWithoutPlatformsNew() (*mainOptions, *dockreftst.MockResolver) {
+	mainOptions := mainOptionsACNew()
+	repo := mainOptions.resolverFactory()().(*dockreftst.MockResolver)
+	mainOptions.resolverFactory = func() func() dockref.Resolver {
+		return func() dockref.Resolver {
+			return resolverWithoutPlatforms{repo}
+		}
+	}
+	_, err := addPinCommand(mainOptions, AddCommand)
+	if err != nil {
+		panic(err)
+	}
+	return mainOptions, repo
+}
+
+func TestPinIgnoresPlatformOfDockerfileWhenResolverDoesNotSupportPlatforms(t *testing.T) {
+	df1 := dockerfile("FROM --platform=linux/amd64 img")
+	defer os.Remove(df1)
+
+	os.Args = []string{"exe", "pin", df1}
+	mainOptions, repo := mainOptionsWithoutPlatformsNew()
+	repo.OnResolve(dockref.MustParse("img")).Return([]dockref.Reference{
+		dockref.MustParse("img:1.2.3@sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf"),
+	}, nil)
+
+	exitCode := doMain(mainOptions)
+
+	assert.Equal(t, ExitSuccess, exitCode)
+	repo.AssertNotCalled(t, "ResolvePlatform", mock.Anything, mock.Anything)
+
+	fileBytes, e := ioutil.ReadFile(df1)
+	assert.Nil(t, e)
+	assert.Equal(t, "FROM --platform=linux/amd64 img:1.2.3@sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf", string(fileBytes), "the index digest is pinned")
+}
+
+func TestPinFailsForPlatformOptionWhenResolverDoesNotSupportPlatforms(t *testing.T) {
+	df1 := dockerfile("FROM --platform=linux/amd64 img")
+	defer os.Remove(df1)
+
+	os.Args = []string{"exe", "--platform", "linux/arm64", "pin", df1}
+	mainOptions, repo := mainOptionsWithoutPlatformsNew()
+
+	exitCode := doMain(mainOptions)
+
+	assert.NotEqual(t, ExitSuccess, exitCode)
+	repo.AssertNotCalled(t, "Resolve", mock.Anything, mock.Anything)
+
+	fileBytes, e := ioutil.ReadFile(df1)
+	assert.Nil(t, e)
+	assert.Equal(t, "FROM --platform=linux/amd64 img", string(fileBytes))
+}
+
+func TestPinRejectsInvalidPlatform(t *testing.T) {
+	_, _, exitCode, stdout := testMain([]string{"--platform", "amd65", "pin", "fileName"}, addPinCommand)
+
+	assert.Equal(t, ExitInvalidParams, exitCode)
+	assert.Contains(t, stdout.String(), "invalid platform 'amd65'")
+}
+
+func TestPinFailsForInvalidPlatformOfDockerfile(t *testing.T) {
+	df1 := dockerfile("FROM --platform=amd65 img")
+	defer os.Remove(df1)
+
+	os.Args = []string{"exe", "pin", df1}
+	mainOptions := mainOptionsACNew(addPinCommand)
+
+	exitCode := doMain(mainOptions)
+
+	assert.NotEqual(t, ExitSuccess, exitCode)
+	fileBytes, e := ioutil.ReadFile(df1)
+	assert.Nil(t, e)
+	assert.Equal(t, "FROM --platform=amd65 img", string(fileBytes))
+}
+
+func TestPinTagStrategies(t *testing.T) {
+	const digest = "sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf"
+
+	for strategy, expected := range map[string]string{
+		"most-precise":       "FROM nginx:1.15.6@" + digest + "\nFROM nginx:1.15.6@" + digest,
+		"least-precise":      "FROM nginx:1.15@" + digest + "\nFROM nginx:1@" + digest,
+		"keep":               "FROM nginx:1.15@" + digest + "\nFROM nginx@" + digest,
+		"prefer:lts,stable":  "FROM nginx:stable@" + digest + "\nFROM nginx:stable@" + digest,
+		"prefer:lts,missing": "FROM nginx:1.15.6@" + digest + "\nFROM nginx:1.15.6@" + digest,
+	} {
+		t.Run(strategy, func(t *testing.T) {
+			df1 := dockerfile("FROM nginx:1.15\nFROM nginx")
+			defer os.Remove(df1)
+
+			os.Args = []string{"exe", "pin", "--tag-strategy", strategy, df1}
+			mainOptions := mainOptionsACNew(addPinCommand)
+
+			repo := mainOptions.resolverFactory()().(*dockreftst.MockResolver)
+			repo.OnResolve(dockref.MustParse("nginx:1.15")).Return([]dockref.Reference{
+				dockref.MustParse("nginx:1.15@" + digest),
+				dockref.MustParse("nginx:1.15.6@" + digest),
+			}, nil)
+			repo.OnResolve(dockref.MustParse("nginx")).Return([]dockref.Reference{
+				dockref.MustParse("nginx:latest@" + digest),
+				dockref.MustParse("nginx:1@" + digest),
+				dockref.MustParse("nginx:1.15.6@" + digest),
+			}, nil)
+			repo.OnResolve(dockref.MustParse("nginx:stable")).Return([]dockref.Reference{
+				dockref.MustParse("nginx:stable@" + digest),
+			}, nil)
+			repo.OnResolve(dockref.MustParse("nginx:lts")).Return([]dockref.Reference{}, dockref.NotFoundError{Reference: "nginx:lts"})
+			repo.OnResolve(dockref.MustParse("nginx:missing")).Return([]dockref.Reference{}, dockref.NotFoundError{Reference: "nginx:missing"})
+
+			exitCode := doMain(mainOptions)
+
+			assert.Equal(t, ExitSuccess, exitCode)
+
+			fileBytes, e := ioutil.ReadFile(df1)
+			assert.Nil(t, e)
+			assert.Equal(t, expected, string(fileBytes))
+
+			registryOptions, e := mainOptions.registryOptions()
+			assert.Nil(t, e)
+			assert.Equal(t, strategy == "keep", registryOptions.NoPreciseTags)
+		})
+	}
+}
+
+func TestPinRejectsInvalidTagStrategy(t *testing.T) {
+	df1 := dockerfile("FROM nginx:1.15")
+	defer os.Remove(df1)
+
+	os.Args = []string{"exe", "pin", "--tag-strategy", "newest", df1}
+	mainOptions := mainOptionsACNew(addPinCommand)
+
+	exitCode := doMain(mainOptions)
+
+	assert.Equal(t, ExitInvalidParams, exitCode)
+	fileBytes, e := ioutil.ReadFile(df1)
+	assert.Nil(t, e)
+	assert.Equal(t, "FROM nginx:1.15", string(fileBytes))
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/docker/distribution/reference"
+	"github.com/jessevdk/go-flags"
+	"sync"
+	"time"
+)
+
+type updateOptions struct {
+	pinOptions
+
+	Update struct {
+		Level        string `required:"no" long:"level" description:"Newest version allowed: patch keeps major and minor version, minor keeps the major version, major allows any newer version" choice:"patch" choice:"minor" choice:"major" default:"minor"`
+		VariantLevel string `required:"no" long:"variant-level" description:"Newest version of versioned variants like alpine3.18 unless the update policy sets one: none keeps the variant, the other levels like --level" choice:"none" choice:"patch" choice:"minor" choice:"major" default:"minor"`
+	} `group:"Update Options" description:"Control which newer versions are used"`
+
+	// tags by repository name, both phases of the pin command ask for the tags
+	tagsMutex sync.Mutex
+	tags      map[string][]string
+
+	policies dockref.UpdatePolicies
+	now      func() time.Time
+}
+
+func (uo *updateOptions) Execute(args []string) error {
+	return errors.New("Use ExecuteWithExitCode instead")
+}
+
+func (uo *updateOptions) ExecuteWithExitCode(args []string) (exitCode ExitCode, err error) {
+	level, err := dockref.ParseUpdateLevel(uo.Update.Level)
+	if err != nil {
+		return ExitInvalidParams, err
+	}
+
+	variantLevel, err := dockref.ParseVariantLevel(uo.Update.VariantLevel)
+	if err != nil {
+		return ExitInvalidParams, err
+	}
+
+	uo.policies, err = uo.mainOptions().updatePolicies()
+	if err != nil {
+		return ExitInvalidParams, err
+	}
+
+	uo.target = func(ctx context.Context, original dockref.Reference) (dockref.Reference, error) {
+		return uo.newestReference(ctx, original, level, variantLevel)
+	}
+
+	return uo.pinOptions.ExecuteWithExitCode(args)
+}
+
+// newestReference replaces the tag with the newest tag allowed by the levels and the update policy,
+// references without version tag are kept
+func (uo *updateOptions) newestReference(ctx context.Context, original dockref.Reference, level dockref.UpdateLevel, variantLevel dockref.UpdateLevel) (dockref.Reference, error) {
+	if original.Named() == nil || original.Tag() == "" {
+		return original, nil
+	}
+
+	tags, err := uo.listTags(ctx, original)
+	if err != nil {
+		uo.Log().WithField("error", err.Error()).Errorf("Could not list tags of %s", original.Original())
+		return nil, err
+	}
+
+	policy := uo.policies.For(original)
+	if policy.VariantLevel == nil {
+		policy.VariantLevel = &variantLevel
+	}
+	candidates := uo.schemes.For(original).UpdateCandidates(original, tags, level, policy)
+	if len(candidates) == 0 {
+		return original, nil
+	}
+
+	tag := candidates[0]
+	if policy.MinAge > 0 {
+		tag, err = dockref.FirstMatureTag(ctx, uo.Repo(), original, candidates, policy.MinAge, uo.now())
+		if err != nil {
+			uo.Log().WithField("error", err.Error()).Errorf("Could not check the age of the newer versions of %s", original.Original())
+			return nil, err
+		}
+		if tag == "" {
+			uo.Log().Infof("Keeping %s, all newer versions are younger than %s", original.Original(), policy.MinAge)
+			return original, nil
+		}
+	}
+
+	uo.Log().Infof("Updating %s to %s", original.Original(), tag)
+	// a new reference without the digest of the old tag, resolvers like dockerd use the original string
+	return dockref.Parse(reference.FamiliarName(original.Named()) + ":" + tag)
+}
+
+func (uo *updateOptions) listTags(ctx context.Context, original dockref.Reference) ([]string, error) {
+	// the lock is not held while listing, other repositories are listed concurrently
+	uo.tagsMutex.Lock()
+	tags, ok := uo.tags[original.Name()]
+	uo.tagsMutex.Unlock()
+	if ok {
+		return tags, nil
+	}
+
+	tags, err := dockref.ListTags(ctx, uo.Repo(), original)
+	if err != nil {
+		return nil, err
+	}
+
+	uo.tagsMutex.Lock()
+	uo.tags[original.Name()] = tags
+	uo.tagsMutex.Unlock()
+	return tags, nil
+}
+
+func updateOptionsNew(mainOptions *mainOptions, resolverFactory func() dockref.Resolver) *updateOptions {
+	return &updateOptions{
+		pinOptions: *pinOptionsNew(mainOptions, resolverFactory),
+		tags:       make(map[string][]string),
+		now:        time.Now,
+	}
+}
+
+func addUpdateCommand(mainOptions *mainOptions, adder func(opts *mainOptions, command string, shortDescription string, longDescription string, data interface{}) (*flags.Command, error)) (*flags.Command, error) {
+	repoFactory := mainOptions.resolverFactory()
+	updateOptions := updateOptionsNew(mainOptions, repoFactory)
+
+	return adder(mainOptions, "update",
+		"Change image references to newer versions",
+		"Change image references to the newest version of the same variant allowed by --level and --policy and pin them like the pin command",
+		updateOptions)
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/MeneDev/dockmoor/docktst/dockreftst"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+const (
+	updateTestDigestA = "sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf"
+	updateTestDigestB = "sha256:31b8e90a349d1fce7621f5a5a08e4fc519b634f7d3feb09d53fac9b12aa4d991"
+)
+
+var updateTestTags = []string{"latest", "1.15", "1.15.6", "1.15.7", "1.16", "1.16.1", "2.0.0", "1.15-alpine", "1.16-alpine"}
+
+func TestUpdateLevels(t *testing.T) {
+	for level, expected := range map[string]string{
+		"patch": "nginx:1.15.7@" + updateTestDigestA,
+		"minor": "nginx:1.16.1@" + updateTestDigestA,
+		"major": "nginx:2.0.0@" + updateTestDigestA,
+	} {
+		t.Run(level, func(t *testing.T) {
+			df1 := dockerfile("FROM nginx:1.15.6@" + updateTestDigestB)
+			defer os.Remove(df1)
+
+			os.Args = []string{"exe", "update", "--level", level, df1}
+			mainOptions := mainOptionsACNew(addUpdateCommand)
+
+			newest := dockref.MustParse(expected)
+			repo := mainOptions.resolverFactory()().(*dockreftst.MockResolver)
+			repo.OnListTags(mock.Anything).Return(updateTestTags, nil)
+			repo.OnResolve(dockref.MustParse("nginx:"+newest.Tag())).Return([]dockref.Reference{newest}, nil)
+
+			exitCode := doMain(mainOptions)
+
+			assert.Equal(t, ExitSuccess, exitCode)
+			repo.AssertNumberOfCalls(t, "ListTags", 1)
+
+			fileBytes, e := ioutil.ReadFile(df1)
+			assert.Nil(t, e)
+			assert.Equal(t, "FROM "+expected, string(fileBytes))
+		})
+	}
+}
+
+func TestUpdateKeepsVariantAndUnversionedTags(t *testing.T) {
+	df1 := dockerfile("FROM nginx:1.15-alpine AS a\nFROM nginx:latest AS b")
+	defer os.Remove(df1)
+
+	os.Args = []string{"exe", "update", "--no-digest", df1}
+	mainOptions := mainOptionsACNew(addUpdateCommand)
+
+	repo := mainOptions.resolverFactory()().(*dockreftst.MockResolver)
+	repo.OnListTags(mock.Anything).Return(updateTestTags, nil)
+	repo.OnResolve(dockref.MustParse("nginx:1.16-alpine")).Return([]dockref.Reference{
+		dockref.MustParse("nginx:1.16-alpine@" + updateTestDigestA),
+	}, nil)
+	repo.OnResolve(dockref.MustParse("nginx:latest")).Return([]dockref.Reference{
+		dockref.MustParse("nginx:latest@" + updateTestDigestB),
+	}, nil)
+
+	exitCode := doMain(mainOptions)
+
+	assert.Equal(t, ExitSuccess, exitCode)
+
+	fileBytes, e := ioutil.ReadFile(df1)
+	assert.Nil(t, e)
+	assert.Equal(t, "FROM nginx:1.16-alpine AS a\nFROM nginx:latest AS b", string(fileBytes))
+}
+
+func TestUpdateIsScopedByPredicates(t *testing.T) {
+	df1 := dockerfile("FROM nginx:1.15.6\nFROM alpine:3.7")
+	defer os.Remove(df1)
+
+	os.Args = []string{"exe", "update", "--familiar-name", "nginx", df1}
+	mainOptions := mainOptionsACNew(addUpdateCommand)
+
+	repo := mainOptions.resolverFactory()().(*dockreftst.MockResolver)
+	repo.OnListTags(dockref.MustParse("nginx:1.15.6")).Return(updateTestTags, nil)
+	repo.OnResolve(dockref.MustParse("nginx:1.16.1")).Return([]dockref.Reference{
+		dockref.MustParse("nginx:1.16.1@" + updateTestDigestA),
+	}, nil)
+
+	exitCode := doMain(mainOptions)
+
+	assert.Equal(t, ExitSuccess, exitCode)
+
+	fileBytes, e := ioutil.ReadFile(df1)
+	assert.Nil(t, e)
+	assert.Equal(t, "FROM nginx:1.16.1@"+updateTestDigestA+"\nFROM alpine:3.7", string(fileBytes))
+}
+
+func TestUpdateFailsWhenTagsCannotBeListed(t *testing.T) {
+	df1 := dockerfile("FROM nginx:1.15.6")
+	defer os.Remove(df1)
+
+	os.Args = []string{"exe", "update", df1}
+	mainOptions := mainOptionsACNew(addUpdateCommand)
+
+	repo := mainOptions.resolverFactory()().(*dockreftst.MockResolver)
+	repo.OnListTags(mock.Anything).Return([]string(nil), errors.New("registry unavailable"))
+
+	exitCode := doMain(mainOptions)
+
+	assert.NotEqual(t, ExitSuccess, exitCode)
+
+	fileBytes, e := ioutil.ReadFile(df1)
+	assert.Nil(t, e)
+	assert.Equal(t, "FROM nginx:1.15.6", string(fileBytes))
+}
+
+func TestUpdateListsTagsOfRepositoriesConcurrently(t *testing.T) {
+	mainOptions := mainOptionsTestNew()
+	repo := dockreftst.MockResolverNew()
+	uo := updateOptionsNew(mainOptions.mainOptions, func() dockref.Resolver {
+		return repo
+	})
+
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	repo.OnListTags(mock.Anything).Run(func(args mock.Arguments) {
+		started <- struct{}{}
+		<-release
+	}).Return(updateTestTags, nil)
+
+	done := make(chan error, 2)
+	for _, name := range []string{"nginx:1.15", "httpd:2.4"} {
+		go func(name string) {
+			_, e := uo.listTags(context.Background(), dockref.MustParse(name))
+			done <- e
+		}(name)
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-started:
+		case <-time.After(5 * time.Second):
+			t.Fatal("the tags of the second repository are not listed while the first one is")
+		}
+	}
+	close(release)
+
+	assert.Nil(t, <-done)
+	assert.Nil(t, <-done)
+}
+
+func TestUpdateRejectsInvalidLevel(t *testing.T) {
+	_, _, exitCode, _ := testMain([]string{"update", "--level", "build", "fileName"}, addUpdateCommand)
+
+	assert.Equal(t, ExitInvalidParams, exitCode)
+}
+
+func TestUpdateVariantLevels(t *testing.T) {
+	for variantLevel, expected := range map[string]string{
+		"none":  "node:18.18-alpine3.17",
+		"minor": "node:18.18-alpine3.18",
+		"major": "node:18.18-alpine4.0",
+	} {
+		t.Run(variantLevel, func(t *testing.T) {
+			df1 := dockerfile("FROM node:18-alpine3.17")
+			defer os.Remove(df1)
+
+			os.Args = []string{"exe", "update", "--variant-level", variantLevel, "--no-digest", df1}
+			mainOptions := mainOptionsACNew(addUpdateCommand)
+
+			repo := mainOptions.resolverFactory()().(*dockreftst.MockResolver)
+			repo.OnListTags(mock.Anything).Return([]string{"18-alpine3.17", "18.18-alpine3.17", "18.18-alpine3.18", "18.18-alpine4.0", "18.18-alpine"}, nil)
+			repo.OnResolve(dockref.MustParse(expected)).Return([]dockref.Reference{
+				dockref.MustParse(expected + "@" + updateTestDigestA),
+			}, nil)
+
+			exitCode := doMain(mainOptions)
+
+			assert.Equal(t, ExitSuccess, exitCode)
+
+			fileBytes, e := ioutil.ReadFile(df1)
+			assert.Nil(t, e)
+			assert.Equal(t, "FROM "+expected, string(fileBytes))
+		})
+	}
+}
@@ -1,9 +1,11 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"github.com/MeneDev/dockmoor/dockfmt"
 	"github.com/MeneDev/dockmoor/dockproc"
+	"github.com/MeneDev/dockmoor/dockref"
 	"github.com/hashicorp/go-multierror"
 	"github.com/jessevdk/go-flags"
 	"github.com/pkg/errors"
@@ -108,7 +110,7 @@ type MatchingOptions struct {
 	TagPredicates struct {
 		Untagged bool     `required:"no" long:"untagged" description:"Matches images with no tag"`
 		Latest   bool     `required:"no" long:"latest" description:"Matches images with latest or no tag. References with digest are only matched when explicit latest tag is present."`
-		Outdated bool     `required:"no" long:"outdated" description:"Matches all images with newer versions available" hidden:"true"`
+		Outdated bool     `required:"no" long:"outdated" description:"Matches all images with a newer version of the same variant available. Requires a resolver that can list tags, e.g. --resolver registry"`
 		Tags     []string `required:"no" long:"tag" description:"Matches all images matching one of the specified tag. Surround with '/' for regex i.e. /regex/."`
 	} `group:"Tag Predicates" description:"Limit matched image references depending on their tag"`
 
@@ -233,9 +235,9 @@ var pathsPredicateFactory = func(paths []string) (dockproc.Predicate, error) {
 	return dockproc.PathsPredicateNew(paths)
 }
 
-//var outdatedPredicateFactory = func() (dockproc.Predicate, error) {
-//	return dockproc.OutdatedPredicateNew()
-//}
+var outdatedPredicateFactory = func(ctx context.Context, resolver dockref.Resolver, schemes dockref.TagSchemes, policies dockref.UpdatePolicies) (dockproc.Predicate, error) {
+	return dockproc.OutdatedPredicateNew(ctx, resolver, schemes, policies)
+}
 var untaggedPredicateFactory = func() (dockproc.Predicate, error) {
 	return dockproc.UntaggedPredicateNew()
 }
@@ -249,7 +251,8 @@ var andPredicateFactory = func(predicates []dockproc.Predicate) (dockproc.Predic
 	return dockproc.AndPredicateNew(predicates)
 }
 
-func (mopts *MatchingOptions) getPredicate() (dockproc.Predicate, error) {
+// getPredicate uses ctx for predicates that contact registries, i.e. --outdated
+func (mopts *MatchingOptions) getPredicate(ctx context.Context) (dockproc.Predicate, error) {
 
 	anyPredicate, e := anyPredicateFactory()
 	if e != nil {
@@ -283,10 +286,19 @@ func (mopts *MatchingOptions) getPredicate() (dockproc.Predicate, error) {
 		predicates = append(predicates, p)
 	}
 
-	//if mopts.TagPredicates.Outdated {
-	//	p := outdatedPredicateFactory()
-	//	predicates = append(predicates, p)
-	//}
+	if mopts.TagPredicates.Outdated {
+		schemes, e := mopts.mainOptions().tagSchemes()
+		err = multierror.Append(err, e)
+		policies, e := mopts.mainOptions().updatePolicies()
+		err = multierror.Append(err, e)
+
+		p, e := outdatedPredicateFactory(ctx, mopts.mainOptions().resolverFactory()(), schemes, policies)
+		if e != nil {
+			e = errors.Wrapf(e, "--%s requires a resolver that can list tags, e.g. --resolver registry", outdatedPred)
+		}
+		err = multierror.Append(err, e)
+		predicates = append(predicates, p)
+	}
 
 	if mopts.TagPredicates.Untagged {
 		p, e := untaggedPredicateFactory()
@@ -366,7 +378,8 @@ func (mopts *MatchingOptions) WithFormatProcessorDo(fpInput io.Reader, action fu
 	filename := string(mopts.Positional.InputFile)
 	fileFormat, formatError := dockfmt.IdentifyFormat(log, formatProvider, fpInput, filename)
 
-	if formatError != nil {
+	// the errors of the other formats don't matter when one format accepts the input
+	if fileFormat == nil {
 		return formatError
 	}
 
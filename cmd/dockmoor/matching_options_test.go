@@ -2,9 +2,12 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"github.com/MeneDev/dockmoor/dockproc"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/MeneDev/dockmoor/docktst/dockreftst"
 	"github.com/jessevdk/go-flags"
 	"github.com/stretchr/testify/assert"
 	"reflect"
@@ -218,7 +221,7 @@ func TestDigestPredicateCombinations(t *testing.T) {
 func TestAnyPredicateWhenNoFlagWithContains(t *testing.T) {
 	fo := &MatchingOptions{}
 
-	predicate, e := fo.getPredicate()
+	predicate, e := fo.getPredicate(context.Background())
 	assert.Nil(t, e)
 	predicateNew, e2 := dockproc.AnyPredicateNew()
 	assert.Nil(t, e2)
@@ -229,7 +232,7 @@ func TestDomainsPredicateWhenDomainsSet(t *testing.T) {
 	fo := &MatchingOptions{}
 	fo.DomainPredicates.Domains = []string{"a", "b"}
 
-	predicate, e := fo.getPredicate()
+	predicate, e := fo.getPredicate(context.Background())
 	assert.Nil(t, e)
 
 	expected, e := dockproc.DomainsPredicateNew(nil)
@@ -241,7 +244,7 @@ func TestNamesPredicateWhenNamesSet(t *testing.T) {
 	fo := &MatchingOptions{}
 	fo.NamePredicates.Names = []string{"a", "b"}
 
-	predicate, e := fo.getPredicate()
+	predicate, e := fo.getPredicate(context.Background())
 	assert.Nil(t, e)
 
 	expected, e := dockproc.NamesPredicateNew([]string{"a", "b"})
@@ -253,7 +256,7 @@ func TestFamiliarNamePredicateWhenFamiliarNameSet(t *testing.T) {
 	fo := &MatchingOptions{}
 	fo.NamePredicates.FamiliarNames = []string{"a", "b"}
 
-	predicate, e := fo.getPredicate()
+	predicate, e := fo.getPredicate(context.Background())
 	assert.Nil(t, e)
 
 	expected, e := dockproc.FamiliarNamesPredicateNew(nil)
@@ -265,7 +268,7 @@ func TestPathsPredicateWhenPathsSet(t *testing.T) {
 	fo := &MatchingOptions{}
 	fo.NamePredicates.Paths = []string{"a", "b"}
 
-	predicate, e := fo.getPredicate()
+	predicate, e := fo.getPredicate(context.Background())
 	assert.Nil(t, e)
 
 	expected, e := dockproc.PathsPredicateNew(nil)
@@ -277,7 +280,7 @@ func TestTagsPredicateWhenTagsSet(t *testing.T) {
 	fo := &MatchingOptions{}
 	fo.TagPredicates.Tags = []string{"a", "b"}
 
-	predicate, e := fo.getPredicate()
+	predicate, e := fo.getPredicate(context.Background())
 	assert.Nil(t, e)
 
 	expected, e := dockproc.TagsPredicateNew([]string{"a", "b"})
@@ -289,7 +292,7 @@ func TestUntaggedPredicateWhenUntaggedSet(t *testing.T) {
 	fo := &MatchingOptions{}
 	fo.TagPredicates.Untagged = true
 
-	predicate, e := fo.getPredicate()
+	predicate, e := fo.getPredicate(context.Background())
 	assert.Nil(t, e)
 
 	expected, e := dockproc.UntaggedPredicateNew()
@@ -297,20 +300,45 @@ func TestUntaggedPredicateWhenUntaggedSet(t *testing.T) {
 	assert.IsType(t, expected, predicate)
 }
 
-//func TestOutdatedPredicateWhenOutdatedSet(t *testing.T) {
-//	fo := &MatchingOptions{}
-//	fo.TagPredicates.Outdated = true
-//
-//	predicate := fo.getPredicate()
-//
-//	assert.IsType(t, dockproc.OutdatedPredicateNew(), predicate)
-//}
+func TestOutdatedPredicateWhenOutdatedSet(t *testing.T) {
+	mainOptions := mainOptionsTestNew().mainOptions
+	mainOptions.resolverFactory = func() func() dockref.Resolver {
+		return func() dockref.Resolver {
+			return dockreftst.MockResolverNew()
+		}
+	}
+	fo := &MatchingOptions{mainOpts: mainOptions}
+	fo.TagPredicates.Outdated = true
+
+	predicate, e := fo.getPredicate(context.Background())
+	assert.Nil(t, e)
+
+	expected, e := dockproc.OutdatedPredicateNew(context.Background(), dockreftst.MockResolverNew(), nil, nil)
+	assert.Nil(t, e)
+	assert.IsType(t, expected, predicate)
+}
+
+func TestOutdatedPredicateRequiresTagLister(t *testing.T) {
+	mainOptions := mainOptionsTestNew().mainOptions
+	mainOptions.resolverFactory = func() func() dockref.Resolver {
+		return func() dockref.Resolver {
+			return dockref.LockResolverNew("")
+		}
+	}
+	fo := &MatchingOptions{mainOpts: mainOptions}
+	fo.TagPredicates.Outdated = true
+
+	_, e := fo.getPredicate(context.Background())
+	if assert.Error(t, e) {
+		assert.Contains(t, e.Error(), "--outdated requires a resolver that can list tags")
+	}
+}
 
 func TestLatestPredicateWhenLatestSet(t *testing.T) {
 	fo := &MatchingOptions{}
 	fo.TagPredicates.Latest = true
 
-	predicate, e := fo.getPredicate()
+	predicate, e := fo.getPredicate(context.Background())
 	assert.Nil(t, e)
 
 	expected, e := dockproc.LatestPredicateNew()
@@ -322,7 +350,7 @@ func TestDigestsPredicateWhenDomainSet(t *testing.T) {
 	fo := &MatchingOptions{}
 	fo.DigestPredicates.Digests = []string{"a", "b"}
 
-	predicate, e := fo.getPredicate()
+	predicate, e := fo.getPredicate(context.Background())
 	assert.Nil(t, e)
 
 	expected, e := dockproc.DigestsPredicateNew([]string{"a", "b"})
@@ -334,7 +362,7 @@ func TestUnpinnedPredicateWhenUnpinnedSet(t *testing.T) {
 	fo := &MatchingOptions{}
 	fo.DigestPredicates.Unpinned = true
 
-	predicate, e := fo.getPredicate()
+	predicate, e := fo.getPredicate(context.Background())
 	assert.Nil(t, e)
 
 	expected, e := dockproc.UnpinnedPredicateNew()
@@ -347,7 +375,7 @@ func TestAndPredicateWhenUnpinnedAndLatestSet(t *testing.T) {
 	fo.DigestPredicates.Unpinned = true
 	fo.TagPredicates.Latest = true
 
-	predicate, e := fo.getPredicate()
+	predicate, e := fo.getPredicate(context.Background())
 	assert.Nil(t, e)
 
 	expected, e := dockproc.AndPredicateNew(nil)
@@ -379,7 +407,7 @@ func TestAndPredicateWhenUnpinnedAndLatestSet(t *testing.T) {
 	assert.Equal(t, 2, matches)
 }
 
-var unimplemented = []string{outdatedPred}
+var unimplemented = []string{}
 
 func TestHelpContainsImplementedPredicates(t *testing.T) {
 	mo := MatchingOptions{}
@@ -407,7 +435,7 @@ func TestMatchingOptions_matchAndProcessFormatProcessorReturnsErrorForErrorIn(t
 	}
 
 	mo.NamePredicates.Names = []string{"/a(b/"}
-	predicate, e := mo.getPredicate()
+	predicate, e := mo.getPredicate(context.Background())
 	assert.Error(t, e)
 	assert.Nil(t, predicate)
 }
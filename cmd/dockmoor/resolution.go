@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockproc"
+	"github.com/MeneDev/dockmoor/dockref"
+	"strings"
+)
+
+// resolutions are the prefetched results of a resolution phase, keyed by the requests
+type resolutions map[string]dockref.Resolution
+
+// retarget chooses the reference that is resolved instead of a matching reference, e.g. a newer tag
+type retarget func(ctx context.Context, original dockref.Reference) (dockref.Reference, error)
+
+// sameReference resolves the matching references themselves
+func sameReference(ctx context.Context, original dockref.Reference) (dockref.Reference, error) {
+	return original, nil
+}
+
+// resolveMatching collects the matching references of the processor and resolves their targets concurrently.
+// The processor must be able to process its input again, e.g. to write the pinned references.
+func resolveMatching(ctx context.Context, options *mainOptions, predicate dockproc.Predicate, processor dockfmt.FormatProcessor, resolver dockref.Resolver, target retarget) (resolutions, error) {
+	requests := make([]dockref.ResolveRequest, 0)
+
+	err := processor.ProcessOccurrences(func(original dockref.Reference, occurrence dockfmt.Occurrence) (dockref.Reference, error) {
+		if predicate.Matches(original) {
+			reference, err := target(ctx, original)
+			if err != nil {
+				return nil, err
+			}
+
+			request, err := options.resolveRequest(reference, occurrence, dockref.CanResolvePlatform(resolver))
+			if err != nil {
+				return nil, err
+			}
+			requests = append(requests, request)
+		}
+		return original, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return dockref.ResolveAllRequests(ctx, resolver, requests, options.Jobs), nil
+}
+
+// resolveRequest uses the platform requested by the input, e.g. FROM --platform=linux/arm64, or else the --platform option.
+// Platforms that refer to build arguments like $TARGETPLATFORM are unknown before the build and therefore ignored.
+// When the resolver doesn't support platforms, the platform of the input is ignored with a warning and the index digest is pinned,
+// only the --platform option fails.
+func (options *mainOptions) resolveRequest(original dockref.Reference, occurrence dockfmt.Occurrence, platforms bool) (dockref.ResolveRequest, error) {
+	request := dockref.ResolveRequest{Reference: original}
+
+	platform := options.Platform
+	if occurrence.Platform != "" && !strings.Contains(occurrence.Platform, "$") {
+		if platforms || options.Platform != "" {
+			platform = occurrence.Platform
+		} else {
+			options.Log().Warnf("Ignoring platform %s of %s, the resolver does not support platforms", occurrence.Platform, original.Original())
+		}
+	}
+
+	if platform == "" {
+		return request, nil
+	}
+
+	var err error
+	request.Platform, err = dockref.ParsePlatform(platform)
+	return request, err
+}
+
+// resolve uses the prefetched result and falls back to the resolver for requests that were not prefetched
+func (r resolutions) resolve(ctx context.Context, repoFactory func() dockref.Resolver, request dockref.ResolveRequest) ([]dockref.Reference, error) {
+	if resolution, ok := r[request.Key()]; ok {
+		return resolution.References, resolution.Err
+	}
+
+	return dockref.ResolveForPlatform(ctx, repoFactory(), request.Reference, request.Platform)
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/MeneDev/dockmoor/dockproc"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"path/filepath"
+)
+
+// referencePatterns use the same patterns as the predicates, surround with '/' for regex
+type referencePatterns struct {
+	Domains       []string `json:"domains,omitempty"`
+	Names         []string `json:"names,omitempty"`
+	FamiliarNames []string `json:"familiar-names,omitempty"`
+	Paths         []string `json:"paths,omitempty"`
+}
+
+type resolverRouteConfig struct {
+	referencePatterns
+	Resolvers []string `json:"resolvers"`
+}
+
+// registryConfig is the configuration of a single registry, relative paths are relative to the configuration file
+type registryConfig struct {
+	CAFiles    []string `json:"ca,omitempty"`
+	ClientCert string   `json:"cert,omitempty"`
+	ClientKey  string   `json:"key,omitempty"`
+	Proxy      string   `json:"proxy,omitempty"`
+	Insecure   bool     `json:"insecure,omitempty"`
+	PlainHTTP  bool     `json:"plain-http,omitempty"`
+}
+
+type resolverConfig struct {
+	Routes []resolverRouteConfig `json:"routes"`
+	// Mirrors maps domains to the endpoints of mirrors used by the registry resolver, e.g. "docker.io": "mirror.corp:5000"
+	Mirrors map[string]string `json:"mirrors,omitempty"`
+	// Registries configure certificates, proxies and insecure access of the registry resolver by registry domain
+	Registries map[string]registryConfig `json:"registries,omitempty"`
+}
+
+var readResolverConfigFile = ioutil.ReadFile
+
+func readResolverConfig(filename string) (resolverConfig, error) {
+	var config resolverConfig
+
+	content, err := readResolverConfigFile(filename)
+	if err != nil {
+		return config, err
+	}
+
+	err = json.Unmarshal(content, &config)
+	if err != nil {
+		return config, errors.Wrapf(err, "invalid resolver configuration %s", filename)
+	}
+
+	if len(config.Routes) == 0 {
+		if len(config.Mirrors) == 0 && len(config.Registries) == 0 {
+			return config, errors.Errorf("no routes in resolver configuration %s", filename)
+		}
+		// mirrors and registries alone configure the registry resolver for all references
+		config.Routes = []resolverRouteConfig{{Resolvers: []string{"registry"}}}
+	}
+
+	return config, nil
+}
+
+// withFlags converts the configuration and keeps insecure and plain HTTP access enabled by command line flags
+func (config registryConfig) withFlags(flags dockref.RegistryConfig, dir string) dockref.RegistryConfig {
+	path := func(file string) string {
+		if file == "" || filepath.IsAbs(file) {
+			return file
+		}
+		return filepath.Join(dir, file)
+	}
+
+	result := dockref.RegistryConfig{
+		ClientCert: path(config.ClientCert),
+		ClientKey:  path(config.ClientKey),
+		Proxy:      config.Proxy,
+		Insecure:   config.Insecure || flags.Insecure,
+		PlainHTTP:  config.PlainHTTP || flags.PlainHTTP,
+	}
+	for _, caFile := range config.CAFiles {
+		result.CAFiles = append(result.CAFiles, path(caFile))
+	}
+	return result
+}
+
+// matcher returns nil when there are no patterns and thus all references match
+func (patterns referencePatterns) matcher() (dockref.ReferenceMatcher, error) {
+	var predicates []dockproc.Predicate
+
+	add := func(values []string, factory func([]string) (dockproc.Predicate, error)) error {
+		if values == nil {
+			return nil
+		}
+		p, e := factory(values)
+		predicates = append(predicates, p)
+		return e
+	}
+
+	if e := add(patterns.Domains, domainsPredicateFactory); e != nil {
+		return nil, e
+	}
+	if e := add(patterns.Names, namePredicateFactory); e != nil {
+		return nil, e
+	}
+	if e := add(patterns.FamiliarNames, familiarNamePredicateFactory); e != nil {
+		return nil, e
+	}
+	if e := add(patterns.Paths, pathsPredicateFactory); e != nil {
+		return nil, e
+	}
+
+	switch len(predicates) {
+	case 0:
+		return nil, nil
+	case 1:
+		return predicates[0], nil
+	default:
+		return andPredicateFactory(predicates)
+	}
+}
+
+func (options *mainOptions) configuredResolver(filename string) (dockref.Resolver, error) {
+	config, err := readResolverConfig(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	registryOptions, err := options.registryOptions()
+	if err != nil {
+		return nil, err
+	}
+	registryOptions.Mirrors = config.Mirrors
+	for domain, registryConfig := range config.Registries {
+		registryOptions.Registries[domain] = registryConfig.withFlags(registryOptions.Registries[domain], filepath.Dir(filename))
+	}
+	registry, err := dockref.RegistryResolverWithOptionsNew(registryOptions)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid mirrors or registries in %s", filename)
+	}
+	// all routes share the registry resolver and thus its authorizations
+	registry = options.withCache(options.registryCacheNamespace(registryOptions), registry)
+
+	routes := make([]dockref.ResolverRoute, 0, len(config.Routes))
+	for i, routeConfig := range config.Routes {
+		matcher, err := routeConfig.matcher()
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid pattern in route %d of %s", i+1, filename)
+		}
+
+		if len(routeConfig.Resolvers) == 0 {
+			return nil, errors.Errorf("no resolvers in route %d of %s", i+1, filename)
+		}
+
+		route := dockref.ResolverRoute{Matcher: matcher}
+		for _, name := range routeConfig.Resolvers {
+			if err := verifyResolverName(name); err != nil {
+				return nil, errors.Wrapf(err, "invalid resolver in route %d of %s", i+1, filename)
+			}
+			if name == "registry" {
+				route.Resolvers = append(route.Resolvers, registry)
+			} else {
+				route.Resolvers = append(route.Resolvers, options.resolverNamed(name))
+			}
+		}
+
+		routes = append(routes, route)
+	}
+
+	return dockref.CompositeResolverNew(routes), nil
+}
+
+// failingResolver reports configuration errors on use, resolver factories cannot return errors
+type failingResolver struct {
+	err error
+}
+
+func (r failingResolver) Resolve(ctx context.Context, reference dockref.Reference) ([]dockref.Reference, error) {
+	return nil, r.err
+}
+
+func (r failingResolver) ResolvePlatform(ctx context.Context, reference dockref.Reference, platform dockref.Platform) ([]dockref.Reference, error) {
+	return nil, r.err
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func resolverConfigFile(content string) (fileName string) {
+	return dockerfile(content)
+}
+
+func TestResolverConfig_RoutesReferences(t *testing.T) {
+	archive := tmpFile().Name()
+	defer os.Remove(archive)
+
+	config := resolverConfigFile(`{
+		"routes": [
+			{"domains": ["localhost:5000"], "resolvers": ["dockerd"]},
+			{"domains": ["/^quay\\.io$/"], "familiar-names": ["/^quay.io/coreos/.*/"], "resolvers": ["registry"]},
+			{"resolvers": ["lock", "docker-archive:` + archive + `"]}
+		]
+	}`)
+	defer os.Remove(config)
+
+	mainOptions := mainOptionsTestNew().mainOptions
+	mainOptions.Cache.NoCache = true
+
+	resolver, err := mainOptions.configuredResolver(config)
+	assert.Nil(t, err)
+	assert.IsType(t, dockref.CompositeResolverNew(nil), resolver)
+}
+
+func TestResolverConfig_FallsBackToNextResolver(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dockmoor-config")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	lock := dockref.LockNew()
+	lock.Add(dockref.LockEntry{File: "Dockerfile", Line: 1, Original: "nginx", Name: "docker.io/library/nginx", Tag: "1.15.6", Digest: "sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf"})
+	lockFile := filepath.Join(dir, "dockmoor.lock")
+	assert.Nil(t, lock.WriteFile(lockFile))
+	emptyLayout := filepath.Join(dir, "oci")
+	assert.Nil(t, os.Mkdir(emptyLayout, 0755))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(emptyLayout, "oci-layout"), []byte(`{"imageLayoutVersion": "1.0.0"}`), 0644))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(emptyLayout, "index.json"), []byte(`{"schemaVersion": 2, "manifests": []}`), 0644))
+
+	config := resolverConfigFile(`{"routes": [{"names": ["docker.io/library/nginx"], "resolvers": ["oci:` + emptyLayout + `", "lock"]}]}`)
+	defer os.Remove(config)
+
+	mainOptions := mainOptionsTestNew().mainOptions
+	mainOptions.LockFile = lockFile
+
+	resolver, err := mainOptions.configuredResolver(config)
+	assert.Nil(t, err)
+
+	refs, err := resolver.Resolve(context.Background(), dockref.MustParse("nginx"))
+	assert.Nil(t, err)
+	if assert.Len(t, refs, 1) {
+		assert.Equal(t, "1.15.6", refs[0].Tag())
+	}
+
+	_, err = resolver.Resolve(context.Background(), dockref.MustParse("alpine"))
+	assert.True(t, dockref.IsNotFound(err))
+}
+
+func TestResolverConfig_MirrorsWithoutRoutesUseRegistry(t *testing.T) {
+	config := resolverConfigFile(`{"mirrors": {"docker.io": "mirror.corp:5000"}}`)
+	defer os.Remove(config)
+
+	parsed, err := readResolverConfig(config)
+	assert.Nil(t, err)
+	assert.Equal(t, []resolverRouteConfig{{Resolvers: []string{"registry"}}}, parsed.Routes)
+
+	mainOptions := mainOptionsTestNew().mainOptions
+	resolver, err := mainOptions.configuredResolver(config)
+	assert.Nil(t, err)
+	assert.NotNil(t, resolver)
+}
+
+func TestResolverConfig_Registries(t *testing.T) {
+	config := resolverConfigFile(`{"registries": {"registry.corp": {"ca": ["certs/ca.pem", "/etc/ssl/corp.pem"], "cert": "certs/client.cert", "key": "certs/client.key", "proxy": "http://proxy.corp:3128", "plain-http": true}}}`)
+	defer os.Remove(config)
+
+	parsed, err := readResolverConfig(config)
+	assert.Nil(t, err)
+	assert.Equal(t, []resolverRouteConfig{{Resolvers: []string{"registry"}}}, parsed.Routes)
+
+	dir := filepath.Dir(config)
+	assert.Equal(t, dockref.RegistryConfig{
+		CAFiles:    []string{filepath.Join(dir, "certs/ca.pem"), "/etc/ssl/corp.pem"},
+		ClientCert: filepath.Join(dir, "certs/client.cert"),
+		ClientKey:  filepath.Join(dir, "certs/client.key"),
+		Proxy:      "http://proxy.corp:3128",
+		Insecure:   true,
+		PlainHTTP:  true,
+	}, parsed.Registries["registry.corp"].withFlags(dockref.RegistryConfig{Insecure: true}, dir))
+}
+
+func TestResolverConfig_Invalid(t *testing.T) {
+	for content, expected := range map[string]string{
+		`{ invalid`:                              "invalid resolver configuration",
+		`{"routes": []}`:                         "no routes in resolver configuration",
+		`{"routes": [{"domains": ["quay.io"]}]}`: "no resolvers in route 1",
+		`{"routes": [{"domains": ["/(/"], "resolvers": ["dockerd"]}]}`:  "invalid pattern in route 1",
+		`{"routes": [{"resolvers": ["dockerd", "config:other.json"]}]}`: "invalid resolver in route 1",
+		`{"mirrors": {"docker.io": "ftp://mirror.corp"}}`:               "invalid mirrors",
+		`{"registries": {"registry.corp": {"ca": ["missing.pem"]}}}`:    "invalid mirrors or registries",
+	} {
+		config := resolverConfigFile(content)
+
+		_, err := mainOptionsTestNew().mainOptions.configuredResolver(config)
+		if assert.Error(t, err, content) {
+			assert.Contains(t, err.Error(), expected, content)
+		}
+
+		os.Remove(config)
+	}
+}
+
+func TestUsesConfiguredSolver(t *testing.T) {
+	config := resolverConfigFile(`{"routes": [{"resolvers": ["lock"]}]}`)
+	defer os.Remove(config)
+
+	cmd, _, exitCode, _ := testMain([]string{"--resolver", "config:" + config, "pin", "fileNameIn"}, addPinCommand)
+
+	po, _ := cmd.(*pinOptions)
+	assert.Equal(t, ExitSuccess, exitCode)
+	assert.IsType(t, dockref.CompositeResolverNew(nil), po.mainOptions().resolverFactory()())
+}
+
+func TestInvalidConfiguredSolverIsReported(t *testing.T) {
+	_, _, exitCode, buf := testMain([]string{"--resolver", "config:does-not-exist.json", "pin", "fileNameIn"}, addPinCommand)
+
+	assert.Equal(t, ExitInvalidParams, exitCode)
+	assert.Contains(t, buf.String(), "does-not-exist.json")
+}
+
+func TestInvalidConfiguredSolverFailsOnUse(t *testing.T) {
+	mainOptions := mainOptionsTestNew().mainOptions
+	mainOptions.Resolver = "config:does-not-exist.json"
+
+	resolver := mainOptions.resolverFactory()()
+	_, err := resolver.Resolve(context.Background(), dockref.MustParse("nginx"))
+	assert.Error(t, err)
+}
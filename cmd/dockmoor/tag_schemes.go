@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/pkg/errors"
+	"io/ioutil"
+)
+
+type tagSchemeConfig struct {
+	referencePatterns
+	Name string `json:"name"`
+	// Pattern has the named groups version and optionally variant and build
+	Pattern string `json:"pattern"`
+}
+
+type tagSchemesConfig struct {
+	// Schemes are applied to references in order, the first matching scheme wins
+	Schemes []tagSchemeConfig `json:"schemes"`
+}
+
+var readTagSchemesFile = ioutil.ReadFile
+
+func readTagSchemes(filename string) (dockref.TagSchemes, error) {
+	content, err := readTagSchemesFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var config tagSchemesConfig
+	if err := json.Unmarshal(content, &config); err != nil {
+		return nil, errors.Wrapf(err, "invalid tag schemes %s", filename)
+	}
+
+	schemes := make(dockref.TagSchemes, 0, len(config.Schemes))
+	for i, schemeConfig := range config.Schemes {
+		matcher, err := schemeConfig.matcher()
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid pattern in scheme %d of %s", i+1, filename)
+		}
+
+		scheme, err := dockref.TagSchemeNew(schemeConfig.Name, schemeConfig.Pattern)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid scheme %d of %s", i+1, filename)
+		}
+
+		schemes = append(schemes, dockref.TagSchemeRule{Matcher: matcher, Scheme: scheme})
+	}
+
+	return schemes, nil
+}
+
+// tagSchemes reads the file of --tag-schemes, without it all tags are split at the first hyphen
+func (options *mainOptions) tagSchemes() (dockref.TagSchemes, error) {
+	if options.TagSchemes == "" {
+		return nil, nil
+	}
+	return readTagSchemes(options.TagSchemes)
+}
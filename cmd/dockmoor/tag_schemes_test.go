@@ -0,0 +1,81 @@
+package main
+
+import (
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/MeneDev/dockmoor/docktst/dockreftst"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+const temurinSchemes = `{
+	"schemes": [
+		{"name": "temurin", "familiar-names": ["eclipse-temurin"], "pattern": "^(?P<version>[0-9]+(\\.[0-9]+)*)(_(?P<build>[0-9]+))?-(?P<variant>.+)$"}
+	]
+}`
+
+func tagSchemesFile(content string) (fileName string) {
+	return dockerfile(content)
+}
+
+func TestReadTagSchemes(t *testing.T) {
+	file := tagSchemesFile(temurinSchemes)
+	defer os.Remove(file)
+
+	schemes, err := readTagSchemes(file)
+	assert.Nil(t, err)
+
+	assert.Equal(t, "temurin", schemes.For(dockref.MustParse("eclipse-temurin:17-jdk-jammy")).Name)
+	assert.Equal(t, dockref.DefaultTagScheme, schemes.For(dockref.MustParse("nginx:1.15")))
+}
+
+func TestReadTagSchemes_Invalid(t *testing.T) {
+	for name, content := range map[string]string{
+		"no json":          `schemes`,
+		"invalid regex":    `{"schemes": [{"name": "broken", "pattern": "(?P<version>"}]}`,
+		"no version group": `{"schemes": [{"name": "unnamed", "pattern": "^([0-9.]+)$"}]}`,
+		"invalid name":     `{"schemes": [{"name": "a", "names": ["/a(b/"], "pattern": "(?P<version>.*)"}]}`,
+	} {
+		file := tagSchemesFile(content)
+		_, err := readTagSchemes(file)
+		assert.Error(t, err, name)
+		os.Remove(file)
+	}
+}
+
+func TestInvalidTagSchemesAreReported(t *testing.T) {
+	file := tagSchemesFile(`{"schemes": [{"name": "unnamed", "pattern": "^([0-9.]+)$"}]}`)
+	defer os.Remove(file)
+
+	_, _, exitCode, stdout := testMain([]string{"pin", "--tag-schemes", file, "fileName"}, addPinCommand)
+
+	assert.Equal(t, ExitInvalidParams, exitCode)
+	assert.Contains(t, stdout.String(), "no group named version")
+}
+
+func TestUpdateUsesTagSchemes(t *testing.T) {
+	file := tagSchemesFile(temurinSchemes)
+	defer os.Remove(file)
+	df1 := dockerfile("FROM eclipse-temurin:17.0.8_7-jdk-jammy")
+	defer os.Remove(df1)
+
+	os.Args = []string{"exe", "update", "--tag-schemes", file, "--no-digest", df1}
+	mainOptions := mainOptionsACNew(addUpdateCommand)
+
+	repo := mainOptions.resolverFactory()().(*dockreftst.MockResolver)
+	repo.OnListTags(mock.Anything).Return([]string{"17-jdk-jammy", "17.0.8_7-jdk-jammy", "17.0.9_9-jdk-jammy", "17.0.9_9-jre-jammy", "21.0.1_12-jdk-jammy"}, nil)
+	repo.OnResolve(dockref.MustParse("eclipse-temurin:17.0.9_9-jdk-jammy")).Return([]dockref.Reference{
+		dockref.MustParse("eclipse-temurin:17-jdk-jammy@" + updateTestDigestA),
+		dockref.MustParse("eclipse-temurin:17.0.9_9-jdk-jammy@" + updateTestDigestA),
+	}, nil)
+
+	exitCode := doMain(mainOptions)
+
+	assert.Equal(t, ExitSuccess, exitCode)
+
+	fileBytes, e := ioutil.ReadFile(df1)
+	assert.Nil(t, e)
+	assert.Equal(t, "FROM eclipse-temurin:17.0.9_9-jdk-jammy", string(fileBytes))
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"time"
+)
+
+type updatePolicyRuleConfig struct {
+	referencePatterns
+	// Allow lists semver ranges or regular expressions of tags, versions must match one of them
+	Allow []string `json:"allow,omitempty"`
+	// Ignore lists versions, ranges or regular expressions of tags that are never chosen
+	Ignore []string `json:"ignore,omitempty"`
+	// Prerelease opts in to tags like 1.16.0-rc1
+	Prerelease bool `json:"prerelease,omitempty"`
+	// MinAge is a duration like 72h, newer images are not chosen
+	MinAge string `json:"min-age,omitempty"`
+	// VariantLevel is none, patch, minor or major and limits versioned variants like alpine3.18
+	VariantLevel string `json:"variant-level,omitempty"`
+}
+
+type updatePolicyConfig struct {
+	// Rules are applied to references in order, the first matching rule wins
+	Rules []updatePolicyRuleConfig `json:"rules"`
+}
+
+var readUpdatePolicyFile = ioutil.ReadFile
+
+func readUpdatePolicies(filename string) (dockref.UpdatePolicies, error) {
+	content, err := readUpdatePolicyFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var config updatePolicyConfig
+	if err := json.Unmarshal(content, &config); err != nil {
+		return nil, errors.Wrapf(err, "invalid update policy %s", filename)
+	}
+
+	policies := make(dockref.UpdatePolicies, 0, len(config.Rules))
+	for i, ruleConfig := range config.Rules {
+		rule, err := ruleConfig.rule()
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid rule %d in update policy %s", i+1, filename)
+		}
+		policies = append(policies, rule)
+	}
+
+	return policies, nil
+}
+
+func (config updatePolicyRuleConfig) rule() (dockref.UpdatePolicyRule, error) {
+	matcher, err := config.matcher()
+	if err != nil {
+		return dockref.UpdatePolicyRule{}, err
+	}
+
+	policy := dockref.UpdatePolicy{
+		Allow:      config.Allow,
+		Ignore:     config.Ignore,
+		Prerelease: config.Prerelease,
+	}
+	if config.MinAge != "" {
+		policy.MinAge, err = time.ParseDuration(config.MinAge)
+		if err != nil {
+			return dockref.UpdatePolicyRule{}, errors.Wrap(err, "invalid min-age")
+		}
+	}
+
+	if config.VariantLevel != "" {
+		level, err := dockref.ParseVariantLevel(config.VariantLevel)
+		if err != nil {
+			return dockref.UpdatePolicyRule{}, err
+		}
+		policy.VariantLevel = &level
+	}
+
+	if err := policy.Validate(); err != nil {
+		return dockref.UpdatePolicyRule{}, err
+	}
+
+	return dockref.UpdatePolicyRule{Matcher: matcher, Policy: policy}, nil
+}
+
+// updatePolicies reads the file of --policy, without it there are no restrictions
+func (options *mainOptions) updatePolicies() (dockref.UpdatePolicies, error) {
+	if options.Policy == "" {
+		return nil, nil
+	}
+	return readUpdatePolicies(options.Policy)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/MeneDev/dockmoor/docktst/dockreftst"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func updatePolicyFile(content string) (fileName string) {
+	return dockerfile(content)
+}
+
+func TestReadUpdatePolicies(t *testing.T) {
+	policy := updatePolicyFile(`{
+		"rules": [
+			{"familiar-names": ["postgres"], "allow": [">=13.0.0 <14.0.0"], "ignore": ["13.3.0"]},
+			{"domains": ["/^quay\\.io$/"], "prerelease": true, "min-age": "72h"},
+			{"allow": ["/^[0-9]*[02468]\\./"]},
+			{"familiar-names": ["node"], "variant-level": "none"}
+		]
+	}`)
+	defer os.Remove(policy)
+
+	policies, err := readUpdatePolicies(policy)
+	assert.Nil(t, err)
+
+	assert.Equal(t, dockref.UpdatePolicy{Allow: []string{">=13.0.0 <14.0.0"}, Ignore: []string{"13.3.0"}}, policies.For(dockref.MustParse("postgres:13.1")))
+	assert.Equal(t, dockref.UpdatePolicy{Prerelease: true, MinAge: 72 * time.Hour}, policies.For(dockref.MustParse("quay.io/coreos/etcd:v3.3")))
+	assert.Equal(t, dockref.UpdatePolicy{Allow: []string{`/^[0-9]*[02468]\./`}}, policies.For(dockref.MustParse("node:14.17")))
+
+	none := dockref.UpdateNone
+	policies = policies[len(policies)-1:]
+	assert.Equal(t, dockref.UpdatePolicy{VariantLevel: &none}, policies.For(dockref.MustParse("node:18-alpine3.17")))
+}
+
+func TestReadUpdatePolicies_Invalid(t *testing.T) {
+	for name, content := range map[string]string{
+		"no json":       `rules`,
+		"invalid range": `{"rules": [{"allow": [">=13"]}]}`,
+		"invalid regex": `{"rules": [{"ignore": ["/a(b/"]}]}`,
+		"invalid age":   `{"rules": [{"min-age": "3 days"}]}`,
+		"invalid level": `{"rules": [{"variant-level": "build"}]}`,
+		"invalid name":  `{"rules": [{"names": ["/a(b/"]}]}`,
+	} {
+		policy := updatePolicyFile(content)
+		_, err := readUpdatePolicies(policy)
+		assert.Error(t, err, name)
+		os.Remove(policy)
+	}
+}
+
+func TestInvalidUpdatePolicyIsReported(t *testing.T) {
+	policy := updatePolicyFile(`{"rules": [{"allow": [">=13"]}]}`)
+	defer os.Remove(policy)
+
+	_, _, exitCode, stdout := testMain([]string{"update", "--policy", policy, "fileName"}, addUpdateCommand)
+
+	assert.Equal(t, ExitInvalidParams, exitCode)
+	assert.Contains(t, stdout.String(), "invalid rule 1 in update policy")
+}
+
+func TestUpdateFollowsPolicy(t *testing.T) {
+	policy := updatePolicyFile(`{"rules": [{"familiar-names": ["postgres"], "allow": [">=13.0.0 <14.0.0"], "ignore": ["13.3.0"]}]}`)
+	defer os.Remove(policy)
+	df1 := dockerfile("FROM postgres:13.1")
+	defer os.Remove(df1)
+
+	os.Args = []string{"exe", "update", "--level", "major", "--policy", policy, "--no-digest", df1}
+	mainOptions := mainOptionsACNew(addUpdateCommand)
+
+	repo := mainOptions.resolverFactory()().(*dockreftst.MockResolver)
+	repo.OnListTags(mock.Anything).Return([]string{"13.1", "13.2", "13.3", "14.0", "14.1-rc1"}, nil)
+	repo.OnResolve(dockref.MustParse("postgres:13.2")).Return([]dockref.Reference{
+		dockref.MustParse("postgres:13.2@" + updateTestDigestA),
+	}, nil)
+
+	exitCode := doMain(mainOptions)
+
+	assert.Equal(t, ExitSuccess, exitCode)
+
+	fileBytes, e := ioutil.ReadFile(df1)
+	assert.Nil(t, e)
+	assert.Equal(t, "FROM postgres:13.2", string(fileBytes))
+}
+
+func TestUpdateWaitsForMinimumAge(t *testing.T) {
+	policy := updatePolicyFile(`{"rules": [{"min-age": "72h"}]}`)
+	defer os.Remove(policy)
+	df1 := dockerfile("FROM nginx:1.15.6")
+	defer os.Remove(df1)
+
+	os.Args = []string{"exe", "update", "--policy", policy, "--no-digest", df1}
+	mainOptions := mainOptionsACNew(addUpdateCommand)
+
+	createdFor := func(tag string) interface{} {
+		return mock.MatchedBy(func(reference dockref.Reference) bool {
+			return reference.Tag() == tag
+		})
+	}
+
+	repo := mainOptions.resolverFactory()().(*dockreftst.MockResolver)
+	repo.OnListTags(mock.Anything).Return(updateTestTags, nil)
+	repo.OnCreationTime(createdFor("1.16.1")).Return(time.Now().Add(-time.Hour), nil)
+	repo.OnCreationTime(createdFor("1.16")).Return(time.Now().Add(-time.Hour), nil)
+	repo.OnCreationTime(createdFor("1.15.7")).Return(time.Now().Add(-96*time.Hour), nil)
+	repo.OnResolve(dockref.MustParse("nginx:1.15.7")).Return([]dockref.Reference{
+		dockref.MustParse("nginx:1.15.7@" + updateTestDigestA),
+	}, nil)
+
+	exitCode := doMain(mainOptions)
+
+	assert.Equal(t, ExitSuccess, exitCode)
+
+	fileBytes, e := ioutil.ReadFile(df1)
+	assert.Nil(t, e)
+	assert.Equal(t, "FROM nginx:1.15.7", string(fileBytes))
+}
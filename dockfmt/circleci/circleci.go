@@ -0,0 +1,163 @@
+package circleci
+
+import (
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockfmt/yamlfmt"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+func init() {
+	dockfmt.RegisterFormat(New())
+}
+
+// ensure Format is implemented
+var _ dockfmt.Format = (*circleCiFormat)(nil)
+var _ dockfmt.OccurrenceFormat = (*circleCiFormat)(nil)
+
+type circleCiFormat struct {
+	document *yamlfmt.Document
+	root     *yaml.Node
+}
+
+func (format *circleCiFormat) Name() string {
+	return "CircleCI"
+}
+
+// New creates the format of .circleci/config.yml files
+func New() dockfmt.Format {
+	return new(circleCiFormat)
+}
+
+func (format *circleCiFormat) ValidateInput(log logrus.FieldLogger, reader io.Reader, filename string) error {
+	err := format.validateInput(log, reader, filename)
+	if err != nil {
+		return dockfmt.FormatErrorNew(err)
+	}
+
+	return nil
+}
+
+func (format *circleCiFormat) validateInput(log logrus.FieldLogger, reader io.Reader, filename string) error {
+	content, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+
+	document, err := yamlfmt.Parse(content)
+	if err != nil {
+		return err
+	}
+
+	if len(document.Roots) != 1 {
+		return errors.Errorf("Expected a single YAML document, found %d", len(document.Roots))
+	}
+
+	root := yamlfmt.Mapping(document.Roots[0])
+	if !isCircleCi(root, filename) {
+		return errors.New("No CircleCI version with jobs, executors or workflows found")
+	}
+
+	format.document = document
+	format.root = root
+
+	return nil
+}
+
+// isCircleCi accepts configurations with a version and jobs, executors or workflows, or any version in .circleci/.
+// The jobs of Travis CI aren't accepted, they aren't a mapping of jobs.
+func isCircleCi(root *yaml.Node, filename string) bool {
+	version := yamlfmt.Value(root, "version")
+	if version == nil || version.Kind != yaml.ScalarNode {
+		return false
+	}
+
+	if filepath.Base(filepath.Dir(filename)) == ".circleci" {
+		return true
+	}
+
+	workflows := yamlfmt.Value(root, "workflows")
+	if workflows != nil && workflows.Kind == yaml.MappingNode {
+		return true
+	}
+	return isMappingOfMappings(yamlfmt.Value(root, "executors")) || isMappingOfMappings(yamlfmt.Value(root, "jobs"))
+}
+
+func isMappingOfMappings(node *yaml.Node) bool {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return false
+	}
+	_, values := yamlfmt.Pairs(node)
+	for _, value := range values {
+		if value == nil || value.Kind != yaml.MappingNode {
+			return false
+		}
+	}
+	return true
+}
+
+func (format *circleCiFormat) Process(log logrus.FieldLogger, reader io.Reader, w io.Writer, imageNameProcessor dockfmt.ImageNameProcessor) error {
+	return format.ProcessOccurrences(log, reader, w, func(r dockref.Reference, occurrence dockfmt.Occurrence) (dockref.Reference, error) {
+		return imageNameProcessor(r)
+	})
+}
+
+func (format *circleCiFormat) ProcessOccurrences(log logrus.FieldLogger, reader io.Reader, w io.Writer, occurrenceProcessor dockfmt.OccurrenceProcessor) error {
+	err := format.process(log, w, occurrenceProcessor)
+	if err != nil {
+		return dockfmt.FormatErrorNew(err)
+	}
+
+	return nil
+}
+
+func (format *circleCiFormat) process(log logrus.FieldLogger, w io.Writer, occurrenceProcessor dockfmt.OccurrenceProcessor) error {
+	err := format.document.ProcessImages(log, format.images(log), occurrenceProcessor)
+	if err != nil {
+		return err
+	}
+
+	_, err = format.document.WriteTo(w)
+	return err
+}
+
+// images returns the docker images of the executors and jobs, also of inline orbs.
+// Orb references like circleci/node@5.0 are no images, images with parameters like << parameters.tag >> are skipped.
+func (format *circleCiFormat) images(log logrus.FieldLogger) []yamlfmt.Image {
+	images := make([]yamlfmt.Image, 0)
+
+	_, orbs := yamlfmt.Pairs(yamlfmt.Value(format.root, "orbs"))
+	for _, root := range append([]*yaml.Node{format.root}, orbs...) {
+		for _, key := range []string{"executors", "jobs"} {
+			_, executors := yamlfmt.Pairs(yamlfmt.Value(root, key))
+			for _, executor := range executors {
+				images = append(images, dockerImages(log, executor)...)
+			}
+		}
+	}
+
+	return images
+}
+
+// dockerImages returns the images of docker: [{image: cimg/go:1.21}, ...]
+func dockerImages(log logrus.FieldLogger, executor *yaml.Node) []yamlfmt.Image {
+	images := make([]yamlfmt.Image, 0)
+	for _, container := range yamlfmt.Items(yamlfmt.Value(executor, "docker")) {
+		image := yamlfmt.Value(container, "image")
+		if image == nil || image.Kind != yaml.ScalarNode {
+			continue
+		}
+		if strings.Contains(image.Value, "<<") {
+			log.Warnf("Skipping image %s in line %d, parameters are not supported", image.Value, image.Line)
+			continue
+		}
+		images = append(images, yamlfmt.Image{Node: image})
+	}
+	return images
+}
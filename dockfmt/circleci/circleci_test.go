@@ -0,0 +1,127 @@
+package circleci
+
+import (
+	"bytes"
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+var log = logrus.New()
+
+func init() {
+	log.SetOutput(bytes.NewBuffer(nil))
+}
+
+const configFile = `version: 2.1
+
+orbs:
+  node: circleci/node@5.0.2
+  inline:
+    executors:
+      default:
+        docker:
+          - image: cimg/base:2023.09
+
+executors:
+  go: &go
+    docker:
+      - image: "cimg/go:1.21" # build image
+        auth:
+          username: $DOCKER_USER
+
+jobs:
+  build:
+    executor: go
+    steps:
+      - checkout
+  test:
+    docker:
+      - image: cimg/node:18.17
+      - image: 'postgres:13'
+        environment:
+          POSTGRES_USER: test
+  parameterized:
+    parameters:
+      tag:
+        type: string
+    docker:
+      - image: cimg/ruby:<< parameters.tag >>
+  machine:
+    machine:
+      image: ubuntu-2204:current
+
+workflows:
+  main:
+    jobs: [build, test]
+`
+
+func TestCircleCiName(t *testing.T) {
+	assert.Equal(t, "CircleCI", New().Name())
+}
+
+func TestCircleCiValidateInput(t *testing.T) {
+	for content, valid := range map[string]bool{
+		configFile: true,
+		"version: 2.1\njobs:\n  build:\n    docker: [{image: nginx}]":        true,
+		"version: 2.1\nexecutors:\n  default:\n    docker: [{image: nginx}]": true,
+		"version: 2\nworkflows:\n  version: 2":                               true,
+		"FROM nginx":                                                         false,
+		"":                                                                   false,
+		"jobs:\n  build:\n    docker: [{image: nginx}]":                      false,
+		"version: ~> 1.0\nlanguage: go\njobs:\n  include:\n    - go: 1.21":             false,
+		"version: \"3.8\"\nservices:\n  web:\n    image: nginx":                        false,
+		"version: 2.1\njobs:\n  build:\n    docker: [{image: nginx}]\n---\nversion: 2": false,
+		"version: [": false,
+	} {
+		err := New().ValidateInput(log, strings.NewReader(content), "ci.yml")
+		if valid {
+			assert.Nil(t, err, content)
+		} else {
+			assert.Error(t, err, content)
+			assert.IsType(t, dockfmt.FormatError{}, err, content)
+		}
+	}
+
+	assert.Nil(t, New().ValidateInput(log, strings.NewReader("version: 2.1\nsetup: true\n"), ".circleci/config.yml"), "any version in .circleci")
+}
+
+func TestCircleCiProcessOccurrences(t *testing.T) {
+	format := New().(*circleCiFormat)
+	assert.Nil(t, format.ValidateInput(log, strings.NewReader(configFile), ".circleci/config.yml"))
+
+	found := make([]string, 0)
+	lines := make([]int, 0)
+	buffer := bytes.NewBuffer(nil)
+	err := format.ProcessOccurrences(log, nil, buffer, func(r dockref.Reference, occurrence dockfmt.Occurrence) (dockref.Reference, error) {
+		found = append(found, r.Original())
+		lines = append(lines, occurrence.Line)
+		return r.WithTag(r.Tag() + ".1"), nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"cimg/go:1.21", "cimg/node:18.17", "postgres:13", "cimg/base:2023.09"}, found)
+	assert.Equal(t, []int{14, 25, 26, 9}, lines)
+
+	expected := strings.Replace(configFile, `"cimg/go:1.21"`, `"cimg/go:1.21.1"`, 1)
+	expected = strings.Replace(expected, "cimg/node:18.17\n", "cimg/node:18.17.1\n", 1)
+	expected = strings.Replace(expected, `'postgres:13'`, `'postgres:13.1'`, 1)
+	expected = strings.Replace(expected, "cimg/base:2023.09", "cimg/base:2023.09.1", 1)
+	assert.Equal(t, expected, buffer.String())
+}
+
+func TestCircleCiProcessKeepsUnchangedInput(t *testing.T) {
+	format := New()
+	assert.Nil(t, format.ValidateInput(log, strings.NewReader(configFile), ".circleci/config.yml"))
+
+	buffer := bytes.NewBuffer(nil)
+	err := format.Process(log, nil, buffer, func(r dockref.Reference) (dockref.Reference, error) {
+		return r, nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, configFile, buffer.String())
+}
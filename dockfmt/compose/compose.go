@@ -0,0 +1,433 @@
+package compose
+
+import (
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockfmt/yamlfmt"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/docker/distribution/reference"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func init() {
+	dockfmt.RegisterFormat(New())
+}
+
+// ensure Format is implemented
+var _ dockfmt.Format = (*composeFormat)(nil)
+var _ dockfmt.OccurrenceFormat = (*composeFormat)(nil)
+var _ dockfmt.FileFormat = (*composeFormat)(nil)
+
+type composeFormat struct {
+	document *yamlfmt.Document
+	services *yaml.Node
+
+	// merged are the services of the file docker compose merges with the input,
+	// i.e. of compose.override.yml for compose.yml and of compose.yml for compose.override.yml
+	merged         *yaml.Node
+	mergedFilename string
+	override       bool
+	envFile        *envFile
+
+	lookupEnv func(key string) (string, bool)
+	readFile  func(filename string) ([]byte, error)
+	writeFile dockfmt.FileWriter
+}
+
+func (format *composeFormat) Name() string {
+	return "docker-compose"
+}
+
+// New creates the format of docker-compose.yml and compose.yaml files, i.e. YAML with a services mapping.
+// Variables are read from the environment and the .env file next to the input.
+func New() dockfmt.Format {
+	return &composeFormat{
+		lookupEnv: os.LookupEnv,
+		readFile:  ioutil.ReadFile,
+		writeFile: yamlfmt.WriteFile,
+	}
+}
+
+// Files returns the .env file, its entries are pinned for variables in images
+func (format *composeFormat) Files() []string {
+	if format.envFile == nil {
+		return nil
+	}
+	return []string{format.envFile.filename}
+}
+
+func (format *composeFormat) SetFileWriter(writer dockfmt.FileWriter) {
+	if writer == nil {
+		writer = yamlfmt.WriteFile
+	}
+	format.writeFile = writer
+}
+
+func (format *composeFormat) ValidateInput(log logrus.FieldLogger, reader io.Reader, filename string) error {
+	err := format.validateInput(log, reader, filename)
+	if err != nil {
+		return dockfmt.FormatErrorNew(err)
+	}
+
+	return nil
+}
+
+func (format *composeFormat) validateInput(log logrus.FieldLogger, reader io.Reader, filename string) error {
+	content, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+
+	document, err := yamlfmt.Parse(content)
+	if err != nil {
+		return err
+	}
+
+	if len(document.Roots) != 1 {
+		return errors.Errorf("Expected a single YAML document, found %d", len(document.Roots))
+	}
+
+	root := yamlfmt.Mapping(document.Roots[0])
+	services := yamlfmt.Value(root, "services")
+	if services == nil || services.Kind != yaml.MappingNode {
+		return errors.New("No services mapping found")
+	}
+	// Woodpecker pipelines may have a services mapping as well
+	if yamlfmt.Value(root, "steps") != nil || yamlfmt.Value(root, "pipeline") != nil {
+		return errors.New("Pipelines with steps are no compose files")
+	}
+
+	format.document = document
+	format.services = services
+
+	return format.readSiblings(filename)
+}
+
+// readSiblings reads the .env file and the file that is merged with the input, both are optional
+func (format *composeFormat) readSiblings(filename string) error {
+	format.envFile = nil
+	format.merged = nil
+	format.mergedFilename = ""
+
+	envFilename := filepath.Join(filepath.Dir(filename), ".env")
+	content, err := format.readOptional(envFilename)
+	if err != nil {
+		return err
+	}
+	if content != nil {
+		format.envFile = parseEnvFile(envFilename, content)
+	}
+
+	candidates, override := mergedFilenames(filename)
+	format.override = override
+	for _, candidate := range candidates {
+		content, err := format.readOptional(candidate)
+		if err != nil {
+			return err
+		}
+		if content == nil {
+			continue
+		}
+
+		merged, err := yamlfmt.Parse(content)
+		if err != nil {
+			return errors.Wrapf(err, "invalid %s", candidate)
+		}
+		if len(merged.Roots) == 1 {
+			format.merged = yamlfmt.Value(yamlfmt.Mapping(merged.Roots[0]), "services")
+		}
+		format.mergedFilename = candidate
+		return nil
+	}
+
+	return nil
+}
+
+// readOptional returns nil when the file doesn't exist
+func (format *composeFormat) readOptional(filename string) ([]byte, error) {
+	content, err := format.readFile(filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if content == nil {
+		content = []byte{}
+	}
+	return content, nil
+}
+
+// mergedFilenames returns the override files of a compose file or, for an override file, its compose files
+func mergedFilenames(filename string) (candidates []string, override bool) {
+	dir, base := filepath.Dir(filename), filepath.Base(filename)
+	for _, name := range []string{"compose", "docker-compose"} {
+		for _, extension := range []string{".yml", ".yaml"} {
+			switch base {
+			case name + extension:
+				return []string{
+					filepath.Join(dir, name+".override.yml"),
+					filepath.Join(dir, name+".override.yaml"),
+				}, false
+			case name + ".override" + extension:
+				return []string{
+					filepath.Join(dir, name+".yml"),
+					filepath.Join(dir, name+".yaml"),
+				}, true
+			}
+		}
+	}
+	return nil, false
+}
+
+func (format *composeFormat) Process(log logrus.FieldLogger, reader io.Reader, w io.Writer, imageNameProcessor dockfmt.ImageNameProcessor) error {
+	return format.ProcessOccurrences(log, reader, w, func(r dockref.Reference, occurrence dockfmt.Occurrence) (dockref.Reference, error) {
+		return imageNameProcessor(r)
+	})
+}
+
+func (format *composeFormat) ProcessOccurrences(log logrus.FieldLogger, reader io.Reader, w io.Writer, occurrenceProcessor dockfmt.OccurrenceProcessor) error {
+	err := format.process(log, w, occurrenceProcessor)
+	if err != nil {
+		return dockfmt.FormatErrorNew(err)
+	}
+
+	return nil
+}
+
+func (format *composeFormat) process(log logrus.FieldLogger, w io.Writer, occurrenceProcessor dockfmt.OccurrenceProcessor) error {
+	// values of the .env file to write
+	pinned := make(map[string]string)
+	seen := make(map[*yaml.Node]bool)
+
+	for _, image := range format.images(log) {
+		if seen[image.Node] {
+			continue
+		}
+		seen[image.Node] = true
+
+		err := format.processImage(log, image, pinned, occurrenceProcessor)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := format.document.WriteTo(w)
+	if err != nil {
+		return err
+	}
+
+	if len(pinned) > 0 {
+		log.Infof("Updating %s", format.envFile.filename)
+		return format.writeFile(format.envFile.filename, format.envFile.with(pinned))
+	}
+	return nil
+}
+
+// processImage reports the interpolated image and pins the defaults or .env entries of its variables
+func (format *composeFormat) processImage(log logrus.FieldLogger, image yamlfmt.Image, pinned map[string]string, occurrenceProcessor dockfmt.OccurrenceProcessor) error {
+	node := image.Node
+
+	t, err := parseTemplate(node.Value)
+	if err != nil {
+		return errors.Wrapf(err, "invalid image in line %d", node.Line)
+	}
+	resolved, err := t.resolve(format.lookup)
+	if err != nil {
+		return errors.Wrapf(err, "invalid image in line %d", node.Line)
+	}
+	for _, r := range resolved {
+		if r.source == sourceUnset && r.variable.operator == "" {
+			log.Warnf("Skipping image %s in line %d, variable %s is not set", node.Value, node.Line, r.variable.name)
+			return nil
+		}
+	}
+
+	platform, err := interpolate(image.Platform, format.lookup)
+	if err != nil {
+		return errors.Wrapf(err, "invalid platform of image in line %d", node.Line)
+	}
+
+	value := t.join(resolved, nil)
+	log.Infof("Found image %s", value)
+	ref, err := dockref.Parse(value)
+	if err != nil {
+		return err
+	}
+
+	processed, err := occurrenceProcessor(ref, dockfmt.Occurrence{Line: node.Line, Platform: platform})
+	if err != nil {
+		return err
+	}
+	if processed == ref {
+		return nil
+	}
+
+	log.Infof("Pinning '%s' as '%s'", value, processed)
+	if len(resolved) == 0 {
+		format.document.Replace(node, processed.String())
+		return nil
+	}
+
+	var raw string
+	var envValues map[string]string
+	for i, candidate := range spellings(value, processed) {
+		var e error
+		raw, envValues, e = pinnedValues(t, resolved, candidate, pinned)
+		if e == nil {
+			err = nil
+			break
+		}
+		if i == 0 {
+			err = e
+		}
+	}
+	if err != nil {
+		return errors.Wrapf(err, "cannot pin %s in line %d as %s", node.Value, node.Line, processed)
+	}
+
+	if raw != node.Value {
+		format.document.Replace(node, raw)
+	}
+	for name, value := range envValues {
+		pinned[name] = value
+	}
+	return nil
+}
+
+// spellings returns processed with the names that refer to the same repository, e.g. docker.io/library/postgres for postgres.
+// The name as written in value comes first, so the literals of the template match.
+func spellings(value string, processed dockref.Reference) []string {
+	formatted := processed.String()
+	named := processed.Named()
+	if named == nil || processed.Format()&dockref.FormatHasName == 0 {
+		return []string{formatted}
+	}
+
+	names := []string{named.Name(), reference.FamiliarName(named)}
+	if reference.Domain(named) == "docker.io" {
+		names = append(names, "docker.io/"+reference.FamiliarName(named))
+	}
+
+	rest := formatted
+	for _, name := range names {
+		if strings.HasPrefix(formatted, name) && len(formatted)-len(name) < len(rest) {
+			rest = formatted[len(name):]
+		}
+	}
+	if rest == formatted {
+		return []string{formatted}
+	}
+
+	candidates := make([]string, 0, len(names)+1)
+	for _, name := range names {
+		if strings.HasPrefix(value, name+":") || strings.HasPrefix(value, name+"@") || value == name {
+			candidates = append(candidates, name+rest)
+		}
+	}
+	candidates = append(candidates, formatted)
+	for _, name := range names {
+		candidates = append(candidates, name+rest)
+	}
+
+	unique := make([]string, 0, len(candidates))
+	seen := make(map[string]bool)
+	for _, candidate := range candidates {
+		if !seen[candidate] {
+			seen[candidate] = true
+			unique = append(unique, candidate)
+		}
+	}
+	return unique
+}
+
+// pinnedValues returns the template with the changed defaults and the changed .env entries that produce value
+func pinnedValues(t template, resolved []resolvedVariable, value string, pinned map[string]string) (string, map[string]string, error) {
+	values, err := t.match(resolved, value)
+	if err != nil {
+		return "", nil, err
+	}
+
+	raw := t.raw
+	envValues := make(map[string]string)
+	// replace from the end, so the offsets of earlier arguments stay valid
+	for i := len(resolved) - 1; i >= 0; i-- {
+		r := resolved[i]
+		if values[i] == r.value {
+			continue
+		}
+
+		switch r.source {
+		case sourceArgument:
+			if strings.Contains(r.variable.argument, "$") {
+				return "", nil, errors.Errorf("the default of %s contains variables", r.variable.name)
+			}
+			raw = raw[:r.variable.start] + values[i] + raw[r.variable.end:]
+		case sourceEnvFile:
+			if previous, ok := envValues[r.variable.name]; ok && previous != values[i] {
+				return "", nil, errors.Errorf("%s would need several values", r.variable.name)
+			}
+			if previous, ok := pinned[r.variable.name]; ok && previous != values[i] {
+				return "", nil, errors.Errorf("%s in .env is already pinned to %s for another image", r.variable.name, previous)
+			}
+			envValues[r.variable.name] = values[i]
+		case sourceEnvironment:
+			return "", nil, errors.Errorf("%s is set in the environment", r.variable.name)
+		default:
+			return "", nil, errors.Errorf("%s is not set", r.variable.name)
+		}
+	}
+
+	return raw, envValues, nil
+}
+
+// lookup returns the value of a variable, the environment takes precedence over the .env file like in docker compose
+func (format *composeFormat) lookup(name string) (string, valueSource) {
+	if value, ok := format.lookupEnv(name); ok {
+		return value, sourceEnvironment
+	}
+	if value, ok := format.envFile.lookup(name); ok {
+		return value, sourceEnvFile
+	}
+	return "", sourceUnset
+}
+
+// images returns the image of each service in order, services that are only built have no image.
+// Images that are overridden by the override file are skipped, its platforms take precedence.
+func (format *composeFormat) images(log logrus.FieldLogger) []yamlfmt.Image {
+	images := make([]yamlfmt.Image, 0)
+
+	names, services := yamlfmt.Pairs(format.services)
+	for i, service := range services {
+		image := yamlfmt.Value(service, "image")
+		if image == nil || image.Kind != yaml.ScalarNode {
+			continue
+		}
+
+		platform := yamlfmt.Scalar(yamlfmt.Value(service, "platform"))
+		merged := yamlfmt.Value(format.merged, names[i])
+		if format.override {
+			if platform == "" {
+				platform = yamlfmt.Scalar(yamlfmt.Value(merged, "platform"))
+			}
+		} else {
+			if yamlfmt.Value(merged, "image") != nil {
+				log.Infof("Skipping image %s of service %s, it is overridden in %s", image.Value, names[i], format.mergedFilename)
+				continue
+			}
+			if mergedPlatform := yamlfmt.Scalar(yamlfmt.Value(merged, "platform")); mergedPlatform != "" {
+				platform = mergedPlatform
+			}
+		}
+
+		images = append(images, yamlfmt.Image{Node: image, Platform: platform})
+	}
+
+	return images
+}
@@ -0,0 +1,277 @@
+package compose
+
+import (
+	"bytes"
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"strings"
+	"testing"
+)
+
+var log = logrus.New()
+
+func init() {
+	log.SetOutput(bytes.NewBuffer(nil))
+}
+
+const composeFile = `# the application
+version: "3.8"
+
+x-defaults: &defaults
+  image: redis:6 # cache
+  restart: always
+
+services:
+  web:
+    image: "nginx:1.15"   # frontend
+    platform: linux/arm64
+    ports:
+      - "80:80"
+  db:
+    image: 'postgres:13'
+  cache:
+    <<: *defaults
+  worker:
+    build: ./worker
+  variable:
+    image: registry.corp/app:${TAG:-1.2}
+`
+
+// formatWith creates the format with the environment and files of a test, written files are returned
+func formatWith(env map[string]string, files map[string]string) (*composeFormat, map[string]string) {
+	written := make(map[string]string)
+
+	format := New().(*composeFormat)
+	format.lookupEnv = func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	}
+	format.readFile = func(filename string) ([]byte, error) {
+		content, ok := files[filename]
+		if !ok {
+			return nil, &os.PathError{Op: "open", Path: filename, Err: os.ErrNotExist}
+		}
+		return []byte(content), nil
+	}
+	format.writeFile = func(filename string, content []byte) error {
+		written[filename] = string(content)
+		return nil
+	}
+
+	return format, written
+}
+
+// pinWith validates and processes the content, tags are extended by .1
+func pinWith(format *composeFormat, filename string, content string) ([]string, string, error) {
+	err := format.ValidateInput(log, strings.NewReader(content), filename)
+	if err != nil {
+		return nil, "", err
+	}
+
+	found := make([]string, 0)
+	buffer := bytes.NewBuffer(nil)
+	err = format.ProcessOccurrences(log, nil, buffer, func(r dockref.Reference, occurrence dockfmt.Occurrence) (dockref.Reference, error) {
+		found = append(found, r.Original())
+		return r.WithTag(r.Tag() + ".1"), nil
+	})
+	return found, buffer.String(), err
+}
+
+func TestComposeName(t *testing.T) {
+	assert.Equal(t, "docker-compose", New().Name())
+}
+
+func TestComposeValidateInput(t *testing.T) {
+	for content, valid := range map[string]bool{
+		composeFile:                         true,
+		"services: {}":                      true,
+		"FROM nginx":                        false,
+		"":                                  false,
+		"services:\n  - image: nginx":       false,
+		"image: nginx":                      false,
+		"services: {}\n---\nservices: {}\n": false,
+		"services: [":                       false,
+		"steps: []\nservices: {}":           false,
+	} {
+		err := New().ValidateInput(log, strings.NewReader(content), "docker-compose.yml")
+		if valid {
+			assert.Nil(t, err, content)
+		} else {
+			assert.Error(t, err, content)
+			assert.IsType(t, dockfmt.FormatError{}, err, content)
+		}
+	}
+}
+
+func TestComposeProcessOccurrences(t *testing.T) {
+	format, _ := formatWith(nil, nil)
+	assert.Nil(t, format.ValidateInput(log, strings.NewReader(composeFile), "docker-compose.yml"))
+
+	found := make([]string, 0)
+	platforms := make([]string, 0)
+	lines := make([]int, 0)
+	buffer := bytes.NewBuffer(nil)
+	err := format.ProcessOccurrences(log, nil, buffer, func(r dockref.Reference, occurrence dockfmt.Occurrence) (dockref.Reference, error) {
+		found = append(found, r.Original())
+		platforms = append(platforms, occurrence.Platform)
+		lines = append(lines, occurrence.Line)
+		return r.WithTag(r.Tag() + ".1"), nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"nginx:1.15", "postgres:13", "redis:6", "registry.corp/app:1.2"}, found)
+	assert.Equal(t, []string{"linux/arm64", "", "", ""}, platforms)
+	assert.Equal(t, []int{10, 15, 5, 21}, lines)
+
+	expected := strings.Replace(composeFile, `"nginx:1.15"`, `"nginx:1.15.1"`, 1)
+	expected = strings.Replace(expected, `'postgres:13'`, `'postgres:13.1'`, 1)
+	expected = strings.Replace(expected, `redis:6 #`, `redis:6.1 #`, 1)
+	expected = strings.Replace(expected, `${TAG:-1.2}`, `${TAG:-1.2.1}`, 1)
+	assert.Equal(t, expected, buffer.String())
+}
+
+func TestComposeProcessKeepsUnchangedInput(t *testing.T) {
+	format, written := formatWith(nil, map[string]string{".env": "TAG=1.2\n"})
+	assert.Nil(t, format.ValidateInput(log, strings.NewReader(composeFile), "docker-compose.yml"))
+
+	buffer := bytes.NewBuffer(nil)
+	err := format.Process(log, nil, buffer, func(r dockref.Reference) (dockref.Reference, error) {
+		return r, nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, composeFile, buffer.String())
+	assert.Empty(t, written)
+}
+
+func TestComposeVariables(t *testing.T) {
+	content := `services:
+  default:
+    image: app:${TAG:-1.2}
+  unset:
+    image: app:${MISSING}
+  registry:
+    image: "${REGISTRY:-registry.corp/team}/app:${APP_TAG-1.0}"
+`
+	format, written := formatWith(nil, nil)
+	found, pinned, err := pinWith(format, "compose.yml", content)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"app:1.2", "registry.corp/team/app:1.0"}, found)
+	expected := strings.Replace(content, "${TAG:-1.2}", "${TAG:-1.2.1}", 1)
+	expected = strings.Replace(expected, "${APP_TAG-1.0}", "${APP_TAG-1.0.1}", 1)
+	assert.Equal(t, expected, pinned)
+	assert.Empty(t, written)
+}
+
+func TestComposeVariables_EnvFile(t *testing.T) {
+	content := `services:
+  app:
+    image: app:${TAG:-1.0}
+  worker:
+    image: worker:${TAG}
+  db:
+    image: postgres:${DB_TAG}
+`
+	env := "# versions\nTAG=1.2\nexport DB_TAG=\"13\" # database\n"
+	format, written := formatWith(nil, map[string]string{"project/.env": env})
+	found, pinned, err := pinWith(format, "project/compose.yml", content)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"app:1.2", "worker:1.2", "postgres:13"}, found)
+	assert.Equal(t, content, pinned, "values of the .env file are pinned there")
+	assert.Equal(t, map[string]string{
+		"project/.env": "# versions\nTAG=1.2.1\nexport DB_TAG=\"13.1\" # database\n",
+	}, written)
+}
+
+func TestComposeVariables_ConflictingEnvFileValues(t *testing.T) {
+	content := `services:
+  app:
+    image: app:${TAG}
+  worker:
+    image: worker:${TAG}
+`
+	format, written := formatWith(nil, map[string]string{".env": "TAG=1.2\n"})
+	err := format.ValidateInput(log, strings.NewReader(content), "compose.yml")
+	assert.Nil(t, err)
+
+	buffer := bytes.NewBuffer(nil)
+	err = format.ProcessOccurrences(log, nil, buffer, func(r dockref.Reference, occurrence dockfmt.Occurrence) (dockref.Reference, error) {
+		return r.WithTag(r.Tag() + "." + strings.TrimPrefix(r.Name(), "docker.io/library/")), nil
+	})
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "TAG in .env is already pinned to 1.2.app for another image")
+	assert.Empty(t, written, "no image pins TAG when another can't")
+}
+
+func TestComposeVariables_Environment(t *testing.T) {
+	content := "services:\n  app:\n    image: app:${TAG:-1.0}\n"
+	format, written := formatWith(map[string]string{"TAG": "2.0"}, map[string]string{".env": "TAG=1.2\n"})
+	found, _, err := pinWith(format, "compose.yml", content)
+
+	assert.Error(t, err, "the environment can't be pinned")
+	assert.Contains(t, err.Error(), "TAG is set in the environment")
+	assert.Equal(t, []string{"app:2.0"}, found, "the environment takes precedence")
+	assert.Empty(t, written)
+}
+
+func TestComposeVariables_NameOfOtherForm(t *testing.T) {
+	content := "services:\n  db:\n    image: ${REGISTRY:-docker.io}/library/postgres:${TAG:-13}\n"
+	format, _ := formatWith(nil, nil)
+	err := format.ValidateInput(log, strings.NewReader(content), "compose.yml")
+	assert.Nil(t, err)
+
+	buffer := bytes.NewBuffer(nil)
+	err = format.ProcessOccurrences(log, nil, buffer, func(r dockref.Reference, occurrence dockfmt.Occurrence) (dockref.Reference, error) {
+		return dockref.MustParse("postgres:13.2"), nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, strings.Replace(content, "${TAG:-13}", "${TAG:-13.2}", 1), buffer.String())
+}
+
+func TestComposeVariables_Invalid(t *testing.T) {
+	for _, image := range []string{"app:${TAG:?TAG is required}", "app:${TAG", "app:$-"} {
+		format, _ := formatWith(nil, nil)
+		_, _, err := pinWith(format, "compose.yml", "services:\n  app:\n    image: "+image+"\n")
+		assert.Error(t, err, image)
+		assert.IsType(t, dockfmt.FormatError{}, err, image)
+	}
+}
+
+func TestComposeOverride(t *testing.T) {
+	base := `services:
+  web:
+    image: nginx:1.15
+  db:
+    image: postgres:13
+    platform: linux/amd64
+`
+	override := `services:
+  web:
+    image: nginx:1.15-alpine
+  db:
+    platform: linux/arm64
+`
+	files := map[string]string{"docker-compose.override.yml": override, "docker-compose.yml": base}
+
+	format, _ := formatWith(nil, files)
+	assert.Nil(t, format.ValidateInput(log, strings.NewReader(base), "docker-compose.yml"))
+	platforms := make(map[string]string)
+	err := format.ProcessOccurrences(log, nil, bytes.NewBuffer(nil), func(r dockref.Reference, occurrence dockfmt.Occurrence) (dockref.Reference, error) {
+		platforms[r.Original()] = occurrence.Platform
+		return r, nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"postgres:13": "linux/arm64"}, platforms, "the web image is overridden")
+
+	format, _ = formatWith(nil, files)
+	found, _, err := pinWith(format, "docker-compose.override.yml", override)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"nginx:1.15-alpine"}, found)
+}
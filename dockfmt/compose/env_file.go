@@ -0,0 +1,90 @@
+package compose
+
+import (
+	"strings"
+)
+
+// envFile is a .env file of KEY=VALUE lines, values are replaced in the original lines
+type envFile struct {
+	filename string
+	lines    []string
+	entries  map[string]envEntry
+}
+
+// envEntry is the value of a key, start and end are the offsets of the value in its line without quotes
+type envEntry struct {
+	value string
+	line  int
+	start int
+	end   int
+	quote string
+}
+
+// parseEnvFile parses lines like KEY=VALUE, export KEY=VALUE, KEY="VALUE" and comments, later keys take precedence
+func parseEnvFile(filename string, content []byte) *envFile {
+	file := &envFile{
+		filename: filename,
+		lines:    strings.SplitAfter(string(content), "\n"),
+		entries:  make(map[string]envEntry),
+	}
+
+	for i, line := range file.lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		equals := strings.Index(line, "=")
+		if equals < 0 {
+			continue
+		}
+		key := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line[:equals]), "export "))
+
+		start := equals + 1
+		for start < len(line) && (line[start] == ' ' || line[start] == '\t') {
+			start++
+		}
+		rest := strings.TrimRight(line[start:], "\r\n")
+
+		entry := envEntry{line: i, start: start, end: start + len(rest)}
+		if len(rest) > 0 && (rest[0] == '"' || rest[0] == '\'') {
+			if closing := strings.Index(rest[1:], rest[:1]); closing >= 0 {
+				entry.quote = rest[:1]
+				entry.start = start + 1
+				entry.end = start + 1 + closing
+			}
+		} else if comment := strings.Index(rest, " #"); comment >= 0 {
+			entry.end = start + comment
+		}
+		entry.value = strings.TrimSpace(line[entry.start:entry.end])
+		if entry.quote == "" {
+			entry.end = entry.start + len(strings.TrimRight(line[entry.start:entry.end], " \t"))
+		} else {
+			entry.value = line[entry.start:entry.end]
+		}
+
+		file.entries[key] = entry
+	}
+
+	return file
+}
+
+// lookup returns the value of the key and whether the file defines it
+func (file *envFile) lookup(key string) (string, bool) {
+	if file == nil {
+		return "", false
+	}
+	entry, ok := file.entries[key]
+	return entry.value, ok
+}
+
+// with returns the content of the file with the values of the keys replaced
+func (file *envFile) with(values map[string]string) []byte {
+	lines := append([]string{}, file.lines...)
+	for key, value := range values {
+		entry := file.entries[key]
+		line := lines[entry.line]
+		lines[entry.line] = line[:entry.start] + value + line[entry.end:]
+	}
+	return []byte(strings.Join(lines, ""))
+}
@@ -0,0 +1,35 @@
+package compose
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestEnvFile(t *testing.T) {
+	content := "# comment\n\nTAG=1.2\nexport BASE = alpine # variant\nQUOTED='1.0'\r\nDOUBLE=\"a # b\"\nEMPTY=\nINVALID\n"
+	file := parseEnvFile(".env", []byte(content))
+
+	for key, expected := range map[string]string{
+		"TAG":    "1.2",
+		"BASE":   "alpine",
+		"QUOTED": "1.0",
+		"DOUBLE": "a # b",
+		"EMPTY":  "",
+	} {
+		value, ok := file.lookup(key)
+		assert.True(t, ok, key)
+		assert.Equal(t, expected, value, key)
+	}
+
+	_, ok := file.lookup("INVALID")
+	assert.False(t, ok)
+
+	pinned := file.with(map[string]string{"TAG": "1.2.3", "BASE": "alpine3.18", "QUOTED": "1.0.1", "EMPTY": "1"})
+	assert.Equal(t, "# comment\n\nTAG=1.2.3\nexport BASE = alpine3.18 # variant\nQUOTED='1.0.1'\r\nDOUBLE=\"a # b\"\nEMPTY=1\nINVALID\n", string(pinned))
+}
+
+func TestEnvFile_Nil(t *testing.T) {
+	var file *envFile
+	_, ok := file.lookup("TAG")
+	assert.False(t, ok)
+}
@@ -0,0 +1,288 @@
+package compose
+
+import (
+	"github.com/pkg/errors"
+	"regexp"
+	"strings"
+)
+
+var variableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*`)
+
+// template is a value with variables like registry/app:${TAG:-1.2}, split into literals and variables
+type template struct {
+	raw   string
+	parts []templatePart
+}
+
+// templatePart is either a literal or a variable
+type templatePart struct {
+	literal  string
+	variable *variable
+}
+
+// variable is $NAME or ${NAME} with an optional operator like :- and its argument, e.g. the default of ${TAG:-1.2}
+type variable struct {
+	name string
+	// operator is one of :- - :? ? :+ + or empty
+	operator string
+	argument string
+	// start and end are the offsets of the argument in the raw value
+	start int
+	end   int
+}
+
+// valueSource tells where the value of a variable comes from
+type valueSource int
+
+const (
+	sourceUnset valueSource = iota
+	sourceEnvironment
+	sourceEnvFile
+	sourceArgument
+)
+
+// resolvedVariable is the value of a variable in a template
+type resolvedVariable struct {
+	variable *variable
+	value    string
+	source   valueSource
+}
+
+// lookup returns the value of a variable, the environment takes precedence over the .env file
+type lookup func(name string) (value string, source valueSource)
+
+// parseTemplate parses a value with variables as used by docker compose, $$ is a literal $
+func parseTemplate(raw string) (template, error) {
+	t := template{raw: raw}
+	literal := ""
+
+	for i := 0; i < len(raw); {
+		if raw[i] != '$' {
+			literal += raw[i : i+1]
+			i++
+			continue
+		}
+
+		if strings.HasPrefix(raw[i:], "$$") {
+			literal += "$"
+			i += 2
+			continue
+		}
+
+		v, n, err := parseVariable(raw, i)
+		if err != nil {
+			return t, err
+		}
+		if literal != "" {
+			t.parts = append(t.parts, templatePart{literal: literal})
+			literal = ""
+		}
+		t.parts = append(t.parts, templatePart{variable: v})
+		i += n
+	}
+
+	if literal != "" {
+		t.parts = append(t.parts, templatePart{literal: literal})
+	}
+	return t, nil
+}
+
+// parseVariable parses the variable that starts at offset and returns its length
+func parseVariable(raw string, offset int) (*variable, int, error) {
+	rest := raw[offset+1:]
+	if !strings.HasPrefix(rest, "{") {
+		name := variableNamePattern.FindString(rest)
+		if name == "" {
+			return nil, 0, errors.Errorf("invalid variable in %s, use $$ for a literal $", raw)
+		}
+		return &variable{name: name}, 1 + len(name), nil
+	}
+
+	name := variableNamePattern.FindString(rest[1:])
+	if name == "" {
+		return nil, 0, errors.Errorf("invalid variable in %s", raw)
+	}
+
+	v := &variable{name: name}
+	i := 1 + len(name)
+	for _, operator := range []string{":-", "-", ":?", "?", ":+", "+"} {
+		if strings.HasPrefix(rest[i:], operator) {
+			v.operator = operator
+			i += len(operator)
+			break
+		}
+	}
+
+	end := closingBrace(rest, i)
+	if end < 0 || v.operator == "" && end != i {
+		return nil, 0, errors.Errorf("invalid variable in %s", raw)
+	}
+
+	v.argument = rest[i:end]
+	v.start = offset + 1 + i
+	v.end = offset + 1 + end
+	return v, 1 + end + 1, nil
+}
+
+// closingBrace returns the index of the brace that closes a variable, nested variables like ${A:-${B}} are skipped
+func closingBrace(s string, from int) int {
+	depth := 0
+	for i := from; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			if depth == 0 {
+				return i
+			}
+			depth--
+		}
+	}
+	return -1
+}
+
+// resolve evaluates the variables of the template
+func (t template) resolve(lookup lookup) ([]resolvedVariable, error) {
+	resolved := make([]resolvedVariable, 0)
+	for _, part := range t.parts {
+		if part.variable == nil {
+			continue
+		}
+		r, err := part.variable.resolve(lookup)
+		if err != nil {
+			return nil, err
+		}
+		resolved = append(resolved, r)
+	}
+	return resolved, nil
+}
+
+func (v *variable) resolve(lookup lookup) (resolvedVariable, error) {
+	value, source := lookup(v.name)
+	set := source != sourceUnset
+	nonEmpty := set && value != ""
+
+	useArgument := false
+	switch v.operator {
+	case ":-":
+		useArgument = !nonEmpty
+	case "-":
+		useArgument = !set
+	case ":?", "?":
+		if v.operator == ":?" && !nonEmpty || !set {
+			return resolvedVariable{}, errors.Errorf("required variable %s is missing a value: %s", v.name, v.argument)
+		}
+	case ":+":
+		if !nonEmpty {
+			return resolvedVariable{variable: v, value: "", source: sourceUnset}, nil
+		}
+		useArgument = true
+	case "+":
+		if !set {
+			return resolvedVariable{variable: v, value: "", source: sourceUnset}, nil
+		}
+		useArgument = true
+	}
+
+	if !useArgument {
+		return resolvedVariable{variable: v, value: value, source: source}, nil
+	}
+
+	argument, err := interpolate(v.argument, lookup)
+	if err != nil {
+		return resolvedVariable{}, err
+	}
+	return resolvedVariable{variable: v, value: argument, source: sourceArgument}, nil
+}
+
+// interpolate replaces the variables of raw with their values
+func interpolate(raw string, lookup lookup) (string, error) {
+	t, err := parseTemplate(raw)
+	if err != nil {
+		return "", err
+	}
+	resolved, err := t.resolve(lookup)
+	if err != nil {
+		return "", err
+	}
+	return t.join(resolved, nil), nil
+}
+
+// join concatenates the literals and the values of the variables, values are replaced by the ones in values
+func (t template) join(resolved []resolvedVariable, values []string) string {
+	result := ""
+	i := 0
+	for _, part := range t.parts {
+		if part.variable == nil {
+			result += part.literal
+			continue
+		}
+		if values != nil {
+			result += values[i]
+		} else {
+			result += resolved[i].value
+		}
+		i++
+	}
+	return result
+}
+
+// match finds the values of the variables that make the template produce value, e.g. 1.2.3 for TAG in app:${TAG}.
+// Where a literal occurs several times, the occurrence where the previous variable keeps its length is preferred.
+func (t template) match(resolved []resolvedVariable, value string) ([]string, error) {
+	values := make([]string, 0, len(resolved))
+	position := 0
+	variableStart := -1
+	i := 0
+
+	for p, part := range t.parts {
+		if part.variable != nil {
+			if variableStart >= 0 {
+				return nil, errors.Errorf("cannot tell the values of adjacent variables in %s", t.raw)
+			}
+			variableStart = position
+			continue
+		}
+
+		var index int
+		switch {
+		case variableStart < 0:
+			index = position
+			if !strings.HasPrefix(value[position:], part.literal) {
+				index = -1
+			}
+		case p == len(t.parts)-1:
+			index = len(value) - len(part.literal)
+			if index < position || !strings.HasSuffix(value, part.literal) {
+				index = -1
+			}
+		default:
+			index = -1
+			expected := position + len(resolved[i].value)
+			if expected <= len(value) && strings.HasPrefix(value[expected:], part.literal) {
+				index = expected
+			} else if found := strings.Index(value[position:], part.literal); found >= 0 {
+				index = position + found
+			}
+		}
+
+		if index < 0 {
+			return nil, errors.Errorf("%s doesn't match %s", value, t.raw)
+		}
+
+		if variableStart >= 0 {
+			values = append(values, value[variableStart:index])
+			variableStart = -1
+			i++
+		}
+		position = index + len(part.literal)
+	}
+
+	if variableStart >= 0 {
+		values = append(values, value[variableStart:])
+	} else if position != len(value) {
+		return nil, errors.Errorf("%s doesn't match %s", value, t.raw)
+	}
+
+	return values, nil
+}
@@ -0,0 +1,93 @@
+package compose
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func lookupOf(env map[string]string, envFile map[string]string) lookup {
+	return func(name string) (string, valueSource) {
+		if value, ok := env[name]; ok {
+			return value, sourceEnvironment
+		}
+		if value, ok := envFile[name]; ok {
+			return value, sourceEnvFile
+		}
+		return "", sourceUnset
+	}
+}
+
+func TestInterpolate(t *testing.T) {
+	lookup := lookupOf(map[string]string{"EMPTY": "", "TAG": "1.2"}, map[string]string{"BASE": "alpine"})
+
+	for raw, expected := range map[string]string{
+		"nginx":                  "nginx",
+		"app:$TAG":               "app:1.2",
+		"app:${TAG}-$BASE":       "app:1.2-alpine",
+		"app:${MISSING:-1.0}":    "app:1.0",
+		"app:${EMPTY:-1.0}":      "app:1.0",
+		"app:${EMPTY-1.0}":       "app:",
+		"app:${MISSING-1.0}":     "app:1.0",
+		"app:1${TAG:+-beta}":     "app:1-beta",
+		"app:1${MISSING:+beta}":  "app:1",
+		"app:${TAG?required}":    "app:1.2",
+		"app:${MISSING:-${TAG}}": "app:1.2",
+		"app:$$TAG":              "app:$TAG",
+	} {
+		value, err := interpolate(raw, lookup)
+		assert.Nil(t, err, raw)
+		assert.Equal(t, expected, value, raw)
+	}
+
+	for _, raw := range []string{"app:${MISSING:?required}", "app:${EMPTY:?required}", "app:${TAG", "app:${TAG!}", "app:$", "app:${}"} {
+		_, err := interpolate(raw, lookup)
+		assert.Error(t, err, raw)
+	}
+}
+
+func TestTemplateMatch(t *testing.T) {
+	lookup := lookupOf(map[string]string{"TAG": "1.2", "REGISTRY": "registry.corp/team", "NAME": "app"}, nil)
+
+	for raw, expected := range map[string][]string{
+		"app:${TAG}":                        {"1.2.3@sha256:abc"},
+		"${REGISTRY}/app:${TAG}":            {"registry.corp/team", "1.2.3@sha256:abc"},
+		"${REGISTRY}/${NAME}:${TAG}-alpine": {"registry.corp/team", "app", "1.2.3"},
+	} {
+		tmpl, err := parseTemplate(raw)
+		assert.Nil(t, err, raw)
+		resolved, err := tmpl.resolve(lookup)
+		assert.Nil(t, err, raw)
+
+		pinned := "app:1.2.3@sha256:abc"
+		switch raw {
+		case "${REGISTRY}/app:${TAG}":
+			pinned = "registry.corp/team/app:1.2.3@sha256:abc"
+		case "${REGISTRY}/${NAME}:${TAG}-alpine":
+			pinned = "registry.corp/team/app:1.2.3-alpine"
+		}
+
+		values, err := tmpl.match(resolved, pinned)
+		assert.Nil(t, err, raw)
+		assert.Equal(t, expected, values, raw)
+		assert.Equal(t, pinned, tmpl.join(resolved, values), raw)
+	}
+}
+
+func TestTemplateMatch_Mismatch(t *testing.T) {
+	lookup := lookupOf(map[string]string{"TAG": "1.2", "BASE": "alpine"}, nil)
+
+	for raw, pinned := range map[string]string{
+		"app:${TAG}":        "other:1.2.3",
+		"app:${TAG}-alpine": "app:1.2.3",
+		"app:${TAG}${BASE}": "app:1.2.3alpine",
+		"app:1.2":           "app:1.2.3",
+	} {
+		tmpl, err := parseTemplate(raw)
+		assert.Nil(t, err, raw)
+		resolved, err := tmpl.resolve(lookup)
+		assert.Nil(t, err, raw)
+
+		_, err = tmpl.match(resolved, pinned)
+		assert.Error(t, err, raw)
+	}
+}
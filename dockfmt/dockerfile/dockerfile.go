@@ -21,6 +21,7 @@ func init() {
 
 // ensure Format is implemented
 var _ dockfmt.Format = (*dockerfileFormat)(nil)
+var _ dockfmt.OccurrenceFormat = (*dockerfileFormat)(nil)
 
 type dockerfileFormat struct {
 	lines         []string
@@ -122,7 +123,13 @@ func saveFlush(log logrus.FieldLogger, writer *bufio.Writer) {
 }
 
 func (format *dockerfileFormat) Process(log logrus.FieldLogger, reader io.Reader, w io.Writer, imageNameProcessor dockfmt.ImageNameProcessor) error {
-	err := format.process(log, reader, w, imageNameProcessor)
+	return format.ProcessOccurrences(log, reader, w, func(r dockref.Reference, occurrence dockfmt.Occurrence) (dockref.Reference, error) {
+		return imageNameProcessor(r)
+	})
+}
+
+func (format *dockerfileFormat) ProcessOccurrences(log logrus.FieldLogger, reader io.Reader, w io.Writer, occurrenceProcessor dockfmt.OccurrenceProcessor) error {
+	err := format.process(log, reader, w, occurrenceProcessor)
 	if err != nil {
 		return dockfmt.FormatErrorNew(err)
 	}
@@ -130,7 +137,7 @@ func (format *dockerfileFormat) Process(log logrus.FieldLogger, reader io.Reader
 	return nil
 }
 
-func (format *dockerfileFormat) process(log logrus.FieldLogger, reader io.Reader, w io.Writer, imageNameProcessor dockfmt.ImageNameProcessor) error {
+func (format *dockerfileFormat) process(log logrus.FieldLogger, reader io.Reader, w io.Writer, occurrenceProcessor dockfmt.OccurrenceProcessor) error {
 	result := new(multierror.Error)
 	writer := bufio.NewWriter(w)
 
@@ -148,7 +155,7 @@ func (format *dockerfileFormat) process(log logrus.FieldLogger, reader io.Reader
 			curLineNum++
 		}
 
-		handled, err := format.processNode(log, cmd, writer, imageNameProcessor)
+		handled, err := format.processNode(log, cmd, writer, occurrenceProcessor)
 		if err != nil {
 			return err
 		}
@@ -182,7 +189,17 @@ func endLineOfNode(command *parser.Node) int {
 	return endLine
 }
 
-func (format *dockerfileFormat) processNode(log logrus.FieldLogger, node *parser.Node, writer *bufio.Writer, imageNameProcessor dockfmt.ImageNameProcessor) (bool, error) {
+// platformFlag returns the value of the --platform flag of a FROM instruction
+func platformFlag(flags []string) string {
+	for _, flag := range flags {
+		if strings.HasPrefix(flag, "--platform=") {
+			return strings.TrimPrefix(flag, "--platform=")
+		}
+	}
+	return ""
+}
+
+func (format *dockerfileFormat) processNode(log logrus.FieldLogger, node *parser.Node, writer *bufio.Writer, occurrenceProcessor dockfmt.OccurrenceProcessor) (bool, error) {
 	result := new(multierror.Error)
 
 	if node.Value == "from" {
@@ -194,7 +211,7 @@ func (format *dockerfileFormat) processNode(log logrus.FieldLogger, node *parser
 			return false, err
 		}
 
-		processed, err := imageNameProcessor(ref)
+		processed, err := occurrenceProcessor(ref, dockfmt.Occurrence{Line: node.StartLine, Platform: platformFlag(node.Flags)})
 		if err != nil {
 			return false, err
 		}
@@ -120,6 +120,44 @@ FROM something:tag`
 	assert.Equal(t, 2, calls)
 }
 
+func TestDockerfileProcessOccurrencesReportsLines(t *testing.T) {
+	file := `FROM nginx:tag
+RUN some \
+	command
+
+FROM something:tag`
+	format := newDockerfileFormat()
+	format.ValidateInput(log, strings.NewReader(file), "anything")
+
+	lines := make(map[string]int)
+	err := format.ProcessOccurrences(log, strings.NewReader(file), bytes.NewBuffer(nil), func(r dockref.Reference, occurrence dockfmt.Occurrence) (dockref.Reference, error) {
+		lines[r.Original()] = occurrence.Line
+		return r, nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]int{"nginx:tag": 1, "something:tag": 5}, lines)
+}
+
+func TestDockerfileProcessOccurrencesReportsPlatforms(t *testing.T) {
+	file := `FROM --platform=linux/arm64 nginx:tag AS build
+FROM --platform=$BUILDPLATFORM golang:1.11
+FROM something:tag`
+	format := newDockerfileFormat()
+	format.ValidateInput(log, strings.NewReader(file), "anything")
+
+	platforms := make(map[string]string)
+	buffer := bytes.NewBuffer(nil)
+	err := format.ProcessOccurrences(log, strings.NewReader(file), buffer, func(r dockref.Reference, occurrence dockfmt.Occurrence) (dockref.Reference, error) {
+		platforms[r.Original()] = occurrence.Platform
+		return r.WithTag("pinned"), nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"nginx:tag": "linux/arm64", "golang:1.11": "$BUILDPLATFORM", "something:tag": ""}, platforms)
+	assert.Contains(t, buffer.String(), "FROM --platform=linux/arm64 nginx:pinned AS build")
+}
+
 func TestDockerfilePassProcessorErrors(t *testing.T) {
 	file := `FROM valid`
 	format := New()
@@ -0,0 +1,156 @@
+package drone
+
+import (
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockfmt/yamlfmt"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+func init() {
+	dockfmt.RegisterFormat(New())
+	dockfmt.RegisterFormat(WoodpeckerNew())
+}
+
+// ensure Format is implemented
+var _ dockfmt.Format = (*droneFormat)(nil)
+var _ dockfmt.OccurrenceFormat = (*droneFormat)(nil)
+
+type droneFormat struct {
+	document  *yamlfmt.Document
+	pipelines []*yaml.Node
+}
+
+func (format *droneFormat) Name() string {
+	return "Drone"
+}
+
+// New creates the format of .drone.yml files, i.e. YAML documents with kind: pipeline
+func New() dockfmt.Format {
+	return new(droneFormat)
+}
+
+func (format *droneFormat) ValidateInput(log logrus.FieldLogger, reader io.Reader, filename string) error {
+	err := format.validateInput(log, reader, filename)
+	if err != nil {
+		return dockfmt.FormatErrorNew(err)
+	}
+
+	return nil
+}
+
+func (format *droneFormat) validateInput(log logrus.FieldLogger, reader io.Reader, filename string) error {
+	content, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+
+	document, err := yamlfmt.Parse(content)
+	if err != nil {
+		return err
+	}
+
+	// documents of other kinds like secret or signature have no images
+	pipelines := make([]*yaml.Node, 0)
+	for _, root := range document.Roots {
+		pipeline := yamlfmt.Mapping(root)
+		if yamlfmt.Scalar(yamlfmt.Value(pipeline, "kind")) == "pipeline" {
+			pipelines = append(pipelines, pipeline)
+		}
+	}
+
+	if len(pipelines) == 0 {
+		return errors.New("No document with kind: pipeline found")
+	}
+
+	format.document = document
+	format.pipelines = pipelines
+
+	return nil
+}
+
+func (format *droneFormat) Process(log logrus.FieldLogger, reader io.Reader, w io.Writer, imageNameProcessor dockfmt.ImageNameProcessor) error {
+	return format.ProcessOccurrences(log, reader, w, func(r dockref.Reference, occurrence dockfmt.Occurrence) (dockref.Reference, error) {
+		return imageNameProcessor(r)
+	})
+}
+
+func (format *droneFormat) ProcessOccurrences(log logrus.FieldLogger, reader io.Reader, w io.Writer, occurrenceProcessor dockfmt.OccurrenceProcessor) error {
+	err := format.process(log, w, occurrenceProcessor)
+	if err != nil {
+		return dockfmt.FormatErrorNew(err)
+	}
+
+	return nil
+}
+
+func (format *droneFormat) process(log logrus.FieldLogger, w io.Writer, occurrenceProcessor dockfmt.OccurrenceProcessor) error {
+	err := format.document.ProcessImages(log, format.images(), occurrenceProcessor)
+	if err != nil {
+		return err
+	}
+
+	_, err = format.document.WriteTo(w)
+	return err
+}
+
+// images returns the images of the steps and services of each pipeline with the platform of the pipeline
+func (format *droneFormat) images() []yamlfmt.Image {
+	images := make([]yamlfmt.Image, 0)
+
+	for _, pipeline := range format.pipelines {
+		platform := dronePlatform(yamlfmt.Value(pipeline, "platform"))
+		for _, key := range []string{"steps", "services"} {
+			images = append(images, stepImages(yamlfmt.Value(pipeline, key), platform)...)
+		}
+	}
+
+	return images
+}
+
+// dronePlatform joins platform: {os: linux, arch: arm64, variant: v8} to linux/arm64/v8, os and arch default to linux and amd64
+func dronePlatform(platform *yaml.Node) string {
+	if platform == nil {
+		return ""
+	}
+
+	osName := yamlfmt.Scalar(yamlfmt.Value(platform, "os"))
+	arch := yamlfmt.Scalar(yamlfmt.Value(platform, "arch"))
+	if osName == "" && arch == "" {
+		return ""
+	}
+	if osName == "" {
+		osName = "linux"
+	}
+	if arch == "" {
+		arch = "amd64"
+	}
+
+	parts := []string{osName, arch}
+	if variant := yamlfmt.Scalar(yamlfmt.Value(platform, "variant")); variant != "" {
+		parts = append(parts, variant)
+	}
+	return strings.Join(parts, "/")
+}
+
+// stepImages returns the images of steps as list [{name: build, image: golang}] or as mapping {build: {image: golang}}
+func stepImages(steps *yaml.Node, platform string) []yamlfmt.Image {
+	items := yamlfmt.Items(steps)
+	if steps != nil && steps.Kind == yaml.MappingNode {
+		_, items = yamlfmt.Pairs(steps)
+	}
+
+	images := make([]yamlfmt.Image, 0)
+	for _, step := range items {
+		image := yamlfmt.Value(step, "image")
+		if image != nil && image.Kind == yaml.ScalarNode {
+			images = append(images, yamlfmt.Image{Node: image, Platform: platform})
+		}
+	}
+	return images
+}
@@ -0,0 +1,118 @@
+package drone
+
+import (
+	"bytes"
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+var log = logrus.New()
+
+func init() {
+	log.SetOutput(bytes.NewBuffer(nil))
+}
+
+const droneFile = `kind: pipeline
+type: docker
+name: amd64
+
+steps:
+  - name: build
+    image: golang:1.21 # build
+    commands: [go build ./...]
+  - name: publish
+    image: plugins/docker
+    settings:
+      repo: example/app:${DRONE_TAG}
+
+services:
+  - name: database
+    image: "postgres:13"
+
+---
+kind: pipeline
+type: docker
+name: arm64
+
+platform:
+  arch: arm64
+
+steps:
+  - name: build
+    image: 'golang:1.21'
+
+---
+kind: secret
+name: token
+get:
+  path: secrets/token
+`
+
+// process processes the content and extends each tag by .1
+func process(t *testing.T, format dockfmt.Format, content string) (found []string, platforms []string, lines []int, output string) {
+	assert.Nil(t, format.ValidateInput(log, strings.NewReader(content), "ci.yml"))
+
+	buffer := bytes.NewBuffer(nil)
+	err := format.(dockfmt.OccurrenceFormat).ProcessOccurrences(log, nil, buffer, func(r dockref.Reference, occurrence dockfmt.Occurrence) (dockref.Reference, error) {
+		found = append(found, r.Original())
+		platforms = append(platforms, occurrence.Platform)
+		lines = append(lines, occurrence.Line)
+		return r.WithTag(r.Tag() + ".1"), nil
+	})
+	assert.Nil(t, err)
+
+	return found, platforms, lines, buffer.String()
+}
+
+func TestDroneName(t *testing.T) {
+	assert.Equal(t, "Drone", New().Name())
+}
+
+func TestDroneValidateInput(t *testing.T) {
+	for content, valid := range map[string]bool{
+		droneFile:                             true,
+		"kind: pipeline\nsteps: []":           true,
+		"kind: secret\nname: token":           false,
+		"steps:\n  - image: golang":           false,
+		"FROM nginx":                          false,
+		"":                                    false,
+		"kind: [":                             false,
+		"services:\n  web:\n    image: nginx": false,
+	} {
+		err := New().ValidateInput(log, strings.NewReader(content), ".drone.yml")
+		if valid {
+			assert.Nil(t, err, content)
+		} else {
+			assert.Error(t, err, content)
+			assert.IsType(t, dockfmt.FormatError{}, err, content)
+		}
+	}
+}
+
+func TestDroneProcessOccurrences(t *testing.T) {
+	found, platforms, lines, output := process(t, New(), droneFile)
+
+	assert.Equal(t, []string{"golang:1.21", "plugins/docker", "postgres:13", "golang:1.21"}, found)
+	assert.Equal(t, []string{"", "", "", "linux/arm64"}, platforms)
+	assert.Equal(t, []int{7, 10, 16, 28}, lines)
+
+	expected := strings.Replace(droneFile, "golang:1.21 #", "golang:1.21.1 #", 1)
+	expected = strings.Replace(expected, `"postgres:13"`, `"postgres:13.1"`, 1)
+	expected = strings.Replace(expected, `'golang:1.21'`, `'golang:1.21.1'`, 1)
+	assert.Equal(t, expected, output)
+}
+
+func TestDronePlatform(t *testing.T) {
+	for content, expected := range map[string]string{
+		"kind: pipeline\nplatform:\n  os: linux\n  arch: arm\n  variant: v7\nsteps: [{image: golang}]": "linux/arm/v7",
+		"kind: pipeline\nplatform:\n  os: windows\nsteps: [{image: golang}]":                           "windows/amd64",
+		"kind: pipeline\nplatform: {}\nsteps: [{image: golang}]":                                       "",
+	} {
+		_, platforms, _, _ := process(t, New(), content)
+		assert.Equal(t, []string{expected}, platforms, content)
+	}
+}
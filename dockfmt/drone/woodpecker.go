@@ -0,0 +1,113 @@
+package drone
+
+import (
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockfmt/yamlfmt"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+	"io"
+	"io/ioutil"
+)
+
+// ensure Format is implemented
+var _ dockfmt.Format = (*woodpeckerFormat)(nil)
+var _ dockfmt.OccurrenceFormat = (*woodpeckerFormat)(nil)
+
+type woodpeckerFormat struct {
+	document *yamlfmt.Document
+	root     *yaml.Node
+}
+
+func (format *woodpeckerFormat) Name() string {
+	return "Woodpecker"
+}
+
+// WoodpeckerNew creates the format of .woodpecker.yml files and the files of .woodpecker/,
+// i.e. a single YAML document with steps and without the kind of Drone
+func WoodpeckerNew() dockfmt.Format {
+	return new(woodpeckerFormat)
+}
+
+func (format *woodpeckerFormat) ValidateInput(log logrus.FieldLogger, reader io.Reader, filename string) error {
+	err := format.validateInput(log, reader, filename)
+	if err != nil {
+		return dockfmt.FormatErrorNew(err)
+	}
+
+	return nil
+}
+
+func (format *woodpeckerFormat) validateInput(log logrus.FieldLogger, reader io.Reader, filename string) error {
+	content, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+
+	document, err := yamlfmt.Parse(content)
+	if err != nil {
+		return err
+	}
+
+	if len(document.Roots) != 1 {
+		return errors.Errorf("Expected a single YAML document, found %d", len(document.Roots))
+	}
+
+	root := yamlfmt.Mapping(document.Roots[0])
+	if yamlfmt.Value(root, "kind") != nil {
+		return errors.New("Documents with kind are Drone pipelines")
+	}
+	if !isSteps(yamlfmt.Value(root, "steps")) && !isSteps(yamlfmt.Value(root, "pipeline")) {
+		return errors.New("No steps found")
+	}
+
+	format.document = document
+	format.root = root
+
+	return nil
+}
+
+// isSteps accepts a list or a mapping of steps, the pipeline: of older versions is a mapping
+func isSteps(steps *yaml.Node) bool {
+	return steps != nil && (steps.Kind == yaml.SequenceNode || steps.Kind == yaml.MappingNode)
+}
+
+func (format *woodpeckerFormat) Process(log logrus.FieldLogger, reader io.Reader, w io.Writer, imageNameProcessor dockfmt.ImageNameProcessor) error {
+	return format.ProcessOccurrences(log, reader, w, func(r dockref.Reference, occurrence dockfmt.Occurrence) (dockref.Reference, error) {
+		return imageNameProcessor(r)
+	})
+}
+
+func (format *woodpeckerFormat) ProcessOccurrences(log logrus.FieldLogger, reader io.Reader, w io.Writer, occurrenceProcessor dockfmt.OccurrenceProcessor) error {
+	err := format.process(log, w, occurrenceProcessor)
+	if err != nil {
+		return dockfmt.FormatErrorNew(err)
+	}
+
+	return nil
+}
+
+func (format *woodpeckerFormat) process(log logrus.FieldLogger, w io.Writer, occurrenceProcessor dockfmt.OccurrenceProcessor) error {
+	err := format.document.ProcessImages(log, format.images(), occurrenceProcessor)
+	if err != nil {
+		return err
+	}
+
+	_, err = format.document.WriteTo(w)
+	return err
+}
+
+// images returns the images of clone, steps and services with the platform of labels: or the older platform:
+func (format *woodpeckerFormat) images() []yamlfmt.Image {
+	platform := yamlfmt.Scalar(yamlfmt.Value(yamlfmt.Value(format.root, "labels"), "platform"))
+	if platform == "" {
+		platform = yamlfmt.Scalar(yamlfmt.Value(format.root, "platform"))
+	}
+
+	images := make([]yamlfmt.Image, 0)
+	for _, key := range []string{"clone", "steps", "pipeline", "services"} {
+		images = append(images, stepImages(yamlfmt.Value(format.root, key), platform)...)
+	}
+	return images
+}
@@ -0,0 +1,75 @@
+package drone
+
+import (
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+const woodpeckerFile = `labels:
+  platform: linux/arm64
+
+clone:
+  git:
+    image: woodpeckerci/plugin-git:2.1
+
+steps:
+  - name: build
+    image: golang:1.21
+    commands: [go build ./...]
+  - name: notify
+    image: plugins/webhook
+    when:
+      event: tag
+
+services:
+  database:
+    image: postgres:13
+`
+
+func TestWoodpeckerName(t *testing.T) {
+	assert.Equal(t, "Woodpecker", WoodpeckerNew().Name())
+}
+
+func TestWoodpeckerValidateInput(t *testing.T) {
+	for content, valid := range map[string]bool{
+		woodpeckerFile:                           true,
+		"steps:\n  build:\n    image: golang":    true,
+		"pipeline:\n  build:\n    image: golang": true,
+		"kind: pipeline\nsteps: []":              false,
+		"steps: make":                            false,
+		"services:\n  web:\n    image: nginx":    false,
+		"steps: []\n---\nsteps: []":              false,
+		"FROM nginx":                             false,
+		"":                                       false,
+	} {
+		err := WoodpeckerNew().ValidateInput(log, strings.NewReader(content), ".woodpecker.yml")
+		if valid {
+			assert.Nil(t, err, content)
+		} else {
+			assert.Error(t, err, content)
+			assert.IsType(t, dockfmt.FormatError{}, err, content)
+		}
+	}
+}
+
+func TestWoodpeckerProcessOccurrences(t *testing.T) {
+	found, platforms, lines, output := process(t, WoodpeckerNew(), woodpeckerFile)
+
+	assert.Equal(t, []string{"woodpeckerci/plugin-git:2.1", "golang:1.21", "plugins/webhook", "postgres:13"}, found)
+	assert.Equal(t, []string{"linux/arm64", "linux/arm64", "linux/arm64", "linux/arm64"}, platforms)
+	assert.Equal(t, []int{6, 10, 13, 19}, lines)
+
+	expected := strings.Replace(woodpeckerFile, "plugin-git:2.1", "plugin-git:2.1.1", 1)
+	expected = strings.Replace(expected, "golang:1.21", "golang:1.21.1", 1)
+	expected = strings.Replace(expected, "postgres:13", "postgres:13.1", 1)
+	assert.Equal(t, expected, output)
+}
+
+func TestWoodpeckerPipelineAndPlatform(t *testing.T) {
+	found, platforms, _, _ := process(t, WoodpeckerNew(), "platform: linux/arm\npipeline:\n  build:\n    image: golang\n")
+
+	assert.Equal(t, []string{"golang"}, found)
+	assert.Equal(t, []string{"linux/arm"}, platforms)
+}
@@ -14,29 +14,98 @@ type Format interface {
 }
 type ImageNameProcessor func(r dockref.Reference) (dockref.Reference, error)
 
+// Occurrence describes where an image reference was found in the input
+type Occurrence struct {
+	// Line is the 1-based line of the reference, 0 when unknown
+	Line int
+	// Platform is the platform requested for the image as written in the input, e.g. linux/arm64 or $TARGETPLATFORM.
+	// It is empty when the input doesn't request a platform.
+	Platform string
+	// File is the file of the reference when it is not the input, e.g. a file included by the input, empty otherwise
+	File string
+}
+
+type OccurrenceProcessor func(r dockref.Reference, occurrence Occurrence) (dockref.Reference, error)
+
+// OccurrenceFormat is implemented by Formats that know where image references occur in the input
+type OccurrenceFormat interface {
+	Format
+	ProcessOccurrences(log logrus.FieldLogger, reader io.Reader, writer io.Writer, occurrenceProcessor OccurrenceProcessor) error
+}
+
+// FileWriter writes the changes of a file other than the input
+type FileWriter func(filename string, content []byte) error
+
+// FileFormat is implemented by Formats that process other files along with the input, e.g. included files
+type FileFormat interface {
+	Format
+	// Files returns the other files found by ValidateInput
+	Files() []string
+	// SetFileWriter replaces how changes of the other files are written, nil writes them in place
+	SetFileWriter(writer FileWriter)
+}
+
 type FormatProcessor interface {
 	Process(imageNameProcessor ImageNameProcessor) error
+	ProcessOccurrences(occurrenceProcessor OccurrenceProcessor) error
 	WithWriter(writer io.Writer) FormatProcessor
+	// WithFileWriter writes the changes of other files than the input with writer, see FileFormat
+	WithFileWriter(writer FileWriter) FormatProcessor
+	// Files returns the other files processed along with the input, see FileFormat
+	Files() []string
 }
 
 var _ FormatProcessor = (*formatProcessor)(nil)
 
 type formatProcessor struct {
-	format Format
-	log    logrus.FieldLogger
-	reader io.Reader
-	writer io.Writer
+	format     Format
+	log        logrus.FieldLogger
+	reader     io.Reader
+	writer     io.Writer
+	fileWriter FileWriter
 }
 
 func (fp *formatProcessor) Process(imageNameProcessor ImageNameProcessor) error {
+	fp.setFileWriter()
 	return fp.format.Process(fp.log, fp.reader, fp.writer, imageNameProcessor)
 }
 
+// ProcessOccurrences falls back to an unknown Occurrence for formats that don't implement OccurrenceFormat
+func (fp *formatProcessor) ProcessOccurrences(occurrenceProcessor OccurrenceProcessor) error {
+	fp.setFileWriter()
+	if format, ok := fp.format.(OccurrenceFormat); ok {
+		return format.ProcessOccurrences(fp.log, fp.reader, fp.writer, occurrenceProcessor)
+	}
+
+	return fp.format.Process(fp.log, fp.reader, fp.writer, func(r dockref.Reference) (dockref.Reference, error) {
+		return occurrenceProcessor(r, Occurrence{})
+	})
+}
+
 func (fp *formatProcessor) WithWriter(writer io.Writer) FormatProcessor {
 	fp.writer = writer
 	return fp
 }
 
+func (fp *formatProcessor) WithFileWriter(writer FileWriter) FormatProcessor {
+	fp.fileWriter = writer
+	return fp
+}
+
+// setFileWriter passes the file writer on, formats are shared and would otherwise keep the writer of a previous processor
+func (fp *formatProcessor) setFileWriter() {
+	if format, ok := fp.format.(FileFormat); ok {
+		format.SetFileWriter(fp.fileWriter)
+	}
+}
+
+func (fp *formatProcessor) Files() []string {
+	if format, ok := fp.format.(FileFormat); ok {
+		return format.Files()
+	}
+	return nil
+}
+
 func FormatProcessorNew(format Format,
 	log logrus.FieldLogger,
 	reader io.Reader) FormatProcessor {
@@ -1,9 +1,11 @@
 package dockfmt
 
 import (
+	"bytes"
 	"github.com/hashicorp/go-multierror"
 	"github.com/sirupsen/logrus"
 	"io"
+	"io/ioutil"
 )
 
 type FormatProvider interface {
@@ -41,9 +43,19 @@ type AmbiguousFormatError struct {
 	Formats []Format
 }
 
+// IdentifyFormat returns the only format that accepts the input, each format reads the whole input
 func IdentifyFormat(log logrus.FieldLogger, formatProvider FormatProvider, reader io.Reader, filename string) (Format, error) {
 	formats := formatProvider.Formats()
 
+	var content []byte
+	if reader != nil {
+		var err error
+		content, err = ioutil.ReadAll(reader)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	log = log.WithFields(logrus.Fields{
 		"filename":     filename,
 		"knownFormats": formats,
@@ -52,7 +64,7 @@ func IdentifyFormat(log logrus.FieldLogger, formatProvider FormatProvider, reade
 	var format Format
 	var formatErrors error
 	for _, p := range formats {
-		validationErr := p.ValidateInput(log, reader, filename)
+		validationErr := p.ValidateInput(log, bytes.NewReader(content), filename)
 		if validationErr != nil {
 			formatErrors = multierror.Append(formatErrors, validationErr)
 			log.WithFields(logrus.Fields{
@@ -46,6 +46,27 @@ func TestFormatProcessor_ProcessPassesLogAndReaderAndImageProcessorAndWriter(t *
 	formatMock.Mock.AssertNumberOfCalls(t, "Process", 1)
 }
 
+func TestFormatProcessor_ProcessOccurrencesFallsBackToProcess(t *testing.T) {
+	log := logrus.New()
+
+	formatMock := new(FormatMock)
+	reader := strings.NewReader("input")
+	formatMock.On("Process", log, reader, bytes.NewBuffer(nil), mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		imageNameProcessor := args.Get(3).(ImageNameProcessor)
+		imageNameProcessor(dockref.MustParse("nginx"))
+	})
+
+	var occurrences []Occurrence
+	processor := FormatProcessorNew(formatMock, log, reader)
+	err := processor.ProcessOccurrences(func(r dockref.Reference, occurrence Occurrence) (dockref.Reference, error) {
+		occurrences = append(occurrences, occurrence)
+		return r, nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []Occurrence{{}}, occurrences)
+}
+
 func TestFormatError(t *testing.T) {
 	err := errors.New("test")
 	formatError := FormatErrorNew(err)
@@ -0,0 +1,363 @@
+package gitlabci
+
+import (
+	"bytes"
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockfmt/yamlfmt"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func init() {
+	dockfmt.RegisterFormat(New())
+}
+
+// ensure Format is implemented
+var _ dockfmt.Format = (*gitlabCiFormat)(nil)
+var _ dockfmt.OccurrenceFormat = (*gitlabCiFormat)(nil)
+var _ dockfmt.FileFormat = (*gitlabCiFormat)(nil)
+
+// keywords are the top level keys that are no jobs
+var keywords = map[string]bool{
+	"after_script":  true,
+	"before_script": true,
+	"cache":         true,
+	"default":       true,
+	"image":         true,
+	"include":       true,
+	"services":      true,
+	"stages":        true,
+	"variables":     true,
+	"workflow":      true,
+}
+
+type gitlabCiFormat struct {
+	document *yamlfmt.Document
+	root     *yaml.Node
+	// includes are the local files included by the input and by the included files
+	includes []includedFile
+
+	readFile  func(filename string) ([]byte, error)
+	writeFile dockfmt.FileWriter
+	glob      func(pattern string) ([]string, error)
+}
+
+type includedFile struct {
+	filename string
+	document *yamlfmt.Document
+	root     *yaml.Node
+}
+
+func (format *gitlabCiFormat) Name() string {
+	return "GitLab CI"
+}
+
+// New creates the format of .gitlab-ci.yml files, local files of include are processed as well
+func New() dockfmt.Format {
+	return &gitlabCiFormat{
+		readFile:  ioutil.ReadFile,
+		writeFile: yamlfmt.WriteFile,
+		glob:      filepath.Glob,
+	}
+}
+
+func (format *gitlabCiFormat) ValidateInput(log logrus.FieldLogger, reader io.Reader, filename string) error {
+	err := format.validateInput(log, reader, filename)
+	if err != nil {
+		return dockfmt.FormatErrorNew(err)
+	}
+
+	return nil
+}
+
+func (format *gitlabCiFormat) validateInput(log logrus.FieldLogger, reader io.Reader, filename string) error {
+	content, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+
+	document, root, err := parse(content)
+	if err != nil {
+		return err
+	}
+
+	if !isGitlabCi(root, filename) {
+		return errors.New("No GitLab CI jobs found")
+	}
+
+	format.document = document
+	format.root = root
+	format.includes = nil
+
+	visited := map[string]bool{filepath.Clean(filename): true}
+	return format.readIncludes(log, filepath.Dir(filename), root, visited)
+}
+
+// Files returns the local files included by the input
+func (format *gitlabCiFormat) Files() []string {
+	files := make([]string, 0, len(format.includes))
+	for _, include := range format.includes {
+		files = append(files, include.filename)
+	}
+	return files
+}
+
+func (format *gitlabCiFormat) SetFileWriter(writer dockfmt.FileWriter) {
+	if writer == nil {
+		writer = yamlfmt.WriteFile
+	}
+	format.writeFile = writer
+}
+
+// parse returns the mapping of the configuration, a header document with spec: is skipped
+func parse(content []byte) (*yamlfmt.Document, *yaml.Node, error) {
+	document, err := yamlfmt.Parse(content)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	roots := document.Roots
+	if len(roots) == 2 && yamlfmt.Value(yamlfmt.Mapping(roots[0]), "spec") != nil {
+		roots = roots[1:]
+	}
+	if len(roots) != 1 {
+		return nil, nil, errors.Errorf("Expected a single YAML document, found %d", len(document.Roots))
+	}
+
+	root := yamlfmt.Mapping(roots[0])
+	if root == nil {
+		return nil, nil, errors.New("Expected a mapping")
+	}
+	return document, root, nil
+}
+
+// isGitlabCi accepts .gitlab-ci.yml files and files with jobs, services as mapping are left to docker-compose
+func isGitlabCi(root *yaml.Node, filename string) bool {
+	services := yamlfmt.Value(root, "services")
+	if services != nil && services.Kind == yaml.MappingNode {
+		return false
+	}
+
+	base := filepath.Base(filename)
+	if base == ".gitlab-ci.yml" || base == ".gitlab-ci.yaml" {
+		return true
+	}
+
+	// jobs are mappings, other top level values like version: 2.1 of CircleCI are no GitLab CI
+	hasJob := false
+	keys, values := yamlfmt.Pairs(root)
+	for i, key := range keys {
+		if keywords[key] || strings.HasPrefix(key, ".") && values[i].Kind != yaml.MappingNode {
+			continue
+		}
+		if values[i].Kind != yaml.MappingNode {
+			return false
+		}
+		if isJob(values[i]) {
+			hasJob = true
+		}
+	}
+	return hasJob
+}
+
+// isJob accepts mappings with script, extends, run or trigger, e.g. the steps of Woodpecker named run are mappings and no jobs
+func isJob(job *yaml.Node) bool {
+	for _, key := range []string{"script", "extends", "run"} {
+		value := yamlfmt.Value(job, key)
+		if value != nil && (value.Kind == yaml.ScalarNode || value.Kind == yaml.SequenceNode) {
+			return true
+		}
+	}
+
+	trigger := yamlfmt.Value(job, "trigger")
+	if trigger != nil && trigger.Kind == yaml.ScalarNode {
+		return true
+	}
+	return yamlfmt.Value(trigger, "project") != nil || yamlfmt.Value(trigger, "include") != nil
+}
+
+// readIncludes reads the local files of include:, relative to the directory of the input, i.e. the root of the repository
+func (format *gitlabCiFormat) readIncludes(log logrus.FieldLogger, dir string, root *yaml.Node, visited map[string]bool) error {
+	for _, local := range localIncludes(log, yamlfmt.Value(root, "include")) {
+		filenames := []string{filepath.Join(dir, local)}
+		if strings.Contains(local, "*") {
+			matches, err := format.glob(filepath.Join(dir, local))
+			if err != nil {
+				return errors.Wrapf(err, "invalid include %s", local)
+			}
+			filenames = matches
+		}
+
+		for _, filename := range filenames {
+			filename = filepath.Clean(filename)
+			if visited[filename] {
+				continue
+			}
+			visited[filename] = true
+
+			content, err := format.readFile(filename)
+			if os.IsNotExist(err) {
+				log.Warnf("Skipping include %s, the file doesn't exist", filename)
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			document, included, err := parse(content)
+			if err != nil {
+				return errors.Wrapf(err, "invalid include %s", filename)
+			}
+
+			format.includes = append(format.includes, includedFile{filename: filename, document: document, root: included})
+			err = format.readIncludes(log, dir, included, visited)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// localIncludes returns the paths of include: 'file.yml', include: [...] and include: {local: file.yml},
+// remote files, templates, components and files of other projects are skipped
+func localIncludes(log logrus.FieldLogger, include *yaml.Node) []string {
+	if include == nil {
+		return nil
+	}
+
+	items := []*yaml.Node{include}
+	if include.Kind == yaml.SequenceNode {
+		items = yamlfmt.Items(include)
+	}
+
+	locals := make([]string, 0)
+	for _, item := range items {
+		local := yamlfmt.Scalar(item)
+		if item.Kind == yaml.MappingNode {
+			local = yamlfmt.Scalar(yamlfmt.Value(item, "local"))
+		}
+
+		if local == "" || strings.Contains(local, "://") || strings.Contains(local, "$") {
+			log.Infof("Skipping include in line %d, only local files are followed", item.Line)
+			continue
+		}
+		locals = append(locals, strings.TrimPrefix(local, "/"))
+	}
+	return locals
+}
+
+func (format *gitlabCiFormat) Process(log logrus.FieldLogger, reader io.Reader, w io.Writer, imageNameProcessor dockfmt.ImageNameProcessor) error {
+	return format.ProcessOccurrences(log, reader, w, func(r dockref.Reference, occurrence dockfmt.Occurrence) (dockref.Reference, error) {
+		return imageNameProcessor(r)
+	})
+}
+
+func (format *gitlabCiFormat) ProcessOccurrences(log logrus.FieldLogger, reader io.Reader, w io.Writer, occurrenceProcessor dockfmt.OccurrenceProcessor) error {
+	err := format.process(log, w, occurrenceProcessor)
+	if err != nil {
+		return dockfmt.FormatErrorNew(err)
+	}
+
+	return nil
+}
+
+func (format *gitlabCiFormat) process(log logrus.FieldLogger, w io.Writer, occurrenceProcessor dockfmt.OccurrenceProcessor) error {
+	err := format.document.ProcessImages(log, images(format.root), occurrenceProcessor)
+	if err != nil {
+		return err
+	}
+
+	for _, include := range format.includes {
+		filename := include.filename
+		err := include.document.ProcessImages(log, images(include.root), func(r dockref.Reference, occurrence dockfmt.Occurrence) (dockref.Reference, error) {
+			occurrence.File = filename
+			return occurrenceProcessor(r, occurrence)
+		})
+		if err != nil {
+			return errors.Wrapf(err, "in include %s", filename)
+		}
+	}
+
+	_, err = format.document.WriteTo(w)
+	if err != nil {
+		return err
+	}
+
+	for _, include := range format.includes {
+		if !include.document.Changed() {
+			continue
+		}
+
+		err := format.writeInclude(log, include)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (format *gitlabCiFormat) writeInclude(log logrus.FieldLogger, include includedFile) error {
+	log.Infof("Updating %s", include.filename)
+
+	buffer := bytes.NewBuffer(nil)
+	_, err := include.document.WriteTo(buffer)
+	if err != nil {
+		return err
+	}
+	return format.writeFile(include.filename, buffer.Bytes())
+}
+
+// images returns the images and services of the global keywords, of default: and of the jobs in order
+func images(root *yaml.Node) []yamlfmt.Image {
+	images := make([]yamlfmt.Image, 0)
+
+	keys, values := yamlfmt.Pairs(root)
+	for i, key := range keys {
+		switch {
+		case key == "image":
+			images = append(images, imagesOf(values[i])...)
+		case key == "services":
+			images = append(images, servicesOf(values[i])...)
+		case key == "default" || !keywords[key]:
+			images = append(images, imagesOf(yamlfmt.Value(values[i], "image"))...)
+			images = append(images, servicesOf(yamlfmt.Value(values[i], "services"))...)
+		}
+	}
+
+	return images
+}
+
+func servicesOf(services *yaml.Node) []yamlfmt.Image {
+	images := make([]yamlfmt.Image, 0)
+	for _, service := range yamlfmt.Items(services) {
+		images = append(images, imagesOf(service)...)
+	}
+	return images
+}
+
+// imagesOf supports the forms image: nginx and image: {name: nginx, docker: {platform: arm64}}
+func imagesOf(node *yaml.Node) []yamlfmt.Image {
+	if node == nil {
+		return nil
+	}
+
+	platform := ""
+	if node.Kind == yaml.MappingNode {
+		platform = yamlfmt.Scalar(yamlfmt.Value(yamlfmt.Value(node, "docker"), "platform"))
+		node = yamlfmt.Value(node, "name")
+	}
+
+	if node == nil || node.Kind != yaml.ScalarNode {
+		return nil
+	}
+	return []yamlfmt.Image{{Node: node, Platform: platform}}
+}
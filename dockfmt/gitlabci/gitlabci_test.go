@@ -0,0 +1,209 @@
+package gitlabci
+
+import (
+	"bytes"
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+var log = logrus.New()
+
+func init() {
+	log.SetOutput(bytes.NewBuffer(nil))
+}
+
+const gitlabCiFile = `image: ruby:3.2 # global
+
+services:
+  - postgres:13
+
+include:
+  - local: /ci/templates.yml
+  - remote: https://example.com/ci.yml
+  - template: Auto-DevOps.gitlab-ci.yml
+  - project: group/project
+    file: ci.yml
+
+default:
+  image: alpine:3.18
+  services:
+    - name: redis:6
+      alias: cache
+
+stages: [build, test]
+
+variables:
+  image: not-an-image:1
+
+.node: &node
+  image:
+    name: "node:18"
+    docker:
+      platform: linux/arm64
+
+build:
+  <<: *node
+  stage: build
+  script: npm run build
+
+test:
+  extends: .node
+  image: $CI_REGISTRY_IMAGE:latest
+  services: ["mysql:8"]
+  script: npm test
+`
+
+const templatesFile = `include: /ci/more/*.yml
+
+.docker:
+  image: docker:24
+  services:
+    - docker:24-dind
+`
+
+// formatWith creates the format with the files of a test, written files are returned
+func formatWith(files map[string]string) (*gitlabCiFormat, map[string]string) {
+	written := make(map[string]string)
+
+	format := New().(*gitlabCiFormat)
+	format.readFile = func(filename string) ([]byte, error) {
+		content, ok := files[filename]
+		if !ok {
+			return nil, &os.PathError{Op: "open", Path: filename, Err: os.ErrNotExist}
+		}
+		return []byte(content), nil
+	}
+	format.writeFile = func(filename string, content []byte) error {
+		written[filename] = string(content)
+		return nil
+	}
+	format.glob = func(pattern string) ([]string, error) {
+		matches := make([]string, 0)
+		for filename := range files {
+			if ok, _ := filepath.Match(pattern, filename); ok {
+				matches = append(matches, filename)
+			}
+		}
+		sort.Strings(matches)
+		return matches, nil
+	}
+
+	return format, written
+}
+
+func TestGitlabCiName(t *testing.T) {
+	assert.Equal(t, "GitLab CI", New().Name())
+}
+
+func TestGitlabCiValidateInput(t *testing.T) {
+	for content, valid := range map[string]bool{
+		gitlabCiFile:                          true,
+		"build:\n  script: make":              true,
+		".template:\n  extends: .base":        true,
+		"deploy:\n  trigger: group/project":   true,
+		"FROM nginx":                          false,
+		"":                                    false,
+		"services:\n  web:\n    image: nginx": false,
+		"image: nginx":                        false,
+		"script: make":                        false,
+		"build: [":                            false,
+		"spec:\n  inputs: {}\n---\nbuild:\n  script: make":    true,
+		"build:\n  script: make\n---\nbuild:\n  script: make": false,
+	} {
+		format, _ := formatWith(nil)
+		err := format.ValidateInput(log, strings.NewReader(content), "ci.yml")
+		if valid {
+			assert.Nil(t, err, content)
+		} else {
+			assert.Error(t, err, content)
+			assert.IsType(t, dockfmt.FormatError{}, err, content)
+		}
+	}
+
+	format, _ := formatWith(nil)
+	assert.Nil(t, format.ValidateInput(log, strings.NewReader("image: nginx"), "project/.gitlab-ci.yml"), "the name identifies .gitlab-ci.yml")
+}
+
+func TestGitlabCiProcessOccurrences(t *testing.T) {
+	files := map[string]string{
+		"project/ci/templates.yml":   templatesFile,
+		"project/ci/more/deploy.yml": "deploy:\n  image: 'alpine:3.18'\n  script: deploy\n",
+		"project/ci/more/readme.md":  "not included",
+	}
+	format, written := formatWith(files)
+	assert.Nil(t, format.ValidateInput(log, strings.NewReader(gitlabCiFile), "project/.gitlab-ci.yml"))
+
+	found := make([]string, 0)
+	platforms := make([]string, 0)
+	lines := make([]int, 0)
+	occurrenceFiles := make([]string, 0)
+	buffer := bytes.NewBuffer(nil)
+	err := format.ProcessOccurrences(log, nil, buffer, func(r dockref.Reference, occurrence dockfmt.Occurrence) (dockref.Reference, error) {
+		found = append(found, r.Original())
+		platforms = append(platforms, occurrence.Platform)
+		lines = append(lines, occurrence.Line)
+		occurrenceFiles = append(occurrenceFiles, occurrence.File)
+		return r.WithTag(r.Tag() + ".1"), nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"ruby:3.2", "postgres:13", "alpine:3.18", "redis:6", "node:18", "mysql:8", "docker:24", "docker:24-dind", "alpine:3.18"}, found)
+	assert.Equal(t, []string{"", "", "", "", "linux/arm64", "", "", "", ""}, platforms)
+	assert.Equal(t, []int{1, 4, 14, 16, 26, 38, 4, 6, 2}, lines)
+	assert.Equal(t, []string{"", "", "", "", "", "", "project/ci/templates.yml", "project/ci/templates.yml", "project/ci/more/deploy.yml"}, occurrenceFiles)
+
+	expected := strings.Replace(gitlabCiFile, "ruby:3.2 #", "ruby:3.2.1 #", 1)
+	expected = strings.Replace(expected, "- postgres:13", "- postgres:13.1", 1)
+	expected = strings.Replace(expected, "alpine:3.18", "alpine:3.18.1", 1)
+	expected = strings.Replace(expected, "redis:6", "redis:6.1", 1)
+	expected = strings.Replace(expected, `"node:18"`, `"node:18.1"`, 1)
+	expected = strings.Replace(expected, `"mysql:8"`, `"mysql:8.1"`, 1)
+	assert.Equal(t, expected, buffer.String())
+
+	assert.Equal(t, map[string]string{
+		"project/ci/templates.yml":   strings.Replace(strings.Replace(templatesFile, "docker:24\n", "docker:24.1\n", 1), "docker:24-dind", "docker:24-dind.1", 1),
+		"project/ci/more/deploy.yml": "deploy:\n  image: 'alpine:3.18.1'\n  script: deploy\n",
+	}, written)
+}
+
+func TestGitlabCiProcessKeepsUnchangedFiles(t *testing.T) {
+	format, written := formatWith(map[string]string{"ci/templates.yml": templatesFile})
+	assert.Nil(t, format.ValidateInput(log, strings.NewReader(gitlabCiFile), ".gitlab-ci.yml"))
+
+	buffer := bytes.NewBuffer(nil)
+	err := format.Process(log, nil, buffer, func(r dockref.Reference) (dockref.Reference, error) {
+		return r, nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, gitlabCiFile, buffer.String())
+	assert.Empty(t, written)
+}
+
+func TestGitlabCiIncludes(t *testing.T) {
+	files := map[string]string{
+		"a.yml": "include: b.yml\na:\n  image: nginx\n  script: a\n",
+		"b.yml": "include: [a.yml, missing.yml]\nb:\n  image: redis\n  script: b\n",
+	}
+	format, _ := formatWith(files)
+	assert.Nil(t, format.ValidateInput(log, strings.NewReader("include: 'a.yml'\n"), ".gitlab-ci.yml"))
+
+	found := make([]string, 0)
+	err := format.Process(log, nil, bytes.NewBuffer(nil), func(r dockref.Reference) (dockref.Reference, error) {
+		found = append(found, r.Original())
+		return r, nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"nginx", "redis"}, found, "each file is included once, missing files are skipped")
+
+	format, _ = formatWith(map[string]string{"broken.yml": "a: ["})
+	err = format.ValidateInput(log, strings.NewReader("include: broken.yml\n"), ".gitlab-ci.yml")
+	assert.Error(t, err)
+}
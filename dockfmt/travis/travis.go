@@ -0,0 +1,141 @@
+package travis
+
+import (
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockfmt/yamlfmt"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+)
+
+func init() {
+	dockfmt.RegisterFormat(New())
+}
+
+// ensure Format is implemented
+var _ dockfmt.Format = (*travisFormat)(nil)
+var _ dockfmt.OccurrenceFormat = (*travisFormat)(nil)
+
+type travisFormat struct {
+	document *yamlfmt.Document
+	root     *yaml.Node
+}
+
+func (format *travisFormat) Name() string {
+	return "Travis CI"
+}
+
+// New creates the format of .travis.yml files, only image keys are image references.
+// Images pulled by scripts or started as docker service are unknown.
+func New() dockfmt.Format {
+	return new(travisFormat)
+}
+
+func (format *travisFormat) ValidateInput(log logrus.FieldLogger, reader io.Reader, filename string) error {
+	err := format.validateInput(log, reader, filename)
+	if err != nil {
+		return dockfmt.FormatErrorNew(err)
+	}
+
+	return nil
+}
+
+func (format *travisFormat) validateInput(log logrus.FieldLogger, reader io.Reader, filename string) error {
+	content, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+
+	document, err := yamlfmt.Parse(content)
+	if err != nil {
+		return err
+	}
+
+	if len(document.Roots) != 1 {
+		return errors.Errorf("Expected a single YAML document, found %d", len(document.Roots))
+	}
+
+	root := yamlfmt.Mapping(document.Roots[0])
+	if root == nil {
+		return errors.New("Expected a mapping")
+	}
+
+	if filepath.Base(filename) != ".travis.yml" {
+		language := yamlfmt.Value(root, "language")
+		if language == nil || language.Kind != yaml.ScalarNode {
+			return errors.New("No language found")
+		}
+		if !hasTravisKey(root) {
+			return errors.New("No keys of Travis CI found")
+		}
+	}
+
+	format.document = document
+	format.root = root
+
+	return nil
+}
+
+// hasTravisKey tells files with a language like mkdocs.yml from Travis CI configurations
+func hasTravisKey(root *yaml.Node) bool {
+	for _, key := range []string{"script", "dist", "os"} {
+		if yamlfmt.Value(root, key) != nil {
+			return true
+		}
+	}
+	for _, key := range []string{"jobs", "matrix"} {
+		if jobs := yamlfmt.Mapping(yamlfmt.Value(root, key)); jobs != nil && yamlfmt.Value(jobs, "include") != nil {
+			return true
+		}
+	}
+	services := yamlfmt.Value(root, "services")
+	return services != nil && services.Kind == yaml.SequenceNode
+}
+
+func (format *travisFormat) Process(log logrus.FieldLogger, reader io.Reader, w io.Writer, imageNameProcessor dockfmt.ImageNameProcessor) error {
+	return format.ProcessOccurrences(log, reader, w, func(r dockref.Reference, occurrence dockfmt.Occurrence) (dockref.Reference, error) {
+		return imageNameProcessor(r)
+	})
+}
+
+func (format *travisFormat) ProcessOccurrences(log logrus.FieldLogger, reader io.Reader, w io.Writer, occurrenceProcessor dockfmt.OccurrenceProcessor) error {
+	err := format.process(log, w, occurrenceProcessor)
+	if err != nil {
+		return dockfmt.FormatErrorNew(err)
+	}
+
+	return nil
+}
+
+func (format *travisFormat) process(log logrus.FieldLogger, w io.Writer, occurrenceProcessor dockfmt.OccurrenceProcessor) error {
+	err := format.document.ProcessImages(log, format.images(), occurrenceProcessor)
+	if err != nil {
+		return err
+	}
+
+	_, err = format.document.WriteTo(w)
+	return err
+}
+
+// images returns the global image and the images of the jobs of jobs: include: and its alias matrix:
+func (format *travisFormat) images() []yamlfmt.Image {
+	images := make([]yamlfmt.Image, 0)
+
+	jobs := []*yaml.Node{format.root}
+	for _, key := range []string{"jobs", "matrix"} {
+		jobs = append(jobs, yamlfmt.Items(yamlfmt.Value(yamlfmt.Value(format.root, key), "include"))...)
+	}
+
+	for _, job := range jobs {
+		image := yamlfmt.Value(job, "image")
+		if image != nil && image.Kind == yaml.ScalarNode {
+			images = append(images, yamlfmt.Image{Node: image})
+		}
+	}
+
+	return images
+}
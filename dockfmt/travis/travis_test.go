@@ -0,0 +1,97 @@
+package travis
+
+import (
+	"bytes"
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+var log = logrus.New()
+
+func init() {
+	log.SetOutput(bytes.NewBuffer(nil))
+}
+
+const travisFile = `language: minimal
+image: ubuntu:22.04
+
+services:
+  - docker
+
+script:
+  - docker run --rm alpine:3.18 true
+
+jobs:
+  include:
+    - name: build
+      image: "golang:1.21" # build image
+    - name: lint
+      script: make lint
+
+matrix:
+  include:
+    - image: 'node:18'
+`
+
+func TestTravisName(t *testing.T) {
+	assert.Equal(t, "Travis CI", New().Name())
+}
+
+func TestTravisValidateInput(t *testing.T) {
+	for content, valid := range map[string]bool{
+		travisFile:                               true,
+		"language: go\nscript: make":             true,
+		"language: go\ndist: focal":              true,
+		"language: go\nos: linux":                true,
+		"language: go\njobs:\n  include: []":     true,
+		"language: go\nmatrix:\n  include: []":   true,
+		"language: go\nservices: [docker]":       true,
+		"language: go":                           false,
+		"language: en\nsite_name: docs\nnav: []": false,
+		"language: go\nservices:\n  db: {}":      false,
+		"language: go\njobs:\n  build: {}":       false,
+		"FROM nginx":                             false,
+		"":                                       false,
+		"image: nginx":                           false,
+		"language: [go]":                         false,
+		"language: go\n---\nlanguage: go":        false,
+		"language: [":                            false,
+	} {
+		err := New().ValidateInput(log, strings.NewReader(content), "ci.yml")
+		if valid {
+			assert.Nil(t, err, content)
+		} else {
+			assert.Error(t, err, content)
+			assert.IsType(t, dockfmt.FormatError{}, err, content)
+		}
+	}
+
+	assert.Nil(t, New().ValidateInput(log, strings.NewReader("os: linux"), "project/.travis.yml"), "the name identifies .travis.yml")
+}
+
+func TestTravisProcessOccurrences(t *testing.T) {
+	format := New()
+	assert.Nil(t, format.ValidateInput(log, strings.NewReader(travisFile), ".travis.yml"))
+
+	found := make([]string, 0)
+	lines := make([]int, 0)
+	buffer := bytes.NewBuffer(nil)
+	err := format.(dockfmt.OccurrenceFormat).ProcessOccurrences(log, nil, buffer, func(r dockref.Reference, occurrence dockfmt.Occurrence) (dockref.Reference, error) {
+		found = append(found, r.Original())
+		lines = append(lines, occurrence.Line)
+		return r.WithTag(r.Tag() + ".1"), nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"ubuntu:22.04", "golang:1.21", "node:18"}, found, "services and scripts are not known")
+	assert.Equal(t, []int{2, 13, 19}, lines)
+
+	expected := strings.Replace(travisFile, "ubuntu:22.04", "ubuntu:22.04.1", 1)
+	expected = strings.Replace(expected, `"golang:1.21"`, `"golang:1.21.1"`, 1)
+	expected = strings.Replace(expected, `'node:18'`, `'node:18.1'`, 1)
+	assert.Equal(t, expected, buffer.String())
+}
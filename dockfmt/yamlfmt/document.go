@@ -0,0 +1,279 @@
+// Package yamlfmt finds and replaces scalars of YAML inputs without changing comments, anchors, key order or formatting
+package yamlfmt
+
+import (
+	"bytes"
+	"github.com/MeneDev/dockmoor/dockfmt"
+	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Document is a YAML input of one or more documents, scalars are replaced in the original text
+type Document struct {
+	content      []byte
+	Roots        []*yaml.Node
+	replacements map[*yaml.Node]string
+}
+
+// Parse parses all documents of the content
+func Parse(content []byte) (*Document, error) {
+	document := &Document{
+		content:      content,
+		Roots:        make([]*yaml.Node, 0),
+		replacements: make(map[*yaml.Node]string),
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	for {
+		root := new(yaml.Node)
+		err := decoder.Decode(root)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		document.Roots = append(document.Roots, root)
+	}
+
+	return document, nil
+}
+
+// Mapping returns the top level mapping of a root, nil for other documents
+func Mapping(root *yaml.Node) *yaml.Node {
+	node := resolve(root)
+	if node != nil && node.Kind == yaml.DocumentNode && len(node.Content) == 1 {
+		node = resolve(node.Content[0])
+	}
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	return node
+}
+
+// Value returns the value of the key in the mapping, following aliases and merge keys like <<: *defaults.
+// It returns nil when node is no mapping or the key is missing.
+func Value(node *yaml.Node, key string) *yaml.Node {
+	mapping := resolve(node)
+	if mapping == nil || mapping.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	var merged *yaml.Node
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		k, v := mapping.Content[i], mapping.Content[i+1]
+		if k.Value == key {
+			return resolve(v)
+		}
+		if k.Value == "<<" && merged == nil {
+			merged = mergedValue(v, key)
+		}
+	}
+	return merged
+}
+
+// mergedValue looks for the key in the mappings of a merge key, earlier mappings take precedence
+func mergedValue(merge *yaml.Node, key string) *yaml.Node {
+	merge = resolve(merge)
+	if merge == nil {
+		return nil
+	}
+	if merge.Kind == yaml.SequenceNode {
+		for _, item := range merge.Content {
+			if value := Value(item, key); value != nil {
+				return value
+			}
+		}
+		return nil
+	}
+	return Value(merge, key)
+}
+
+// Scalar returns the value of a scalar node, the empty string for other nodes
+func Scalar(node *yaml.Node) string {
+	node = resolve(node)
+	if node == nil || node.Kind != yaml.ScalarNode {
+		return ""
+	}
+	return node.Value
+}
+
+// Pairs returns the keys and values of a mapping in order, values follow aliases
+func Pairs(node *yaml.Node) (keys []string, values []*yaml.Node) {
+	mapping := resolve(node)
+	if mapping == nil || mapping.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		keys = append(keys, mapping.Content[i].Value)
+		values = append(values, resolve(mapping.Content[i+1]))
+	}
+	return keys, values
+}
+
+// Items returns the items of a sequence, following aliases
+func Items(node *yaml.Node) []*yaml.Node {
+	sequence := resolve(node)
+	if sequence == nil || sequence.Kind != yaml.SequenceNode {
+		return nil
+	}
+	items := make([]*yaml.Node, 0, len(sequence.Content))
+	for _, item := range sequence.Content {
+		items = append(items, resolve(item))
+	}
+	return items
+}
+
+func resolve(node *yaml.Node) *yaml.Node {
+	for node != nil && node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	return node
+}
+
+// Replace replaces the value of the scalar node when the document is written
+func (document *Document) Replace(node *yaml.Node, value string) {
+	document.replacements[node] = value
+}
+
+// Changed reports whether any scalar is replaced
+func (document *Document) Changed() bool {
+	return len(document.replacements) > 0
+}
+
+// WriteFile writes the content to the file, the mode of an existing file is kept
+func WriteFile(filename string, content []byte) error {
+	mode := os.FileMode(0660)
+
+	info, err := os.Stat(filename)
+	if err == nil {
+		mode = info.Mode()
+	}
+
+	return ioutil.WriteFile(filename, content, mode)
+}
+
+// WriteTo writes the original content with the replaced scalars
+func (document *Document) WriteTo(writer io.Writer) (int64, error) {
+	lines := strings.SplitAfter(string(document.content), "\n")
+
+	nodes := make([]*yaml.Node, 0, len(document.replacements))
+	for node := range document.replacements {
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Line < nodes[j].Line || nodes[i].Line == nodes[j].Line && nodes[i].Column > nodes[j].Column
+	})
+
+	for _, node := range nodes {
+		if node.Line < 1 || node.Line > len(lines) {
+			return 0, errors.Errorf("cannot replace %s, line %d is out of range", node.Value, node.Line)
+		}
+		line, err := replaceScalar(lines[node.Line-1], node, document.replacements[node])
+		if err != nil {
+			return 0, err
+		}
+		lines[node.Line-1] = line
+	}
+
+	n, err := io.WriteString(writer, strings.Join(lines, ""))
+	return int64(n), err
+}
+
+// replaceScalar replaces the scalar in its line, the scalar starts at its column or after its anchor or tag
+func replaceScalar(line string, node *yaml.Node, value string) (string, error) {
+	original, err := quoted(node.Value, node.Style)
+	if err != nil {
+		return "", errors.Wrapf(err, "cannot replace %s in line %d", node.Value, node.Line)
+	}
+	replacement, err := quoted(value, node.Style)
+	if err != nil {
+		return "", errors.Wrapf(err, "cannot replace %s in line %d", node.Value, node.Line)
+	}
+
+	runes := []rune(line)
+	start := node.Column - 1
+	if start < 0 || start > len(runes) {
+		return "", errors.Errorf("cannot replace %s in line %d, column %d is out of range", node.Value, node.Line, node.Column)
+	}
+
+	prefix := string(runes[:start])
+	rest := string(runes[start:])
+	i := strings.Index(rest, original)
+	if i < 0 {
+		return "", errors.Errorf("cannot replace %s in line %d, it spans several lines or uses escapes", node.Value, node.Line)
+	}
+
+	return prefix + rest[:i] + replacement + rest[i+len(original):], nil
+}
+
+// quoted writes the value as scalar of the style
+func quoted(value string, style yaml.Style) (string, error) {
+	switch {
+	case style&yaml.DoubleQuotedStyle != 0:
+		if strings.ContainsAny(value, "\"\\\n") {
+			return "", errors.New("escaped double quoted scalars are not supported")
+		}
+		return `"` + value + `"`, nil
+	case style&yaml.SingleQuotedStyle != 0:
+		if strings.Contains(value, "\n") {
+			return "", errors.New("multi-line scalars are not supported")
+		}
+		return "'" + strings.Replace(value, "'", "''", -1) + "'", nil
+	case style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0:
+		return "", errors.New("block scalars are not supported")
+	}
+	if strings.Contains(value, "\n") {
+		return "", errors.New("multi-line scalars are not supported")
+	}
+	return value, nil
+}
+
+// Image is a scalar node that holds an image reference
+type Image struct {
+	Node *yaml.Node
+	// Platform is the platform requested for the image, e.g. linux/arm64, or empty
+	Platform string
+}
+
+// ProcessImages passes the image references to the processor and replaces the changed ones.
+// Images with variables like ${TAG} are skipped, nodes that are reached via aliases several times are processed once.
+func (document *Document) ProcessImages(log logrus.FieldLogger, images []Image, occurrenceProcessor dockfmt.OccurrenceProcessor) error {
+	seen := make(map[*yaml.Node]bool)
+	for _, image := range images {
+		node := image.Node
+		if seen[node] {
+			continue
+		}
+		seen[node] = true
+
+		if strings.Contains(node.Value, "$") {
+			log.Warnf("Skipping image %s in line %d, variables are not supported", node.Value, node.Line)
+			continue
+		}
+
+		log.Infof("Found image %s", node.Value)
+		ref, err := dockref.Parse(node.Value)
+		if err != nil {
+			return err
+		}
+
+		processed, err := occurrenceProcessor(ref, dockfmt.Occurrence{Line: node.Line, Platform: image.Platform})
+		if err != nil {
+			return err
+		}
+
+		if processed != ref {
+			log.Infof("Pinning '%s' as '%s'", node.Value, processed)
+			document.Replace(node, processed.String())
+		}
+	}
+	return nil
+}
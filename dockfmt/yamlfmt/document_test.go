@@ -0,0 +1,81 @@
+package yamlfmt
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+	"testing"
+)
+
+func TestDocument_Replace(t *testing.T) {
+	content := `# comment
+a: plain # trailing
+b: "double"
+c: 'single''s'
+d: &anchor anchored
+e: *anchor
+f: {x: first, y: second}
+g: |
+  block
+`
+	document, err := Parse([]byte(content))
+	assert.Nil(t, err)
+
+	root := Mapping(document.Roots[0])
+	assert.False(t, document.Changed())
+	document.Replace(Value(root, "a"), "PLAIN")
+	document.Replace(Value(root, "b"), "DOUBLE")
+	document.Replace(Value(root, "c"), "it's")
+	document.Replace(Value(root, "e"), "ANCHORED")
+	document.Replace(Value(Value(root, "f"), "x"), "FIRST")
+	document.Replace(Value(Value(root, "f"), "y"), "SECOND")
+	assert.True(t, document.Changed())
+
+	buffer := bytes.NewBuffer(nil)
+	_, err = document.WriteTo(buffer)
+	assert.Nil(t, err)
+	assert.Equal(t, `# comment
+a: PLAIN # trailing
+b: "DOUBLE"
+c: 'it''s'
+d: &anchor ANCHORED
+e: *anchor
+f: {x: FIRST, y: SECOND}
+g: |
+  block
+`, buffer.String())
+
+	document.Replace(Value(root, "g"), "other")
+	_, err = document.WriteTo(bytes.NewBuffer(nil))
+	assert.Error(t, err)
+}
+
+func TestValue_Merges(t *testing.T) {
+	document, err := Parse([]byte(`
+base: &base {a: 1, b: 2}
+more: &more {b: 3, c: 4}
+single:
+  <<: *base
+  a: 0
+list:
+  <<: [*more, *base]
+`))
+	assert.Nil(t, err)
+
+	root := Mapping(document.Roots[0])
+	assert.Equal(t, "0", Scalar(Value(Value(root, "single"), "a")))
+	assert.Equal(t, "2", Scalar(Value(Value(root, "single"), "b")))
+	assert.Equal(t, "3", Scalar(Value(Value(root, "list"), "b")))
+	assert.Equal(t, "1", Scalar(Value(Value(root, "list"), "a")))
+	assert.Nil(t, Value(Value(root, "list"), "missing"))
+	assert.Nil(t, Value(Value(Value(root, "list"), "a"), "a"))
+}
+
+func TestParse_MultipleDocuments(t *testing.T) {
+	document, err := Parse([]byte("a: 1\n---\n- b\n"))
+	assert.Nil(t, err)
+	assert.Len(t, document.Roots, 2)
+	assert.NotNil(t, Mapping(document.Roots[0]))
+	assert.Nil(t, Mapping(document.Roots[1]))
+	assert.Equal(t, yaml.ScalarNode, Items(document.Roots[1].Content[0])[0].Kind)
+}
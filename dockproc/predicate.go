@@ -1,11 +1,15 @@
 package dockproc
 
 import (
+	"context"
 	"github.com/MeneDev/dockmoor/dockref"
 	"github.com/docker/distribution/reference"
 	"github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
 	"regexp"
 	"strings"
+	"sync"
+	"time"
 )
 
 type Predicate interface {
@@ -60,18 +64,121 @@ func UnpinnedPredicateNew() (Predicate, error) {
 	return unpinnedPredicate{}, nil
 }
 
-//var _ Predicate = (*outdatedPredicate)(nil)
-//
-//type outdatedPredicate struct {
-//}
-//
-//func (outdatedPredicate) Matches(ref dockref.Reference) bool {
-//	panic("not implemented")
-//}
-//
-//func OutdatedPredicateNew() Predicate {
-//	return outdatedPredicate{}
-//}
+// FailingPredicate is implemented by predicates that can fail to decide whether a reference matches,
+// e.g. because the tags of its repository cannot be listed. Such references are not matched.
+type FailingPredicate interface {
+	Predicate
+	Err() error
+}
+
+// PredicateErr collects the errors of all FailingPredicates in predicate, including the operands of AndPredicates
+func PredicateErr(predicate Predicate) error {
+	var result *multierror.Error
+
+	if and, ok := predicate.(AndPredicate); ok {
+		for _, p := range and.Predicates() {
+			if e := PredicateErr(p); e != nil {
+				result = multierror.Append(result, e)
+			}
+		}
+	}
+
+	if failing, ok := predicate.(FailingPredicate); ok {
+		if e := failing.Err(); e != nil {
+			result = multierror.Append(result, e)
+		}
+	}
+
+	return result.ErrorOrNil()
+}
+
+var _ FailingPredicate = (*outdatedPredicate)(nil)
+
+// outdatedPredicate lists the tags of each repository once
+type outdatedPredicate struct {
+	ctx      context.Context
+	resolver dockref.Resolver
+	schemes  dockref.TagSchemes
+	policies dockref.UpdatePolicies
+	now      func() time.Time
+
+	mutex sync.Mutex
+	tags  map[string][]string
+	err   *multierror.Error
+}
+
+func (p *outdatedPredicate) Matches(ref dockref.Reference) bool {
+	if ref.Named() == nil || ref.Tag() == "" {
+		return false
+	}
+
+	tags, err := p.listTags(ref)
+	if err != nil {
+		p.fail(ref, err)
+		return false
+	}
+
+	policy := p.policies.For(ref)
+	newer := p.schemes.For(ref).NewerTags(ref, tags, policy)
+	if len(newer) == 0 || policy.MinAge == 0 {
+		return len(newer) > 0
+	}
+
+	mature, err := dockref.FirstMatureTag(p.ctx, p.resolver, ref, newer, policy.MinAge, p.now())
+	if err != nil {
+		p.fail(ref, err)
+		return false
+	}
+	return mature != ""
+}
+
+func (p *outdatedPredicate) fail(ref dockref.Reference, err error) {
+	p.mutex.Lock()
+	p.err = multierror.Append(p.err, errors.Wrapf(err, "cannot decide whether %s is outdated", ref.Original()))
+	p.mutex.Unlock()
+}
+
+func (p *outdatedPredicate) listTags(ref dockref.Reference) ([]string, error) {
+	p.mutex.Lock()
+	tags, ok := p.tags[ref.Name()]
+	p.mutex.Unlock()
+	if ok {
+		return tags, nil
+	}
+
+	tags, err := dockref.ListTags(p.ctx, p.resolver, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mutex.Lock()
+	p.tags[ref.Name()] = tags
+	p.mutex.Unlock()
+	return tags, nil
+}
+
+func (p *outdatedPredicate) Err() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.err.ErrorOrNil()
+}
+
+// OutdatedPredicateNew matches references with a newer version of the same variant in their repository
+// that their update policy allows, the resolver must be able to list tags
+func OutdatedPredicateNew(ctx context.Context, resolver dockref.Resolver, schemes dockref.TagSchemes, policies dockref.UpdatePolicies) (Predicate, error) {
+	if !dockref.CanListTags(resolver) {
+		return nil, errors.New("the resolver does not support listing tags")
+	}
+
+	return &outdatedPredicate{
+		ctx:      ctx,
+		resolver: resolver,
+		schemes:  schemes,
+		policies: policies,
+		now:      time.Now,
+		tags:     make(map[string][]string),
+	}, nil
+}
 
 var _ Predicate = (*untaggedPredicate)(nil)
 
@@ -1,9 +1,14 @@
 package dockproc
 
 import (
+	"context"
 	"github.com/MeneDev/dockmoor/dockref"
+	"github.com/MeneDev/dockmoor/docktst/dockreftst"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"testing"
+	"time"
 )
 
 func TestAnyPredicate(t *testing.T) {
@@ -702,3 +707,87 @@ func TestTagsPredicate_returnsErrorForInvalidRegex(t *testing.T) {
 	assert.Nil(t, predicateNew)
 	assert.Error(t, err)
 }
+
+func TestOutdatedPredicate(t *testing.T) {
+	resolver := dockreftst.MockResolverNew()
+	resolver.OnListTags(dockref.MustParse("nginx:1.15")).Return([]string{"1.14", "1.15", "1.15.6", "1.16", "1.16-alpine"}, nil).Once()
+
+	predicate, e := OutdatedPredicateNew(context.Background(), resolver, nil, nil)
+	assert.Nil(t, e)
+
+	shouldMatches := []string{"nginx:1.15", "nginx:1.15.2", "nginx:1.15-alpine", "nginx:1.14@sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf"}
+	for _, original := range shouldMatches {
+		t.Run("Matches "+original, func(t *testing.T) {
+			assert.True(t, predicate.Matches(dockref.MustParse(original)))
+		})
+	}
+
+	shouldNotMatches := []string{"nginx:1.16", "nginx:1.16-alpine", "nginx:latest", "nginx", "nginx:mainline",
+		"sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf"}
+	for _, original := range shouldNotMatches {
+		t.Run("Not matching "+original, func(t *testing.T) {
+			assert.False(t, predicate.Matches(dockref.MustParse(original)))
+		})
+	}
+
+	// the tags of a repository are listed once
+	resolver.AssertExpectations(t)
+	assert.Nil(t, PredicateErr(predicate))
+}
+
+func TestOutdatedPredicate_FollowsPolicies(t *testing.T) {
+	resolver := dockreftst.MockResolverNew()
+	resolver.OnListTags(dockref.MustParse("postgres:13.1")).Return([]string{"13.1", "14.0", "15.0-rc1"}, nil)
+	resolver.OnListTags(dockref.MustParse("nginx:1.15")).Return([]string{"1.15", "1.16"}, nil)
+	resolver.OnCreationTime(mock.Anything).Return(time.Now().Add(-time.Hour), nil)
+
+	predicate, e := OutdatedPredicateNew(context.Background(), resolver, nil, dockref.UpdatePolicies{
+		{Matcher: familiarNamesPredicate{familiarNames: []string{"postgres"}}, Policy: dockref.UpdatePolicy{Allow: []string{"<14.0.0"}}},
+		{Policy: dockref.UpdatePolicy{MinAge: 72 * time.Hour}},
+	})
+	assert.Nil(t, e)
+
+	assert.False(t, predicate.Matches(dockref.MustParse("postgres:13.1")))
+	assert.False(t, predicate.Matches(dockref.MustParse("nginx:1.15")))
+	assert.Nil(t, PredicateErr(predicate))
+}
+
+func TestOutdatedPredicate_UsesTagSchemes(t *testing.T) {
+	scheme, e := dockref.TagSchemeNew("temurin", `^(?P<version>[0-9.]+)_(?P<build>[0-9]+)-(?P<variant>.+)$`)
+	assert.Nil(t, e)
+
+	resolver := dockreftst.MockResolverNew()
+	resolver.OnListTags(mock.Anything).Return([]string{"17.0.8_7-jdk-jammy", "17.0.8_10-jdk-jammy"}, nil)
+
+	predicate, e := OutdatedPredicateNew(context.Background(), resolver, dockref.TagSchemes{{Scheme: scheme}}, nil)
+	assert.Nil(t, e)
+
+	assert.True(t, predicate.Matches(dockref.MustParse("eclipse-temurin:17.0.8_7-jdk-jammy")))
+	assert.False(t, predicate.Matches(dockref.MustParse("eclipse-temurin:17.0.8_10-jdk-jammy")))
+}
+
+func TestOutdatedPredicate_ReportsErrors(t *testing.T) {
+	resolver := dockreftst.MockResolverNew()
+	resolver.OnListTags(dockref.MustParse("nginx:1.15")).Return([]string(nil), errors.New("registry unavailable"))
+
+	predicate, e := OutdatedPredicateNew(context.Background(), resolver, nil, nil)
+	assert.Nil(t, e)
+	and, e := AndPredicateNew([]Predicate{predicate})
+	assert.Nil(t, e)
+
+	assert.False(t, and.Matches(dockref.MustParse("nginx:1.15")))
+
+	err := PredicateErr(and)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "cannot decide whether nginx:1.15 is outdated: registry unavailable")
+	}
+}
+
+func TestOutdatedPredicate_RequiresTagLister(t *testing.T) {
+	_, e := OutdatedPredicateNew(context.Background(), dockref.LockResolverNew(""), nil, nil)
+	assert.Error(t, e)
+
+	cached := dockref.CachingResolverNew(dockref.LockResolverNew(""), "lock", dockref.CacheOptions{})
+	_, e = OutdatedPredicateNew(context.Background(), cached, nil, nil)
+	assert.Error(t, e, "the cache lists tags only when the cached resolver does")
+}
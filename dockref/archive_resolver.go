@@ -0,0 +1,376 @@
+package dockref
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	archiveManifestFile     = "manifest.json"
+	archiveRepositoriesFile = "repositories"
+)
+
+type archiveManifestEntry struct {
+	Config   string   `json:"Config"`
+	RepoTags []string `json:"RepoTags"`
+	Layers   []string `json:"Layers"`
+}
+
+// archiveImage is an image of a docker save archive, archives don't contain repo digests
+type archiveImage struct {
+	repoTags []string
+	id       string
+}
+
+type dockerArchive struct {
+	filename string
+	images   []archiveImage
+	// ociImages are only set for archives that are OCI image layouts as well
+	ociImages []ociImage
+}
+
+type dockerArchiveResolver struct {
+	filenames []string
+	open      func(filename string) (io.ReadCloser, error)
+
+	once        sync.Once
+	archives    []dockerArchive
+	archivesErr error
+}
+
+var _ Resolver = (*dockerArchiveResolver)(nil)
+var _ PlatformResolver = (*dockerArchiveResolver)(nil)
+var _ TagLister = (*dockerArchiveResolver)(nil)
+
+// DockerArchiveResolverNew creates a Resolver that reads the images of docker save archives.
+// The archives are searched in the given order, optionally gzip compressed.
+func DockerArchiveResolverNew(filenames ...string) Resolver {
+	return &dockerArchiveResolver{
+		filenames: filenames,
+		open: func(filename string) (io.ReadCloser, error) {
+			return os.Open(filename)
+		},
+	}
+}
+
+func (r *dockerArchiveResolver) load() ([]dockerArchive, error) {
+	r.once.Do(func() {
+		for _, filename := range r.filenames {
+			archive, err := r.readArchive(filename)
+			if err != nil {
+				r.archivesErr = errors.Wrapf(err, "cannot read docker archive %s", filename)
+				return
+			}
+			r.archives = append(r.archives, archive)
+		}
+	})
+	return r.archives, r.archivesErr
+}
+
+func (r *dockerArchiveResolver) Resolve(ctx context.Context, reference Reference) ([]Reference, error) {
+	archives, err := r.load()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, archive := range archives {
+		refs, err := archive.resolve(reference)
+		if IsNotFound(err) {
+			continue
+		}
+		return refs, err
+	}
+
+	return nil, NotFoundError{Reference: reference.Original()}
+}
+
+// ResolvePlatform supports archives that are OCI image layouts, only they contain the manifests of the platforms
+func (r *dockerArchiveResolver) ResolvePlatform(ctx context.Context, reference Reference, platform Platform) ([]Reference, error) {
+	archives, err := r.load()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, archive := range archives {
+		refs, err := archive.resolve(reference)
+		if IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if archive.ociImages == nil {
+			return nil, errors.Errorf("cannot resolve %s for platform %s, docker archive %s contains no manifests", reference.Original(), platform, archive.filename)
+		}
+
+		readBlob := func(dig string) ([]byte, error) {
+			name := ociBlobPath(dig)
+			files, err := r.readTar(archive.filename, func(n string) bool {
+				return n == name
+			})
+			if err != nil {
+				return nil, err
+			}
+			content, ok := files[name]
+			if !ok {
+				return nil, errors.Errorf("blob %s not found in docker archive %s", dig, archive.filename)
+			}
+			return content, nil
+		}
+
+		return withPlatformDigest(reference, refs, platform, readBlob, readBlob)
+	}
+
+	return nil, NotFoundError{Reference: reference.Original()}
+}
+
+// ListTags lists the tags of the repository in all archives
+func (r *dockerArchiveResolver) ListTags(ctx context.Context, reference Reference) ([]string, error) {
+	archives, err := r.load()
+	if err != nil {
+		return nil, err
+	}
+
+	tags := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, archive := range archives {
+		for _, tag := range archive.tags(reference) {
+			if !seen[tag] {
+				seen[tag] = true
+				tags = append(tags, tag)
+			}
+		}
+	}
+
+	if len(tags) == 0 {
+		return nil, NotFoundError{Reference: reference.Original()}
+	}
+	return tags, nil
+}
+
+func (archive dockerArchive) tags(reference Reference) []string {
+	if archive.ociImages != nil {
+		return tagsOfImages(reference, archive.ociImages)
+	}
+
+	tags := make([]string, 0)
+	for _, image := range archive.images {
+		for _, repoTag := range image.repoTags {
+			r := MustParse(repoTag)
+			if r.Name() == reference.Name() {
+				tags = append(tags, r.Tag())
+			}
+		}
+	}
+	return tags
+}
+
+func (archive dockerArchive) resolve(reference Reference) ([]Reference, error) {
+	if archive.ociImages != nil {
+		return resolveFromImages(reference, archive.ociImages)
+	}
+
+	tag := reference.Tag()
+	if tag == "" {
+		tag = "latest"
+	}
+
+	for _, image := range archive.images {
+		if image.matches(reference, tag) {
+			return imageReferences(reference, image.repoTags, nil, image.id), nil
+		}
+	}
+
+	return nil, NotFoundError{Reference: reference.Original()}
+}
+
+func (image archiveImage) matches(reference Reference, tag string) bool {
+	if reference.DigestString() != "" {
+		return reference.DigestString() == image.id
+	}
+
+	for _, repoTag := range image.repoTags {
+		r := MustParse(repoTag)
+		if r.Name() == reference.Name() && r.Tag() == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// readTar returns the content of the regular files accepted by wanted
+func (r *dockerArchiveResolver) readTar(filename string, wanted func(name string) bool) (map[string][]byte, error) {
+	file, err := r.open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		deliberatelyUnsued(file.Close())
+	}()
+
+	reader := bufio.NewReader(file)
+	var tarReader *tar.Reader
+
+	magic, err := reader.Peek(2)
+	if err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gzipReader, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, err
+		}
+		tarReader = tar.NewReader(gzipReader)
+	} else {
+		tarReader = tar.NewReader(reader)
+	}
+
+	files := make(map[string][]byte)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		name := strings.TrimPrefix(header.Name, "./")
+		if header.Typeflag != tar.TypeReg || !wanted(name) {
+			continue
+		}
+
+		content, err := ioutil.ReadAll(tarReader)
+		if err != nil {
+			return nil, err
+		}
+		files[name] = content
+	}
+}
+
+func (r *dockerArchiveResolver) readArchive(filename string) (dockerArchive, error) {
+	archive := dockerArchive{filename: filename}
+
+	files, err := r.readTar(filename, func(name string) bool {
+		switch name {
+		case archiveManifestFile, archiveRepositoriesFile, ociLayoutFile, ociIndexFile:
+			return true
+		}
+		return false
+	})
+	if err != nil {
+		return archive, err
+	}
+
+	if _, isLayout := files[ociLayoutFile]; isLayout {
+		if index, ok := files[ociIndexFile]; ok {
+			archive.ociImages, err = ociImagesFromIndex(index)
+			if err != nil {
+				return archive, errors.Wrapf(err, "invalid %s", ociIndexFile)
+			}
+			return archive, nil
+		}
+	}
+
+	if manifest, ok := files[archiveManifestFile]; ok {
+		archive.images, err = r.imagesFromManifest(filename, manifest)
+		return archive, err
+	}
+
+	if repositories, ok := files[archiveRepositoriesFile]; ok {
+		archive.images, err = imagesFromRepositories(repositories)
+		return archive, err
+	}
+
+	return archive, errors.Errorf("neither %s nor %s found", archiveManifestFile, archiveRepositoriesFile)
+}
+
+func (r *dockerArchiveResolver) imagesFromManifest(filename string, content []byte) ([]archiveImage, error) {
+	var manifest []archiveManifestEntry
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		return nil, errors.Wrapf(err, "invalid %s", archiveManifestFile)
+	}
+
+	configNames := make(map[string]bool)
+	for _, entry := range manifest {
+		configNames[entry.Config] = true
+	}
+
+	// the image id is the digest of its config
+	configs, err := r.readTar(filename, func(name string) bool {
+		return configNames[name]
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	images := make([]archiveImage, 0, len(manifest))
+	for _, entry := range manifest {
+		config, ok := configs[entry.Config]
+		if !ok {
+			return nil, errors.Errorf("config %s not found", entry.Config)
+		}
+		sum := sha256.Sum256(config)
+
+		images = append(images, archiveImage{
+			repoTags: validRepoTags(entry.RepoTags),
+			id:       "sha256:" + hex.EncodeToString(sum[:]),
+		})
+	}
+
+	return images, nil
+}
+
+// imagesFromRepositories reads archives of docker versions before 1.10, which only know the top layer of each tag
+func imagesFromRepositories(content []byte) ([]archiveImage, error) {
+	var repositories map[string]map[string]string
+	if err := json.Unmarshal(content, &repositories); err != nil {
+		return nil, errors.Wrapf(err, "invalid %s", archiveRepositoriesFile)
+	}
+
+	tagsByLayer := make(map[string][]string)
+	for repository, tags := range repositories {
+		for tag, layer := range tags {
+			tagsByLayer[layer] = append(tagsByLayer[layer], repository+":"+tag)
+		}
+	}
+
+	layers := make([]string, 0, len(tagsByLayer))
+	for layer := range tagsByLayer {
+		layers = append(layers, layer)
+	}
+	sort.Strings(layers)
+
+	images := make([]archiveImage, 0, len(layers))
+	for _, layer := range layers {
+		repoTags := validRepoTags(tagsByLayer[layer])
+		if len(repoTags) == 0 {
+			continue
+		}
+		sort.Strings(repoTags)
+		images = append(images, archiveImage{repoTags: repoTags})
+	}
+
+	return images, nil
+}
+
+func validRepoTags(repoTags []string) []string {
+	valid := make([]string, 0, len(repoTags))
+	for _, repoTag := range repoTags {
+		r, err := Parse(repoTag)
+		if err == nil && r.Named() != nil {
+			valid = append(valid, repoTag)
+		}
+	}
+	return valid
+}
@@ -0,0 +1,186 @@
+package dockref
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+const archiveTestConfig = `{"architecture":"amd64","os":"linux"}`
+
+func archiveTestID() string {
+	sum := sha256.Sum256([]byte(archiveTestConfig))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func tarOf(t *testing.T, files map[string]string) []byte {
+	buffer := bytes.NewBuffer(nil)
+	writer := tar.NewWriter(buffer)
+	for name, content := range files {
+		assert.Nil(t, writer.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}))
+		_, err := writer.Write([]byte(content))
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, writer.Close())
+	return buffer.Bytes()
+}
+
+func gzipOf(t *testing.T, content []byte) []byte {
+	buffer := bytes.NewBuffer(nil)
+	writer := gzip.NewWriter(buffer)
+	_, err := writer.Write(content)
+	assert.Nil(t, err)
+	assert.Nil(t, writer.Close())
+	return buffer.Bytes()
+}
+
+func archiveResolverTestNew(archives map[string][]byte, filenames ...string) Resolver {
+	resolver := DockerArchiveResolverNew(filenames...).(*dockerArchiveResolver)
+	resolver.open = func(filename string) (io.ReadCloser, error) {
+		content, ok := archives[filename]
+		if !ok {
+			return nil, &os.PathError{Op: "open", Path: filename, Err: os.ErrNotExist}
+		}
+		return ioutil.NopCloser(bytes.NewReader(content)), nil
+	}
+	return resolver
+}
+
+func archiveTestManifest(t *testing.T) []byte {
+	return tarOf(t, map[string]string{
+		"manifest.json": `[
+		{"Config": "config.json", "RepoTags": ["nginx:1.15", "nginx:1.15.6", "menedev/testimagea:1"], "Layers": ["layer.tar"]},
+		{"Config": "untagged.json", "RepoTags": null, "Layers": ["layer.tar"]}
+	]`,
+		"config.json":   archiveTestConfig,
+		"untagged.json": `{}`,
+		"layer.tar":     "",
+	})
+}
+
+func TestDockerArchiveResolver_Resolve(t *testing.T) {
+	resolver := archiveResolverTestNew(map[string][]byte{"images.tar": archiveTestManifest(t)}, "images.tar")
+
+	refs, err := resolver.Resolve(context.Background(), MustParse("nginx:1.15"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1.15@", "1.15.6@", "1@"}, tagsAndDigests(refs))
+
+	mostPrecise, err := MostPreciseTag(refs, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "1.15.6", mostPrecise.Tag())
+
+	refs, err = resolver.Resolve(context.Background(), MustParse("nginx@"+archiveTestID()))
+	assert.Nil(t, err)
+	assert.Len(t, refs, 3)
+
+	_, err = resolver.Resolve(context.Background(), MustParse("nginx"))
+	assert.True(t, IsNotFound(err))
+}
+
+func TestDockerArchiveResolver_UntaggedImageResolvesToID(t *testing.T) {
+	resolver := archiveResolverTestNew(map[string][]byte{"images.tar": archiveTestManifest(t)}, "images.tar")
+
+	sum := sha256.Sum256([]byte(`{}`))
+	id := "sha256:" + hex.EncodeToString(sum[:])
+
+	refs, err := resolver.Resolve(context.Background(), MustParseAlgoDigest(id))
+	assert.Nil(t, err)
+	assert.Equal(t, []Reference{MustParseAlgoDigest(id)}, refs)
+}
+
+func TestDockerArchiveResolver_SearchesArchivesInOrder(t *testing.T) {
+	legacy := gzipOf(t, tarOf(t, map[string]string{
+		"repositories": `{"nginx": {"latest": "abc", "1.15.6": "abc", "1.14": "def"}}`,
+	}))
+	resolver := archiveResolverTestNew(map[string][]byte{
+		"images.tar":    archiveTestManifest(t),
+		"legacy.tar.gz": legacy,
+	}, "images.tar", "legacy.tar.gz")
+
+	refs, err := resolver.Resolve(context.Background(), MustParse("nginx"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1.15.6@", "latest@"}, tagsAndDigests(refs))
+
+	refs, err = resolver.Resolve(context.Background(), MustParse("nginx:1.15"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1.15@", "1.15.6@", "1@"}, tagsAndDigests(refs))
+}
+
+func TestDockerArchiveResolver_UsesIndexOfOCIArchives(t *testing.T) {
+	archive := tarOf(t, map[string]string{
+		"oci-layout":    `{"imageLayoutVersion": "1.0.0"}`,
+		"index.json":    ociTestIndex,
+		"manifest.json": `[{"Config": "blobs/sha256/missing", "RepoTags": ["nginx:1.15"]}]`,
+	})
+	resolver := archiveResolverTestNew(map[string][]byte{"images.tar": archive}, "images.tar")
+
+	refs, err := resolver.Resolve(context.Background(), MustParse("nginx:1.15"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1.15.6@" + ociTestDigestA, "1.15@" + ociTestDigestA}, tagsAndDigests(refs))
+}
+
+func TestDockerArchiveResolver_InvalidArchives(t *testing.T) {
+	archives := map[string][]byte{
+		"empty.tar":          tarOf(t, map[string]string{}),
+		"invalid.tar":        tarOf(t, map[string]string{"manifest.json": "{ invalid"}),
+		"missing-config.tar": tarOf(t, map[string]string{"manifest.json": `[{"Config": "config.json"}]`}),
+	}
+
+	for filename, expected := range map[string]string{
+		"empty.tar":          "neither manifest.json nor repositories found",
+		"invalid.tar":        "invalid manifest.json",
+		"missing-config.tar": "config config.json not found",
+		"missing.tar":        "file does not exist",
+	} {
+		_, err := archiveResolverTestNew(archives, filename).Resolve(context.Background(), MustParse("nginx"))
+		if assert.Error(t, err, filename) {
+			assert.Contains(t, err.Error(), "cannot read docker archive "+filename)
+			assert.Contains(t, err.Error(), expected)
+		}
+	}
+}
+
+func TestDockerArchiveResolver_ResolvePlatform(t *testing.T) {
+	blobs, index, arm64 := ociTestPlatformBlobs()
+	files := map[string]string{
+		"oci-layout": `{"imageLayoutVersion": "1.0.0"}`,
+		"index.json": ociTestPlatformIndex(index),
+	}
+	for name, content := range blobs {
+		files[name] = content
+	}
+
+	resolver := archiveResolverTestNew(map[string][]byte{
+		"oci.tar":    tarOf(t, files),
+		"images.tar": archiveTestManifest(t),
+	}, "images.tar", "oci.tar").(PlatformResolver)
+
+	_, err := resolver.ResolvePlatform(context.Background(), MustParse("nginx:1.15"), MustParsePlatform("linux/arm64"))
+	if assert.Error(t, err, "images.tar is searched first") {
+		assert.Contains(t, err.Error(), "docker archive images.tar contains no manifests")
+	}
+
+	resolver = archiveResolverTestNew(map[string][]byte{"oci.tar": tarOf(t, files)}, "oci.tar").(PlatformResolver)
+	refs, err := resolver.ResolvePlatform(context.Background(), MustParse("nginx:1.15"), MustParsePlatform("linux/arm64"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1.15@" + arm64}, tagsAndDigests(refs))
+}
+
+func TestDockerArchiveResolver_ListTags(t *testing.T) {
+	resolver := archiveResolverTestNew(map[string][]byte{"images.tar": archiveTestManifest(t)}, "images.tar")
+
+	tags, err := ListTags(context.Background(), resolver, MustParse("nginx:1.15"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1.15", "1.15.6"}, tags)
+
+	_, err = ListTags(context.Background(), resolver, MustParse("alpine:3.8"))
+	assert.True(t, IsNotFound(err))
+}
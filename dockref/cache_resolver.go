@@ -0,0 +1,189 @@
+package dockref
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// CacheOptions control how long and where resolution results are cached
+type CacheOptions struct {
+	// Dir is the directory holding the cache entries
+	Dir string
+	// TTL is the maximum age of a cache entry, zero means cache entries never expire
+	TTL time.Duration
+	// Refresh ignores existing cache entries, but still updates the cache
+	Refresh bool
+}
+
+type cacheEntry struct {
+	Reference string    `json:"reference"`
+	Resolved  []string  `json:"resolved"`
+	Time      time.Time `json:"time"`
+}
+
+type cachingResolver struct {
+	delegate  Resolver
+	namespace string
+	options   CacheOptions
+
+	now func() time.Time
+}
+
+var _ Resolver = (*cachingResolver)(nil)
+var _ PlatformResolver = (*cachingResolver)(nil)
+var _ TagLister = (*cachingResolver)(nil)
+var _ CreationTimeResolver = (*cachingResolver)(nil)
+var _ decorator = (*cachingResolver)(nil)
+
+// CachingResolverNew decorates the delegate with a persistent on-disk cache.
+// The namespace separates the results of different resolvers sharing the same directory.
+// Use CanListTags, CanResolvePlatform and CanTellCreationTime to learn whether the delegate supports them.
+func CachingResolverNew(delegate Resolver, namespace string, options CacheOptions) Resolver {
+	return &cachingResolver{
+		delegate:  delegate,
+		namespace: namespace,
+		options:   options,
+		now:       time.Now,
+	}
+}
+
+func (c *cachingResolver) decorated() Resolver {
+	return c.delegate
+}
+
+func (c *cachingResolver) Resolve(ctx context.Context, reference Reference) ([]Reference, error) {
+	return c.ResolvePlatform(ctx, reference, Platform{})
+}
+
+// ResolvePlatform caches the results of each platform separately, the delegate must support platforms
+func (c *cachingResolver) ResolvePlatform(ctx context.Context, reference Reference, platform Platform) ([]Reference, error) {
+	key := canonicalString(reference)
+	if !platform.IsZero() {
+		key += " " + platform.String()
+	}
+
+	if !c.options.Refresh {
+		if refs, ok := c.load(reference, key); ok {
+			return refs, nil
+		}
+	}
+
+	refs, err := ResolveForPlatform(ctx, c.delegate, reference, platform)
+	if err != nil {
+		return refs, err
+	}
+
+	// the cache is an optimization only, failing to write it must not fail the resolution
+	err = c.store(key, refs)
+	deliberatelyUnsued(err)
+
+	return refs, nil
+}
+
+// ListTags is not cached, new tags are exactly what callers are looking for
+func (c *cachingResolver) ListTags(ctx context.Context, reference Reference) ([]string, error) {
+	return ListTags(ctx, c.delegate, reference)
+}
+
+// CreationTime is not cached, it is only asked for tags that are too new to be chosen
+func (c *cachingResolver) CreationTime(ctx context.Context, reference Reference) (time.Time, error) {
+	return CreationTime(ctx, c.delegate, reference)
+}
+
+func (c *cachingResolver) entryPath(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.options.Dir, c.namespace, hex.EncodeToString(sum[:])+".json")
+}
+
+func (c *cachingResolver) load(reference Reference, key string) ([]Reference, bool) {
+	content, err := ioutil.ReadFile(c.entryPath(key))
+	if err != nil {
+		return nil, false
+	}
+
+	var entry cacheEntry
+	if err := json.Unmarshal(content, &entry); err != nil || entry.Reference != key {
+		return nil, false
+	}
+
+	if c.options.TTL > 0 && c.now().Sub(entry.Time) > c.options.TTL {
+		return nil, false
+	}
+
+	refs := make([]Reference, 0, len(entry.Resolved))
+	for _, resolved := range entry.Resolved {
+		parsed, err := Parse(resolved)
+		if err != nil {
+			return nil, false
+		}
+
+		// keep the format of the original reference, just like the resolvers do
+		if parsed.Named() != nil && reference.Named() != nil {
+			parsed = reference.WithTag(parsed.Tag()).WithDigest(parsed.DigestString())
+		}
+		refs = append(refs, parsed)
+	}
+
+	return refs, true
+}
+
+func (c *cachingResolver) store(key string, refs []Reference) error {
+	entry := cacheEntry{
+		Reference: key,
+		Resolved:  make([]string, 0, len(refs)),
+		Time:      c.now(),
+	}
+	for _, r := range refs {
+		entry.Resolved = append(entry.Resolved, canonicalString(r))
+	}
+
+	content, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := c.entryPath(key)
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	// write to a temporary file first, concurrent runs must never see partial entries
+	tmp, err := ioutil.TempFile(dir, ".entry")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(content)
+	errClose := tmp.Close()
+	if err == nil {
+		err = errClose
+	}
+	if err != nil {
+		deliberatelyUnsued(os.Remove(tmp.Name()))
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// canonicalString formats the reference with all known information, e.g. docker.io/library/nginx:1.15@sha256:...
+func canonicalString(r Reference) string {
+	if r.Named() == nil {
+		return r.DigestString()
+	}
+
+	s := r.Name()
+	if r.Tag() != "" {
+		s += ":" + r.Tag()
+	}
+	if r.DigestString() != "" {
+		s += "@" + r.DigestString()
+	}
+	return s
+}
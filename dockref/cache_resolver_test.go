@@ -0,0 +1,228 @@
+package dockref
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+type countingResolver struct {
+	calls   int
+	results map[string][]Reference
+}
+
+func (r *countingResolver) Resolve(ctx context.Context, reference Reference) ([]Reference, error) {
+	r.calls++
+	refs, ok := r.results[reference.Original()]
+	if !ok {
+		return nil, errors.New("unknown")
+	}
+	return refs, nil
+}
+
+func (r *countingResolver) ResolvePlatform(ctx context.Context, reference Reference, platform Platform) ([]Reference, error) {
+	return r.Resolve(context.Background(), MustParse(reference.Original()+"-"+strings.Replace(platform.String(), "/", "-", -1)))
+}
+
+func cachingResolverTestNew(t *testing.T, options CacheOptions) (*cachingResolver, *countingResolver, func()) {
+	dir, err := ioutil.TempDir("", "dockmoor-cache")
+	assert.Nil(t, err)
+	options.Dir = dir
+
+	nginx := MustParse("nginx:1.15")
+	delegate := &countingResolver{
+		results: map[string][]Reference{
+			"nginx:1.15": {
+				nginx.WithDigest("sha256:31b8e90a349d1fce7621f5a5a08e4fc519b634f7d3feb09d53fac9b12aa4d991"),
+				nginx.WithTag("1.15.6").WithDigest("sha256:31b8e90a349d1fce7621f5a5a08e4fc519b634f7d3feb09d53fac9b12aa4d991"),
+			},
+			"nginx:1.15-linux-arm64": {
+				nginx.WithTag("1.15.6").WithDigest("sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf"),
+			},
+			"3247732819d6cd7af0c45a05b30d0b147f05a25ee2e83d7b9707ee25fcdd0f58": {
+				MustParseAlgoDigest("sha256:3247732819d6cd7af0c45a05b30d0b147f05a25ee2e83d7b9707ee25fcdd0f58"),
+			},
+		},
+	}
+
+	resolver := CachingResolverNew(delegate, "test", options).(*cachingResolver)
+	return resolver, delegate, func() {
+		os.RemoveAll(dir)
+	}
+}
+
+func TestCachingResolver_Resolve(t *testing.T) {
+	resolver, delegate, cleanup := cachingResolverTestNew(t, CacheOptions{TTL: time.Hour})
+	defer cleanup()
+
+	first, e := resolver.Resolve(context.Background(), MustParse("nginx:1.15"))
+	assert.Nil(t, e)
+	assert.Equal(t, 1, delegate.calls)
+
+	second, e := resolver.Resolve(context.Background(), MustParse("nginx:1.15"))
+	assert.Nil(t, e)
+	assert.Equal(t, 1, delegate.calls)
+	assert.Equal(t, first, second)
+
+	// a new resolver instance reads the same cache directory
+	other := CachingResolverNew(delegate, "test", resolver.options)
+	third, e := other.Resolve(context.Background(), MustParse("nginx:1.15"))
+	assert.Nil(t, e)
+	assert.Equal(t, 1, delegate.calls)
+	assert.Equal(t, first, third)
+
+	for _, r := range third {
+		assert.Equal(t, "nginx:1.15", r.Original())
+		assert.Equal(t, "sha256:31b8e90a349d1fce7621f5a5a08e4fc519b634f7d3feb09d53fac9b12aa4d991", r.DigestString())
+	}
+}
+
+func TestCachingResolver_ResolvePlatform(t *testing.T) {
+	resolver, delegate, cleanup := cachingResolverTestNew(t, CacheOptions{})
+	defer cleanup()
+
+	for i := 0; i < 2; i++ {
+		refs, e := resolver.Resolve(context.Background(), MustParse("nginx:1.15"))
+		assert.Nil(t, e)
+		assert.Len(t, refs, 2)
+
+		refs, e = resolver.ResolvePlatform(context.Background(), MustParse("nginx:1.15"), MustParsePlatform("linux/arm64"))
+		assert.Nil(t, e)
+		assert.Equal(t, []string{"1.15.6@sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf"}, tagsAndDigests(refs))
+	}
+
+	assert.Equal(t, 2, delegate.calls)
+}
+
+func TestCachingResolver_Resolve_DigestOnly(t *testing.T) {
+	resolver, delegate, cleanup := cachingResolverTestNew(t, CacheOptions{})
+	defer cleanup()
+
+	ref := MustParse("3247732819d6cd7af0c45a05b30d0b147f05a25ee2e83d7b9707ee25fcdd0f58")
+	first, e := resolver.Resolve(context.Background(), ref)
+	assert.Nil(t, e)
+
+	second, e := resolver.Resolve(context.Background(), ref)
+	assert.Nil(t, e)
+	assert.Equal(t, 1, delegate.calls)
+	if assert.Len(t, second, 1) {
+		assert.Equal(t, first[0].Formatted(), second[0].Formatted())
+	}
+}
+
+func TestCachingResolver_Resolve_Expires(t *testing.T) {
+	resolver, delegate, cleanup := cachingResolverTestNew(t, CacheOptions{TTL: time.Hour})
+	defer cleanup()
+
+	now := time.Now()
+	resolver.now = func() time.Time { return now }
+
+	_, e := resolver.Resolve(context.Background(), MustParse("nginx:1.15"))
+	assert.Nil(t, e)
+
+	now = now.Add(59 * time.Minute)
+	_, e = resolver.Resolve(context.Background(), MustParse("nginx:1.15"))
+	assert.Nil(t, e)
+	assert.Equal(t, 1, delegate.calls)
+
+	now = now.Add(2 * time.Minute)
+	_, e = resolver.Resolve(context.Background(), MustParse("nginx:1.15"))
+	assert.Nil(t, e)
+	assert.Equal(t, 2, delegate.calls)
+}
+
+func TestCachingResolver_Resolve_Refresh(t *testing.T) {
+	resolver, delegate, cleanup := cachingResolverTestNew(t, CacheOptions{Refresh: true})
+	defer cleanup()
+
+	_, e := resolver.Resolve(context.Background(), MustParse("nginx:1.15"))
+	assert.Nil(t, e)
+	_, e = resolver.Resolve(context.Background(), MustParse("nginx:1.15"))
+	assert.Nil(t, e)
+	assert.Equal(t, 2, delegate.calls)
+
+	// refreshed entries are used by later runs
+	resolver.options.Refresh = false
+	_, e = resolver.Resolve(context.Background(), MustParse("nginx:1.15"))
+	assert.Nil(t, e)
+	assert.Equal(t, 2, delegate.calls)
+}
+
+func TestCachingResolver_Resolve_DoesNotCacheErrors(t *testing.T) {
+	resolver, delegate, cleanup := cachingResolverTestNew(t, CacheOptions{})
+	defer cleanup()
+
+	_, e := resolver.Resolve(context.Background(), MustParse("unknown:unknown"))
+	assert.Error(t, e)
+	_, e = resolver.Resolve(context.Background(), MustParse("unknown:unknown"))
+	assert.Error(t, e)
+	assert.Equal(t, 2, delegate.calls)
+}
+
+func TestCachingResolver_Resolve_SeparatesNamespaces(t *testing.T) {
+	resolver, delegate, cleanup := cachingResolverTestNew(t, CacheOptions{})
+	defer cleanup()
+
+	_, e := resolver.Resolve(context.Background(), MustParse("nginx:1.15"))
+	assert.Nil(t, e)
+
+	other := CachingResolverNew(delegate, "other", resolver.options)
+	_, e = other.Resolve(context.Background(), MustParse("nginx:1.15"))
+	assert.Nil(t, e)
+	assert.Equal(t, 2, delegate.calls)
+}
+
+func TestCachingResolver_Resolve_IgnoresCorruptEntries(t *testing.T) {
+	resolver, delegate, cleanup := cachingResolverTestNew(t, CacheOptions{})
+	defer cleanup()
+
+	_, e := resolver.Resolve(context.Background(), MustParse("nginx:1.15"))
+	assert.Nil(t, e)
+
+	path := resolver.entryPath(canonicalString(MustParse("nginx:1.15")))
+	assert.Nil(t, ioutil.WriteFile(path, []byte("{ corrupt"), 0644))
+
+	refs, e := resolver.Resolve(context.Background(), MustParse("nginx:1.15"))
+	assert.Nil(t, e)
+	assert.Len(t, refs, 2)
+	assert.Equal(t, 2, delegate.calls)
+}
+
+func TestCanonicalString(t *testing.T) {
+	assert.Equal(t, "docker.io/library/nginx", canonicalString(MustParse("nginx")))
+	assert.Equal(t, "docker.io/library/nginx:1.15", canonicalString(MustParse("nginx:1.15")))
+	assert.Equal(t, "docker.io/library/nginx@sha256:31b8e90a349d1fce7621f5a5a08e4fc519b634f7d3feb09d53fac9b12aa4d991",
+		canonicalString(MustParse("nginx@sha256:31b8e90a349d1fce7621f5a5a08e4fc519b634f7d3feb09d53fac9b12aa4d991")))
+	assert.Equal(t, "sha256:3247732819d6cd7af0c45a05b30d0b147f05a25ee2e83d7b9707ee25fcdd0f58",
+		canonicalString(MustParse("3247732819d6cd7af0c45a05b30d0b147f05a25ee2e83d7b9707ee25fcdd0f58")))
+}
+
+func TestCachingResolver_SupportsWhatTheDelegateSupports(t *testing.T) {
+	resolver, _, cleanup := cachingResolverTestNew(t, CacheOptions{})
+	defer cleanup()
+
+	assert.True(t, CanResolvePlatform(resolver))
+	assert.False(t, CanListTags(resolver))
+	assert.False(t, CanTellCreationTime(resolver))
+
+	_, e := ListTags(context.Background(), resolver, MustParse("nginx:1.15"))
+	if assert.Error(t, e) {
+		assert.Contains(t, e.Error(), "the resolver does not support listing tags")
+	}
+	_, e = CreationTime(context.Background(), resolver, MustParse("nginx:1.15"))
+	assert.Error(t, e)
+
+	lister := CachingResolverNew(staticTagLister{tags: []string{"1.15"}}, "test", resolver.options)
+	assert.True(t, CanListTags(lister))
+	assert.False(t, CanResolvePlatform(lister))
+
+	_, e = ResolveForPlatform(context.Background(), lister, MustParse("nginx:1.15"), MustParsePlatform("linux/arm64"))
+	if assert.Error(t, e) {
+		assert.Contains(t, e.Error(), "the resolver does not support platforms")
+	}
+}
@@ -0,0 +1,51 @@
+package dockref
+
+import (
+	"github.com/blang/semver"
+	"regexp"
+	"strings"
+)
+
+// dateStampPattern matches dates like 20231009 as used by debian:bookworm-20231009
+var dateStampPattern = regexp.MustCompile(`^(19|20)[0-9]{2}(0[1-9]|1[0-2])(0[1-9]|[12][0-9]|3[01])$`)
+
+var digitsPattern = regexp.MustCompile(`^[0-9]+$`)
+
+// parseVersion parses versions like semver.ParseTolerant,
+// but also calendar versions with leading zeros, e.g. 22.04 is 22.4.0
+func parseVersion(version string) (semver.Version, error) {
+	core, build := version, ""
+	if i := strings.Index(version, "+"); i >= 0 {
+		core, build = version[:i], version[i:]
+	}
+
+	components := strings.Split(core, ".")
+	for i, component := range components {
+		if len(component) > 1 && digitsPattern.MatchString(component) {
+			components[i] = strings.TrimLeft(component, "0")
+			if components[i] == "" {
+				components[i] = "0"
+			}
+		}
+	}
+
+	return semver.ParseTolerant(strings.Join(components, ".") + build)
+}
+
+// isDateStamp reports versions that are dates, e.g. 20231009
+func isDateStamp(version string) bool {
+	return dateStampPattern.MatchString(version)
+}
+
+// splitDateStamp finds a date in the hyphen separated parts of a tag, e.g. bookworm-20231009-slim,
+// the remaining parts are the variant, e.g. bookworm-slim
+func splitDateStamp(tag string) (version string, variant string, ok bool) {
+	parts := strings.Split(tag, "-")
+	for i, part := range parts {
+		if isDateStamp(part) {
+			rest := append(append([]string{}, parts[:i]...), parts[i+1:]...)
+			return part, strings.Join(rest, "-"), true
+		}
+	}
+	return "", tag, false
+}
@@ -0,0 +1,117 @@
+package dockref
+
+import (
+	"context"
+	"github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+	"time"
+)
+
+// ReferenceMatcher selects the references of a ResolverRoute, e.g. a dockproc.Predicate
+type ReferenceMatcher interface {
+	Matches(reference Reference) bool
+}
+
+// ResolverRoute sends matching references to a chain of Resolvers
+type ResolverRoute struct {
+	// Matcher selects the references of this route, nil matches all references
+	Matcher ReferenceMatcher
+	// Resolvers are tried in order until one does not report "not found"
+	Resolvers []Resolver
+}
+
+type compositeResolver struct {
+	routes []ResolverRoute
+}
+
+var _ Resolver = (*compositeResolver)(nil)
+var _ PlatformResolver = (*compositeResolver)(nil)
+var _ TagLister = (*compositeResolver)(nil)
+var _ CreationTimeResolver = (*compositeResolver)(nil)
+
+// CompositeResolverNew creates a Resolver that uses the first route matching the reference
+func CompositeResolverNew(routes []ResolverRoute) Resolver {
+	return &compositeResolver{
+		routes: routes,
+	}
+}
+
+func (c *compositeResolver) Resolve(ctx context.Context, reference Reference) ([]Reference, error) {
+	return c.ResolvePlatform(ctx, reference, Platform{})
+}
+
+func (c *compositeResolver) ResolvePlatform(ctx context.Context, reference Reference, platform Platform) ([]Reference, error) {
+	for _, route := range c.routes {
+		if route.Matcher != nil && !route.Matcher.Matches(reference) {
+			continue
+		}
+
+		return route.resolve(ctx, reference, platform)
+	}
+
+	return nil, errors.Wrap(NotFoundError{Reference: reference.Original()}, "no resolver route matches")
+}
+
+// ListTags uses the first resolver of the matching route that can list the tags of the reference
+func (c *compositeResolver) ListTags(ctx context.Context, reference Reference) ([]string, error) {
+	for _, route := range c.routes {
+		if route.Matcher != nil && !route.Matcher.Matches(reference) {
+			continue
+		}
+
+		for _, resolver := range route.Resolvers {
+			if !CanListTags(resolver) {
+				continue
+			}
+			tags, err := ListTags(ctx, resolver, reference)
+			if !IsNotFound(err) {
+				return tags, err
+			}
+		}
+
+		return nil, errors.Wrap(NotFoundError{Reference: reference.Original()}, "no resolver of the route can list the tags")
+	}
+
+	return nil, errors.Wrap(NotFoundError{Reference: reference.Original()}, "no resolver route matches")
+}
+
+// CreationTime uses the first resolver of the matching route that can tell the creation time of the reference
+func (c *compositeResolver) CreationTime(ctx context.Context, reference Reference) (time.Time, error) {
+	for _, route := range c.routes {
+		if route.Matcher != nil && !route.Matcher.Matches(reference) {
+			continue
+		}
+
+		for _, resolver := range route.Resolvers {
+			if !CanTellCreationTime(resolver) {
+				continue
+			}
+			created, err := CreationTime(ctx, resolver, reference)
+			if !IsNotFound(err) {
+				return created, err
+			}
+		}
+
+		return time.Time{}, errors.Wrap(NotFoundError{Reference: reference.Original()}, "no resolver of the route can tell the creation time")
+	}
+
+	return time.Time{}, errors.Wrap(NotFoundError{Reference: reference.Original()}, "no resolver route matches")
+}
+
+func (route ResolverRoute) resolve(ctx context.Context, reference Reference, platform Platform) ([]Reference, error) {
+	var notFoundErrors *multierror.Error
+
+	for _, resolver := range route.Resolvers {
+		refs, err := ResolveForPlatform(ctx, resolver, reference, platform)
+		if !IsNotFound(err) {
+			return refs, err
+		}
+		notFoundErrors = multierror.Append(notFoundErrors, err)
+	}
+
+	if notFoundErrors == nil {
+		return nil, errors.Wrap(NotFoundError{Reference: reference.Original()}, "resolver route is empty")
+	}
+
+	return nil, errors.Wrap(NotFoundError{Reference: reference.Original()}, notFoundErrors.Error())
+}
@@ -0,0 +1,137 @@
+package dockref
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type funcResolver func(reference Reference) ([]Reference, error)
+
+func (f funcResolver) Resolve(ctx context.Context, reference Reference) ([]Reference, error) {
+	return f(reference)
+}
+
+type domainMatcher string
+
+func (d domainMatcher) Matches(reference Reference) bool {
+	return reference.Domain() == string(d)
+}
+
+func resolvingTo(tag string) Resolver {
+	return funcResolver(func(reference Reference) ([]Reference, error) {
+		return []Reference{reference.WithTag(tag)}, nil
+	})
+}
+
+func failingWith(err error) Resolver {
+	return funcResolver(func(reference Reference) ([]Reference, error) {
+		return nil, err
+	})
+}
+
+type errdefsNotFoundError struct{}
+
+func (errdefsNotFoundError) Error() string {
+	return "No such image"
+}
+
+func (errdefsNotFoundError) NotFound() {}
+
+func TestCompositeResolver_RoutesByMatcher(t *testing.T) {
+	resolver := CompositeResolverNew([]ResolverRoute{
+		{Matcher: domainMatcher("localhost:5000"), Resolvers: []Resolver{resolvingTo("local")}},
+		{Matcher: domainMatcher("quay.io"), Resolvers: []Resolver{resolvingTo("quay")}},
+		{Resolvers: []Resolver{resolvingTo("default")}},
+	})
+
+	for ref, expected := range map[string]string{
+		"localhost:5000/app":  "local",
+		"quay.io/coreos/etcd": "quay",
+		"nginx":               "default",
+	} {
+		refs, err := resolver.Resolve(context.Background(), MustParse(ref))
+		assert.Nil(t, err)
+		if assert.Len(t, refs, 1) {
+			assert.Equal(t, expected, refs[0].Tag(), ref)
+		}
+	}
+}
+
+func TestCompositeResolver_FallsBackOnNotFound(t *testing.T) {
+	resolver := CompositeResolverNew([]ResolverRoute{
+		{Resolvers: []Resolver{
+			failingWith(NotFoundError{Reference: "nginx"}),
+			failingWith(errors.Wrap(errdefsNotFoundError{}, "daemon")),
+			resolvingTo("fallback"),
+			resolvingTo("unused"),
+		}},
+	})
+
+	refs, err := resolver.Resolve(context.Background(), MustParse("nginx"))
+	assert.Nil(t, err)
+	if assert.Len(t, refs, 1) {
+		assert.Equal(t, "fallback", refs[0].Tag())
+	}
+}
+
+func TestCompositeResolver_DoesNotFallBackOnOtherErrors(t *testing.T) {
+	expected := errors.New("unauthorized")
+	resolver := CompositeResolverNew([]ResolverRoute{
+		{Resolvers: []Resolver{failingWith(expected), resolvingTo("unused")}},
+	})
+
+	_, err := resolver.Resolve(context.Background(), MustParse("nginx"))
+	assert.Equal(t, expected, err)
+}
+
+func TestCompositeResolver_NotFound(t *testing.T) {
+	resolver := CompositeResolverNew([]ResolverRoute{
+		{Matcher: domainMatcher("quay.io"), Resolvers: []Resolver{failingWith(NotFoundError{Reference: "quay.io/app"})}},
+		{Matcher: domainMatcher("gcr.io")},
+	})
+
+	_, err := resolver.Resolve(context.Background(), MustParse("quay.io/app"))
+	assert.True(t, IsNotFound(err))
+
+	_, err = resolver.Resolve(context.Background(), MustParse("gcr.io/app"))
+	assert.True(t, IsNotFound(err))
+	assert.Contains(t, err.Error(), "resolver route is empty")
+
+	_, err = resolver.Resolve(context.Background(), MustParse("nginx"))
+	assert.True(t, IsNotFound(err))
+	assert.Contains(t, err.Error(), "no resolver route matches")
+}
+
+func TestCompositeResolver_ResolvePlatform(t *testing.T) {
+	delegate := &countingResolver{results: map[string][]Reference{
+		"nginx:1.15-linux-arm64": {MustParse("nginx:1.15.6@" + ociTestDigestB)},
+	}}
+	resolver := CompositeResolverNew([]ResolverRoute{
+		{Resolvers: []Resolver{delegate}},
+	}).(PlatformResolver)
+
+	refs, err := resolver.ResolvePlatform(context.Background(), MustParse("nginx:1.15"), MustParsePlatform("linux/arm64"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1.15.6@" + ociTestDigestB}, tagsAndDigests(refs))
+
+	_, err = CompositeResolverNew([]ResolverRoute{
+		{Resolvers: []Resolver{resolvingTo("latest")}},
+	}).(PlatformResolver).ResolvePlatform(context.Background(), MustParse("nginx:1.15"), MustParsePlatform("linux/arm64"))
+	assert.Error(t, err)
+}
+
+func TestCompositeResolver_ListTags(t *testing.T) {
+	resolver := CompositeResolverNew([]ResolverRoute{
+		{Matcher: domainMatcher("quay.io"), Resolvers: []Resolver{resolvingTo("1"), staticTagLister{}, staticTagLister{tags: []string{"1", "2"}}}},
+		{Resolvers: []Resolver{resolvingTo("1")}},
+	})
+
+	tags, err := ListTags(context.Background(), resolver, MustParse("quay.io/coreos/etcd:1"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1", "2"}, tags)
+
+	_, err = ListTags(context.Background(), resolver, MustParse("nginx:1"))
+	assert.True(t, IsNotFound(err))
+}
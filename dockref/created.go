@@ -0,0 +1,115 @@
+package dockref
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"time"
+)
+
+// CreationTimeResolver is implemented by Resolvers that can tell when the image of a reference was created
+type CreationTimeResolver interface {
+	CreationTime(ctx context.Context, reference Reference) (time.Time, error)
+}
+
+// CanTellCreationTime reports whether the resolver can tell creation times, decorators like the cache can when the decorated resolver can
+func CanTellCreationTime(resolver Resolver) bool {
+	_, ok := undecorated(resolver).(CreationTimeResolver)
+	return ok
+}
+
+// CreationTime returns the creation time of the reference's image, resolvers that cannot tell fail
+func CreationTime(ctx context.Context, resolver Resolver, reference Reference) (time.Time, error) {
+	creationTimeResolver, ok := resolver.(CreationTimeResolver)
+	if !ok || !CanTellCreationTime(resolver) {
+		return time.Time{}, errors.Errorf("cannot tell the age of %s, the resolver does not support creation times", reference.Original())
+	}
+
+	return creationTimeResolver.CreationTime(ctx, reference)
+}
+
+// FirstMatureTag returns the first of the tags of the reference's repository whose image was created at least minAge before now.
+// The empty string is returned when all images are younger.
+func FirstMatureTag(ctx context.Context, resolver Resolver, reference Reference, tags []string, minAge time.Duration, now time.Time) (string, error) {
+	for _, tag := range tags {
+		tagged, err := Parse(reference.Name() + ":" + tag)
+		if err != nil {
+			return "", err
+		}
+
+		created, err := CreationTime(ctx, resolver, tagged)
+		if err != nil {
+			return "", err
+		}
+
+		if !created.Add(minAge).After(now) {
+			return tag, nil
+		}
+	}
+
+	return "", nil
+}
+
+type imageConfigCreated struct {
+	Created *time.Time `json:"created"`
+}
+
+// imageCreationTime reads the created field of the image config.
+// Manifest lists and indexes use their first image, the images of all platforms are built together.
+func imageCreationTime(reference Reference, manifestRef string, fetchManifest func(dig string) ([]byte, error), fetchBlob func(dig string) ([]byte, error)) (time.Time, error) {
+	content, err := fetchManifest(manifestRef)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var manifest manifestOrIndex
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		return time.Time{}, errors.Wrapf(err, "invalid manifest %s", manifestRef)
+	}
+
+	if manifest.Manifests != nil {
+		image := firstImage(manifest.Manifests)
+		if image == nil {
+			return time.Time{}, errors.Errorf("manifest %s of %s lists no images", manifestRef, reference.Original())
+		}
+		return imageCreationTime(reference, image.Digest, fetchManifest, fetchBlob)
+	}
+
+	if manifest.Config == nil {
+		return time.Time{}, errors.Errorf("manifest %s of %s neither lists manifests nor references a config", manifestRef, reference.Original())
+	}
+
+	content, err = fetchBlob(manifest.Config.Digest)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var config imageConfigCreated
+	if err := json.Unmarshal(content, &config); err != nil {
+		return time.Time{}, errors.Wrapf(err, "invalid config %s", manifest.Config.Digest)
+	}
+
+	if config.Created == nil {
+		return time.Time{}, errors.Errorf("the image of %s has no creation time", reference.Original())
+	}
+
+	return *config.Created, nil
+}
+
+// firstImage returns the first manifest of an index with a platform, manifests without platform are used when none has one.
+// Attestations like those of BuildKit have the platform unknown/unknown and are skipped.
+func firstImage(manifests []platformDescriptor) *platformDescriptor {
+	var withoutPlatform *platformDescriptor
+	for i := range manifests {
+		platform := manifests[i].Platform
+		switch {
+		case platform == nil:
+			if withoutPlatform == nil {
+				withoutPlatform = &manifests[i]
+			}
+		case platform.OS != "unknown" && platform.Architecture != "unknown":
+			return &manifests[i]
+		}
+	}
+	return withoutPlatform
+}
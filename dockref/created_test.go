@@ -0,0 +1,126 @@
+package dockref
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// createdAt knows the creation time of images by tag
+type createdAt struct {
+	Resolver
+	times map[string]time.Time
+}
+
+func (c createdAt) CreationTime(ctx context.Context, reference Reference) (time.Time, error) {
+	created, ok := c.times[reference.Tag()]
+	if !ok {
+		return time.Time{}, NotFoundError{Reference: reference.Original()}
+	}
+	return created, nil
+}
+
+func TestRegistryResolver_CreationTime(t *testing.T) {
+	registry := testRegistryNew()
+	defer registry.Close()
+
+	amd64Config := registry.pushBlob(`{"created": "2021-05-04T12:00:00Z", "os": "linux", "architecture": "amd64"}`)
+	amd64 := registry.pushUntagged(`{"mediaType": "` + mediaTypeManifestV2 + `", "config": {"digest": "` + amd64Config + `"}}`)
+	registry.push("library/nginx", `{
+		"mediaType": "`+mediaTypeManifestList+`",
+		"manifests": [{"digest": "`+amd64+`", "platform": {"os": "linux", "architecture": "amd64"}}]
+	}`, "1.15")
+	noCreated := registry.pushBlob(`{"os": "linux", "architecture": "amd64"}`)
+	registry.push("library/nginx", `{"mediaType": "`+mediaTypeManifestV2+`", "config": {"digest": "`+noCreated+`"}}`, "1.14")
+
+	resolver := registry.resolver()
+
+	created, err := CreationTime(context.Background(), resolver, MustParse(registry.domain()+"/library/nginx:1.15"))
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2021, 5, 4, 12, 0, 0, 0, time.UTC), created.UTC())
+
+	_, err = CreationTime(context.Background(), resolver, MustParse(registry.domain()+"/library/nginx:1.14"))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "has no creation time")
+	}
+
+	_, err = CreationTime(context.Background(), resolver, MustParse(registry.domain()+"/library/nginx:1.13"))
+	assert.True(t, IsNotFound(err))
+}
+
+func TestRegistryResolver_CreationTimeSkipsAttestations(t *testing.T) {
+	registry := testRegistryNew()
+	defer registry.Close()
+
+	attestationConfig := registry.pushBlob(`{}`)
+	attestation := registry.pushUntagged(`{"mediaType": "` + mediaTypeOCIManifest + `", "config": {"digest": "` + attestationConfig + `"}}`)
+	amd64Config := registry.pushBlob(`{"created": "2021-05-04T12:00:00Z", "os": "linux", "architecture": "amd64"}`)
+	amd64 := registry.pushUntagged(`{"mediaType": "` + mediaTypeOCIManifest + `", "config": {"digest": "` + amd64Config + `"}}`)
+	registry.push("library/nginx", `{
+		"mediaType": "`+mediaTypeOCIIndex+`",
+		"manifests": [
+			{"digest": "`+attestation+`", "platform": {"os": "unknown", "architecture": "unknown"}, "annotations": {"vnd.docker.reference.type": "attestation-manifest"}},
+			{"digest": "`+amd64+`", "platform": {"os": "linux", "architecture": "amd64"}}
+		]
+	}`, "1.15")
+	registry.push("library/nginx", `{
+		"mediaType": "`+mediaTypeOCIIndex+`",
+		"manifests": [{"digest": "`+attestation+`", "platform": {"os": "unknown", "architecture": "unknown"}}]
+	}`, "1.14")
+
+	resolver := registry.resolver()
+
+	created, err := CreationTime(context.Background(), resolver, MustParse(registry.domain()+"/library/nginx:1.15"))
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2021, 5, 4, 12, 0, 0, 0, time.UTC), created.UTC())
+
+	_, err = CreationTime(context.Background(), resolver, MustParse(registry.domain()+"/library/nginx:1.14"))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "lists no images")
+	}
+}
+
+func TestCreationTime_RequiresCreationTimeResolver(t *testing.T) {
+	_, err := CreationTime(context.Background(), resolvingTo("1.15"), MustParse("nginx:1.15"))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "does not support creation times")
+	}
+}
+
+func TestFirstMatureTag(t *testing.T) {
+	now := time.Date(2021, 5, 10, 0, 0, 0, 0, time.UTC)
+	resolver := createdAt{times: map[string]time.Time{
+		"1.17": now.Add(-time.Hour),
+		"1.16": now.Add(-72 * time.Hour),
+		"1.15": now.Add(-96 * time.Hour),
+	}}
+	reference := MustParse("nginx:1.14")
+
+	tag, err := FirstMatureTag(context.Background(), resolver, reference, []string{"1.17", "1.16", "1.15"}, 72*time.Hour, now)
+	assert.Nil(t, err)
+	assert.Equal(t, "1.16", tag)
+
+	tag, err = FirstMatureTag(context.Background(), resolver, reference, []string{"1.17"}, 72*time.Hour, now)
+	assert.Nil(t, err)
+	assert.Equal(t, "", tag)
+
+	_, err = FirstMatureTag(context.Background(), resolver, reference, []string{"1.18"}, 72*time.Hour, now)
+	assert.True(t, IsNotFound(err))
+}
+
+func TestCompositeResolver_CreationTime(t *testing.T) {
+	created := time.Date(2021, 5, 4, 12, 0, 0, 0, time.UTC)
+	resolver := CompositeResolverNew([]ResolverRoute{{Resolvers: []Resolver{
+		resolvingTo("1.15"),
+		createdAt{times: map[string]time.Time{}},
+		createdAt{times: map[string]time.Time{"1.15": created}},
+	}}})
+
+	actual, err := CreationTime(context.Background(), resolver, MustParse("nginx:1.15"))
+	assert.Nil(t, err)
+	assert.Equal(t, created, actual)
+
+	_, err = CreationTime(context.Background(), resolver, MustParse("nginx:1.14"))
+	assert.True(t, IsNotFound(err))
+}
@@ -0,0 +1,216 @@
+package dockref
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	dockerHubAuthKey        = "https://index.docker.io/v1/"
+	identityTokenUsername   = "<token>"
+	credentialsNotFoundText = "credentials not found in native keychain"
+)
+
+// Credentials used to authenticate against a registry
+type Credentials struct {
+	Username      string
+	Password      string
+	IdentityToken string
+}
+
+// IsEmpty returns true when there is nothing to authenticate with
+func (c Credentials) IsEmpty() bool {
+	return c.Username == "" && c.Password == "" && c.IdentityToken == ""
+}
+
+type CredentialStore interface {
+	// Credentials returns the credentials for the given registry domain, empty Credentials when unknown
+	Credentials(domain string) (Credentials, error)
+}
+
+type dockerConfigAuth struct {
+	Auth          string `json:"auth,omitempty"`
+	Username      string `json:"username,omitempty"`
+	Password      string `json:"password,omitempty"`
+	IdentityToken string `json:"identitytoken,omitempty"`
+}
+
+type dockerConfig struct {
+	Auths       map[string]dockerConfigAuth `json:"auths"`
+	CredsStore  string                      `json:"credsStore,omitempty"`
+	CredHelpers map[string]string           `json:"credHelpers,omitempty"`
+}
+
+type dockerConfigCredentialStore struct {
+	osGetenv   func(key string) string
+	readFile   func(filename string) ([]byte, error)
+	execHelper func(helper string, serverURL string) ([]byte, error)
+
+	once      sync.Once
+	config    dockerConfig
+	configErr error
+}
+
+var _ CredentialStore = (*dockerConfigCredentialStore)(nil)
+
+// DockerConfigCredentialStoreNew creates a CredentialStore sharing the login state of the docker cli,
+// i.e. ~/.docker/config.json (or $DOCKER_CONFIG/config.json) including credsStore and credHelpers
+func DockerConfigCredentialStoreNew() CredentialStore {
+	return &dockerConfigCredentialStore{
+		osGetenv:   os.Getenv,
+		readFile:   ioutil.ReadFile,
+		execHelper: execCredentialHelper,
+	}
+}
+
+func (store *dockerConfigCredentialStore) configFile() string {
+	dir := store.osGetenv("DOCKER_CONFIG")
+	if dir == "" {
+		dir = filepath.Join(store.osGetenv("HOME"), ".docker")
+	}
+	return filepath.Join(dir, "config.json")
+}
+
+func (store *dockerConfigCredentialStore) load() (dockerConfig, error) {
+	store.once.Do(func() {
+		content, err := store.readFile(store.configFile())
+		if os.IsNotExist(err) {
+			return
+		}
+		if err != nil {
+			store.configErr = err
+			return
+		}
+
+		err = json.Unmarshal(content, &store.config)
+		if err != nil {
+			store.configErr = errors.Wrapf(err, "invalid docker config %s", store.configFile())
+		}
+	})
+
+	return store.config, store.configErr
+}
+
+func (store *dockerConfigCredentialStore) Credentials(domain string) (Credentials, error) {
+	config, err := store.load()
+	if err != nil {
+		return Credentials{}, err
+	}
+
+	serverURL := domain
+	if domain == dockerHubDomain {
+		serverURL = dockerHubAuthKey
+	}
+
+	helper := config.CredHelpers[domain]
+	if helper == "" {
+		helper = config.CredsStore
+	}
+
+	if helper != "" {
+		credentials, err := store.fromHelper(helper, serverURL)
+		if err != nil || !credentials.IsEmpty() {
+			return credentials, err
+		}
+	}
+
+	for key, auth := range config.Auths {
+		if authKeyToDomain(key) == domain {
+			return auth.credentials()
+		}
+	}
+
+	return Credentials{}, nil
+}
+
+type helperCredentials struct {
+	ServerURL string
+	Username  string
+	Secret    string
+}
+
+func (store *dockerConfigCredentialStore) fromHelper(helper string, serverURL string) (Credentials, error) {
+	out, err := store.execHelper(helper, serverURL)
+	if err != nil {
+		if strings.Contains(string(out), credentialsNotFoundText) {
+			return Credentials{}, nil
+		}
+		return Credentials{}, errors.Wrapf(err, "credential helper docker-credential-%s failed: %s", helper, strings.TrimSpace(string(out)))
+	}
+
+	var hc helperCredentials
+	if err := json.Unmarshal(out, &hc); err != nil {
+		return Credentials{}, errors.Wrapf(err, "invalid response from credential helper docker-credential-%s", helper)
+	}
+
+	if hc.Username == identityTokenUsername {
+		return Credentials{IdentityToken: hc.Secret}, nil
+	}
+
+	return Credentials{Username: hc.Username, Password: hc.Secret}, nil
+}
+
+func (auth dockerConfigAuth) credentials() (Credentials, error) {
+	credentials := Credentials{
+		Username:      auth.Username,
+		Password:      auth.Password,
+		IdentityToken: auth.IdentityToken,
+	}
+
+	if auth.Auth != "" {
+		decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+		if err != nil {
+			return Credentials{}, errors.Wrap(err, "invalid auth in docker config")
+		}
+		parts := strings.SplitN(string(decoded), ":", 2)
+		if len(parts) != 2 {
+			return Credentials{}, errors.New("invalid auth in docker config: expected username:password")
+		}
+		credentials.Username = parts[0]
+		credentials.Password = parts[1]
+	}
+
+	return credentials, nil
+}
+
+// authKeyToDomain normalizes keys of auths like https://index.docker.io/v1/ or http://localhost:5000
+func authKeyToDomain(key string) string {
+	if key == dockerHubAuthKey {
+		return dockerHubDomain
+	}
+
+	domain := key
+	if idx := strings.Index(domain, "://"); idx >= 0 {
+		domain = domain[idx+3:]
+	}
+	if idx := strings.Index(domain, "/"); idx >= 0 {
+		domain = domain[:idx]
+	}
+
+	switch domain {
+	case "index.docker.io", dockerHubRegistryDomain:
+		return dockerHubDomain
+	}
+
+	return domain
+}
+
+// execCredentialHelper speaks the docker-credential-helpers protocol: the server URL is sent via stdin, the response is JSON on stdout
+func execCredentialHelper(helper string, serverURL string) ([]byte, error) {
+	cmd := exec.Command("docker-credential-"+helper, "get")
+	cmd.Stdin = strings.NewReader(serverURL)
+	out := bytes.NewBuffer(nil)
+	cmd.Stdout = out
+	cmd.Stderr = out
+
+	err := cmd.Run()
+	return out.Bytes(), err
+}
@@ -0,0 +1,178 @@
+package dockref
+
+import (
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type staticCredentialStore map[string]Credentials
+
+func (s staticCredentialStore) Credentials(domain string) (Credentials, error) {
+	return s[domain], nil
+}
+
+type helperCall struct {
+	helper, serverURL string
+}
+
+func credentialStoreTestNew(config string, helperResponses map[string]string) (*dockerConfigCredentialStore, *[]helperCall) {
+	store := DockerConfigCredentialStoreNew().(*dockerConfigCredentialStore)
+	store.osGetenv = func(key string) string {
+		switch key {
+		case "HOME":
+			return "/home/mene"
+		}
+		return ""
+	}
+	store.readFile = func(filename string) ([]byte, error) {
+		if filename != filepath.Join("/home/mene", ".docker", "config.json") || config == "" {
+			return nil, &os.PathError{Op: "open", Path: filename, Err: os.ErrNotExist}
+		}
+		return []byte(config), nil
+	}
+
+	calls := make([]helperCall, 0)
+	store.execHelper = func(helper string, serverURL string) ([]byte, error) {
+		calls = append(calls, helperCall{helper: helper, serverURL: serverURL})
+		response, ok := helperResponses[helper+" "+serverURL]
+		if !ok {
+			return []byte(credentialsNotFoundText), errors.New("exit status 1")
+		}
+		return []byte(response), nil
+	}
+
+	return store, &calls
+}
+
+func TestDockerConfigCredentialStore_Credentials_Auths(t *testing.T) {
+	store, calls := credentialStoreTestNew(`{
+		"auths": {
+			"https://index.docker.io/v1/": {"auth": "bWVuZTpodWJzZWNyZXQ="},
+			"http://localhost:5000": {"username": "local", "password": "localsecret"},
+			"quay.io": {"identitytoken": "quaytoken"}
+		}
+	}`, nil)
+
+	credentials, e := store.Credentials("docker.io")
+	assert.Nil(t, e)
+	assert.Equal(t, Credentials{Username: "mene", Password: "hubsecret"}, credentials)
+
+	credentials, e = store.Credentials("localhost:5000")
+	assert.Nil(t, e)
+	assert.Equal(t, Credentials{Username: "local", Password: "localsecret"}, credentials)
+
+	credentials, e = store.Credentials("quay.io")
+	assert.Nil(t, e)
+	assert.Equal(t, Credentials{IdentityToken: "quaytoken"}, credentials)
+
+	credentials, e = store.Credentials("gcr.io")
+	assert.Nil(t, e)
+	assert.True(t, credentials.IsEmpty())
+
+	assert.Empty(t, *calls)
+}
+
+func TestDockerConfigCredentialStore_Credentials_CredsStore(t *testing.T) {
+	store, calls := credentialStoreTestNew(`{
+		"auths": {
+			"https://index.docker.io/v1/": {},
+			"localhost:5000": {"auth": "bG9jYWw6bG9jYWxzZWNyZXQ="}
+		},
+		"credsStore": "desktop"
+	}`, map[string]string{
+		"desktop https://index.docker.io/v1/": `{"ServerURL": "https://index.docker.io/v1/", "Username": "mene", "Secret": "hubsecret"}`,
+	})
+
+	credentials, e := store.Credentials("docker.io")
+	assert.Nil(t, e)
+	assert.Equal(t, Credentials{Username: "mene", Password: "hubsecret"}, credentials)
+
+	// not in the store, falls back to auths
+	credentials, e = store.Credentials("localhost:5000")
+	assert.Nil(t, e)
+	assert.Equal(t, Credentials{Username: "local", Password: "localsecret"}, credentials)
+
+	assert.Equal(t, []helperCall{
+		{helper: "desktop", serverURL: "https://index.docker.io/v1/"},
+		{helper: "desktop", serverURL: "localhost:5000"},
+	}, *calls)
+}
+
+func TestDockerConfigCredentialStore_Credentials_CredHelpers(t *testing.T) {
+	store, calls := credentialStoreTestNew(`{
+		"credsStore": "desktop",
+		"credHelpers": {
+			"gcr.io": "gcloud",
+			"registry.example.com": "example"
+		}
+	}`, map[string]string{
+		"gcloud gcr.io":                `{"ServerURL": "gcr.io", "Username": "_dcgcloud_token", "Secret": "gcloudsecret"}`,
+		"example registry.example.com": `{"ServerURL": "registry.example.com", "Username": "<token>", "Secret": "exampletoken"}`,
+	})
+
+	credentials, e := store.Credentials("gcr.io")
+	assert.Nil(t, e)
+	assert.Equal(t, Credentials{Username: "_dcgcloud_token", Password: "gcloudsecret"}, credentials)
+
+	credentials, e = store.Credentials("registry.example.com")
+	assert.Nil(t, e)
+	assert.Equal(t, Credentials{IdentityToken: "exampletoken"}, credentials)
+
+	assert.Equal(t, []helperCall{
+		{helper: "gcloud", serverURL: "gcr.io"},
+		{helper: "example", serverURL: "registry.example.com"},
+	}, *calls)
+}
+
+func TestDockerConfigCredentialStore_Credentials_HelperError(t *testing.T) {
+	store, _ := credentialStoreTestNew(`{"credsStore": "broken"}`, nil)
+	store.execHelper = func(helper string, serverURL string) ([]byte, error) {
+		return []byte("something went wrong"), errors.New("exit status 1")
+	}
+
+	_, e := store.Credentials("docker.io")
+	assert.Error(t, e)
+	assert.Contains(t, e.Error(), "docker-credential-broken")
+	assert.Contains(t, e.Error(), "something went wrong")
+}
+
+func TestDockerConfigCredentialStore_Credentials_NoConfig(t *testing.T) {
+	store, _ := credentialStoreTestNew("", nil)
+
+	credentials, e := store.Credentials("docker.io")
+	assert.Nil(t, e)
+	assert.True(t, credentials.IsEmpty())
+}
+
+func TestDockerConfigCredentialStore_Credentials_InvalidConfig(t *testing.T) {
+	store, _ := credentialStoreTestNew("{ invalid", nil)
+
+	_, e := store.Credentials("docker.io")
+	assert.Error(t, e)
+}
+
+func TestDockerConfigCredentialStore_configFile(t *testing.T) {
+	store := DockerConfigCredentialStoreNew().(*dockerConfigCredentialStore)
+	store.osGetenv = func(key string) string {
+		switch key {
+		case "DOCKER_CONFIG":
+			return "/etc/docker-config"
+		case "HOME":
+			return "/home/mene"
+		}
+		return ""
+	}
+
+	assert.Equal(t, filepath.Join("/etc/docker-config", "config.json"), store.configFile())
+}
+
+func TestAuthKeyToDomain(t *testing.T) {
+	assert.Equal(t, "docker.io", authKeyToDomain("https://index.docker.io/v1/"))
+	assert.Equal(t, "docker.io", authKeyToDomain("index.docker.io"))
+	assert.Equal(t, "localhost:5000", authKeyToDomain("http://localhost:5000"))
+	assert.Equal(t, "quay.io", authKeyToDomain("https://quay.io/v2/"))
+	assert.Equal(t, "quay.io", authKeyToDomain("quay.io"))
+}
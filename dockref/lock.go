@@ -0,0 +1,232 @@
+package dockref
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+const lockVersion = 1
+
+// LockEntry records how one image reference in an input file was resolved
+type LockEntry struct {
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Original string `json:"original"`
+	Name     string `json:"name"`
+	Tag      string `json:"tag,omitempty"`
+	Digest   string `json:"digest"`
+	// Platform is set when the digest is the one of a platform's manifest instead of the manifest list
+	Platform string `json:"platform,omitempty"`
+}
+
+// Lock is the content of a lock file, e.g. dockmoor.lock
+type Lock struct {
+	Version    int         `json:"version"`
+	References []LockEntry `json:"references"`
+}
+
+// LockNew creates an empty Lock
+func LockNew() *Lock {
+	return &Lock{
+		Version:    lockVersion,
+		References: make([]LockEntry, 0),
+	}
+}
+
+// LockRead parses a lock file
+func LockRead(reader io.Reader) (*Lock, error) {
+	lock := LockNew()
+	err := json.NewDecoder(reader).Decode(lock)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid lock file")
+	}
+
+	if lock.Version != lockVersion {
+		return nil, errors.Errorf("unsupported lock file version %d", lock.Version)
+	}
+
+	return lock, nil
+}
+
+// LockReadFile reads the lock file with the given name, a missing file results in an empty Lock
+func LockReadFile(filename string) (*Lock, error) {
+	content, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return LockNew(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return LockRead(bytes.NewReader(content))
+}
+
+// Write writes the lock file with entries sorted by file and line
+func (lock *Lock) Write(writer io.Writer) error {
+	sort.SliceStable(lock.References, func(i, j int) bool {
+		a, b := lock.References[i], lock.References[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Line < b.Line
+	})
+
+	content, err := json.MarshalIndent(lock, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = writer.Write(append(content, '\n'))
+	return err
+}
+
+// WriteFile writes the lock file with the given name
+func (lock *Lock) WriteFile(filename string) error {
+	file, err := ioutil.TempFile(filepath.Dir(filename), ".dockmoor.lock")
+	if err != nil {
+		return err
+	}
+
+	// TempFile creates files only the owner can read, keep the mode of the existing lock file instead
+	mode := os.FileMode(0644)
+	if info, e := os.Stat(filename); e == nil {
+		mode = info.Mode()
+	}
+
+	err = lock.Write(file)
+	errClose := file.Close()
+	if err == nil {
+		err = errClose
+	}
+	if err == nil {
+		err = os.Chmod(file.Name(), mode)
+	}
+	if err != nil {
+		deliberatelyUnsued(os.Remove(file.Name()))
+		return err
+	}
+
+	return os.Rename(file.Name(), filename)
+}
+
+// RemoveFile removes all entries of the given input file
+func (lock *Lock) RemoveFile(file string) {
+	kept := make([]LockEntry, 0, len(lock.References))
+	for _, entry := range lock.References {
+		if entry.File != file {
+			kept = append(kept, entry)
+		}
+	}
+	lock.References = kept
+}
+
+// Add adds an entry, replacing any entry for the same reference at the same position
+func (lock *Lock) Add(entry LockEntry) {
+	for i, e := range lock.References {
+		if e.File == entry.File && e.Line == entry.Line && e.Original == entry.Original {
+			lock.References[i] = entry
+			return
+		}
+	}
+	lock.References = append(lock.References, entry)
+}
+
+// LockEntryNew creates the entry for the original reference as found in file at line
+func LockEntryNew(file string, line int, original Reference, resolved Reference) LockEntry {
+	name := ""
+	if resolved.Named() != nil {
+		name = resolved.Name()
+	}
+
+	return LockEntry{
+		File:     file,
+		Line:     line,
+		Original: original.Original(),
+		Name:     name,
+		Tag:      resolved.Tag(),
+		Digest:   resolved.DigestString(),
+	}
+}
+
+type lockResolver struct {
+	filename string
+	readFile func(filename string) (*Lock, error)
+
+	once    sync.Once
+	lock    *Lock
+	lockErr error
+}
+
+var _ Resolver = (*lockResolver)(nil)
+var _ PlatformResolver = (*lockResolver)(nil)
+
+// LockResolverNew creates a Resolver that answers from the lock file only and never contacts a registry or daemon.
+// References that are not in the lock file are reported as not found.
+func LockResolverNew(filename string) Resolver {
+	return &lockResolver{
+		filename: filename,
+		readFile: lockReadExistingFile,
+	}
+}
+
+func lockReadExistingFile(filename string) (*Lock, error) {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot read lock file")
+	}
+
+	return LockRead(bytes.NewReader(content))
+}
+
+func (r *lockResolver) load() (*Lock, error) {
+	r.once.Do(func() {
+		r.lock, r.lockErr = r.readFile(r.filename)
+	})
+	return r.lock, r.lockErr
+}
+
+func (r *lockResolver) Resolve(ctx context.Context, reference Reference) ([]Reference, error) {
+	return r.ResolvePlatform(ctx, reference, Platform{})
+}
+
+// ResolvePlatform only uses entries that were locked for the same platform
+func (r *lockResolver) ResolvePlatform(ctx context.Context, reference Reference, platform Platform) ([]Reference, error) {
+	lock, err := r.load()
+	if err != nil {
+		return nil, err
+	}
+
+	var found *LockEntry
+	for i, entry := range lock.References {
+		if entry.Original != reference.Original() || entry.Platform != platform.String() {
+			continue
+		}
+
+		if found != nil && (found.Tag != entry.Tag || found.Digest != entry.Digest) {
+			return nil, errors.Errorf("%s is locked ambiguously in %s: %s:%d and %s:%d", reference.Original(), r.filename, found.File, found.Line, entry.File, entry.Line)
+		}
+		found = &lock.References[i]
+	}
+
+	if found == nil {
+		return nil, errors.Wrapf(NotFoundError{Reference: reference.Original()}, "not in lock file %s", r.filename)
+	}
+
+	if found.Digest == "" {
+		return nil, errors.Errorf("%s has no digest in lock file %s", reference.Original(), r.filename)
+	}
+
+	if reference.Named() == nil {
+		return []Reference{reference}, nil
+	}
+
+	return []Reference{reference.WithTag(found.Tag).WithDigest(found.Digest)}, nil
+}
@@ -0,0 +1,173 @@
+package dockref
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const lockTestDigest = "sha256:31b8e90a349d1fce7621f5a5a08e4fc519b634f7d3feb09d53fac9b12aa4d991"
+
+func lockResolverTestNew(lock *Lock) *lockResolver {
+	resolver := LockResolverNew("dockmoor.lock").(*lockResolver)
+	resolver.readFile = func(filename string) (*Lock, error) {
+		return lock, nil
+	}
+	return resolver
+}
+
+func TestLock_WriteAndRead(t *testing.T) {
+	lock := LockNew()
+	lock.Add(LockEntryNew("b/Dockerfile", 1, MustParse("nginx"), MustParse("nginx:1.15.6@"+lockTestDigest)))
+	lock.Add(LockEntryNew("a/Dockerfile", 5, MustParse("nginx:1.15"), MustParse("nginx:1.15.6@"+lockTestDigest)))
+	lock.Add(LockEntryNew("a/Dockerfile", 1, MustParse("alpine"), MustParse("alpine:3.8@"+lockTestDigest)))
+
+	buffer := bytes.NewBuffer(nil)
+	assert.Nil(t, lock.Write(buffer))
+
+	read, err := LockRead(buffer)
+	assert.Nil(t, err)
+	assert.Equal(t, []LockEntry{
+		{File: "a/Dockerfile", Line: 1, Original: "alpine", Name: "docker.io/library/alpine", Tag: "3.8", Digest: lockTestDigest},
+		{File: "a/Dockerfile", Line: 5, Original: "nginx:1.15", Name: "docker.io/library/nginx", Tag: "1.15.6", Digest: lockTestDigest},
+		{File: "b/Dockerfile", Line: 1, Original: "nginx", Name: "docker.io/library/nginx", Tag: "1.15.6", Digest: lockTestDigest},
+	}, read.References)
+}
+
+func TestLock_AddReplacesSamePosition(t *testing.T) {
+	lock := LockNew()
+	lock.Add(LockEntry{File: "Dockerfile", Line: 1, Original: "nginx", Tag: "1.15.5"})
+	lock.Add(LockEntry{File: "Dockerfile", Line: 1, Original: "nginx", Tag: "1.15.6"})
+	lock.Add(LockEntry{File: "Dockerfile", Line: 2, Original: "nginx", Tag: "1.15.6"})
+
+	assert.Equal(t, []LockEntry{
+		{File: "Dockerfile", Line: 1, Original: "nginx", Tag: "1.15.6"},
+		{File: "Dockerfile", Line: 2, Original: "nginx", Tag: "1.15.6"},
+	}, lock.References)
+}
+
+func TestLock_RemoveFile(t *testing.T) {
+	lock := LockNew()
+	lock.Add(LockEntry{File: "a", Line: 1, Original: "nginx"})
+	lock.Add(LockEntry{File: "b", Line: 1, Original: "nginx"})
+
+	lock.RemoveFile("a")
+
+	assert.Equal(t, []LockEntry{{File: "b", Line: 1, Original: "nginx"}}, lock.References)
+}
+
+func TestLockRead_RejectsInvalidContent(t *testing.T) {
+	_, err := LockRead(strings.NewReader("{ invalid"))
+	assert.Error(t, err)
+
+	_, err = LockRead(strings.NewReader(`{"version": 2, "references": []}`))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "unsupported lock file version 2")
+}
+
+func TestLockReadFile_MissingFileIsEmpty(t *testing.T) {
+	lock, err := LockReadFile(filepath.Join(os.TempDir(), "does", "not", "exist.lock"))
+	assert.Nil(t, err)
+	assert.Empty(t, lock.References)
+}
+
+func TestLock_WriteFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dockmoor-lock")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "dockmoor.lock")
+	lock := LockNew()
+	lock.Add(LockEntry{File: "Dockerfile", Line: 1, Original: "nginx", Digest: lockTestDigest})
+	assert.Nil(t, lock.WriteFile(filename))
+
+	read, err := LockReadFile(filename)
+	assert.Nil(t, err)
+	assert.Equal(t, lock.References, read.References)
+}
+
+func TestLock_WriteFile_Mode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dockmoor-lock")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "dockmoor.lock")
+	assert.Nil(t, LockNew().WriteFile(filename))
+
+	info, err := os.Stat(filename)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0644), info.Mode().Perm())
+
+	assert.Nil(t, os.Chmod(filename, 0664))
+	assert.Nil(t, LockNew().WriteFile(filename))
+
+	info, err = os.Stat(filename)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0664), info.Mode().Perm())
+}
+
+func TestLockResolver_Resolve(t *testing.T) {
+	lock := LockNew()
+	lock.Add(LockEntry{File: "Dockerfile", Line: 1, Original: "nginx", Name: "docker.io/library/nginx", Tag: "1.15.6", Digest: lockTestDigest})
+	lock.Add(LockEntry{File: "other/Dockerfile", Line: 3, Original: "nginx", Name: "docker.io/library/nginx", Tag: "1.15.6", Digest: lockTestDigest})
+	resolver := lockResolverTestNew(lock)
+
+	refs, err := resolver.Resolve(context.Background(), MustParse("nginx"))
+	assert.Nil(t, err)
+	if assert.Len(t, refs, 1) {
+		assert.Equal(t, "docker.io/library/nginx", refs[0].Name())
+		assert.Equal(t, "1.15.6", refs[0].Tag())
+		assert.Equal(t, lockTestDigest, refs[0].DigestString())
+	}
+}
+
+func TestLockResolver_ResolvePlatform(t *testing.T) {
+	lock := LockNew()
+	lock.Add(LockEntry{File: "Dockerfile", Line: 1, Original: "nginx", Name: "docker.io/library/nginx", Tag: "1.15.6", Digest: ociTestDigestA})
+	lock.Add(LockEntry{File: "Dockerfile", Line: 3, Original: "nginx", Name: "docker.io/library/nginx", Tag: "1.15.6", Digest: ociTestDigestB, Platform: "linux/arm64"})
+	resolver := lockResolverTestNew(lock)
+
+	refs, err := resolver.Resolve(context.Background(), MustParse("nginx"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1.15.6@" + ociTestDigestA}, tagsAndDigests(refs))
+
+	refs, err = resolver.ResolvePlatform(context.Background(), MustParse("nginx"), MustParsePlatform("linux/arm64"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1.15.6@" + ociTestDigestB}, tagsAndDigests(refs))
+
+	_, err = resolver.ResolvePlatform(context.Background(), MustParse("nginx"), MustParsePlatform("linux/amd64"))
+	assert.True(t, IsNotFound(err))
+}
+
+func TestLockResolver_ResolveFailsForUnlockedReference(t *testing.T) {
+	resolver := lockResolverTestNew(LockNew())
+
+	_, err := resolver.Resolve(context.Background(), MustParse("nginx:1.15"))
+	assert.Error(t, err)
+	assert.True(t, IsNotFound(err))
+	assert.Contains(t, err.Error(), "not in lock file dockmoor.lock")
+}
+
+func TestLockResolver_ResolveFailsForAmbiguousReference(t *testing.T) {
+	lock := LockNew()
+	lock.Add(LockEntry{File: "a", Line: 1, Original: "nginx", Tag: "1.15.5", Digest: lockTestDigest})
+	lock.Add(LockEntry{File: "b", Line: 1, Original: "nginx", Tag: "1.15.6", Digest: lockTestDigest})
+	resolver := lockResolverTestNew(lock)
+
+	_, err := resolver.Resolve(context.Background(), MustParse("nginx"))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "ambiguously")
+}
+
+func TestLockResolver_ResolveFailsWithoutLockFile(t *testing.T) {
+	resolver := LockResolverNew(filepath.Join(os.TempDir(), "does", "not", "exist.lock"))
+
+	_, err := resolver.Resolve(context.Background(), MustParse("nginx"))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "cannot read lock file")
+}
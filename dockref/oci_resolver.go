@@ -0,0 +1,240 @@
+package dockref
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ociLayoutFile                 = "oci-layout"
+	ociIndexFile                  = "index.json"
+	ociRefNameAnnotation          = "org.opencontainers.image.ref.name"
+	containerdImageNameAnnotation = "io.containerd.image.name"
+)
+
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type ociIndex struct {
+	SchemaVersion int             `json:"schemaVersion"`
+	Manifests     []ociDescriptor `json:"manifests"`
+}
+
+type ociLayout struct {
+	ImageLayoutVersion string `json:"imageLayoutVersion"`
+}
+
+// ociImage is a tagged manifest of an image index, an empty name matches every repository
+type ociImage struct {
+	name   string
+	tag    string
+	digest string
+}
+
+type ociLayoutResolver struct {
+	dir      string
+	readFile func(filename string) ([]byte, error)
+
+	once      sync.Once
+	images    []ociImage
+	imagesErr error
+}
+
+var _ Resolver = (*ociLayoutResolver)(nil)
+var _ PlatformResolver = (*ociLayoutResolver)(nil)
+var _ TagLister = (*ociLayoutResolver)(nil)
+var _ CreationTimeResolver = (*ociLayoutResolver)(nil)
+
+// OCILayoutResolverNew creates a Resolver that reads the OCI image layout in dir,
+// i.e. the org.opencontainers.image.ref.name annotations of its index.json
+func OCILayoutResolverNew(dir string) Resolver {
+	return &ociLayoutResolver{
+		dir:      dir,
+		readFile: ioutil.ReadFile,
+	}
+}
+
+func (r *ociLayoutResolver) load() ([]ociImage, error) {
+	r.once.Do(func() {
+		r.images, r.imagesErr = r.readLayout()
+	})
+	return r.images, r.imagesErr
+}
+
+func (r *ociLayoutResolver) readLayout() ([]ociImage, error) {
+	content, err := r.readFile(filepath.Join(r.dir, ociLayoutFile))
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s is not an OCI image layout", r.dir)
+	}
+
+	var layout ociLayout
+	if err := json.Unmarshal(content, &layout); err != nil {
+		return nil, errors.Wrapf(err, "invalid %s in %s", ociLayoutFile, r.dir)
+	}
+
+	content, err = r.readFile(filepath.Join(r.dir, ociIndexFile))
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s is not an OCI image layout", r.dir)
+	}
+
+	images, err := ociImagesFromIndex(content)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid %s in %s", ociIndexFile, r.dir)
+	}
+
+	return images, nil
+}
+
+func (r *ociLayoutResolver) Resolve(ctx context.Context, reference Reference) ([]Reference, error) {
+	images, err := r.load()
+	if err != nil {
+		return nil, err
+	}
+
+	return resolveFromImages(reference, images)
+}
+
+func (r *ociLayoutResolver) ResolvePlatform(ctx context.Context, reference Reference, platform Platform) ([]Reference, error) {
+	refs, err := r.Resolve(ctx, reference)
+	if err != nil {
+		return nil, err
+	}
+
+	return withPlatformDigest(reference, refs, platform, r.readBlob, r.readBlob)
+}
+
+func (r *ociLayoutResolver) CreationTime(ctx context.Context, reference Reference) (time.Time, error) {
+	refs, err := r.Resolve(ctx, reference)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return imageCreationTime(reference, refs[0].DigestString(), r.readBlob, r.readBlob)
+}
+
+func (r *ociLayoutResolver) readBlob(dig string) ([]byte, error) {
+	return r.readFile(filepath.Join(r.dir, filepath.FromSlash(ociBlobPath(dig))))
+}
+
+func (r *ociLayoutResolver) ListTags(ctx context.Context, reference Reference) ([]string, error) {
+	images, err := r.load()
+	if err != nil {
+		return nil, err
+	}
+
+	tags := tagsOfImages(reference, images)
+	if len(tags) == 0 {
+		return nil, NotFoundError{Reference: reference.Original()}
+	}
+	return tags, nil
+}
+
+// ociBlobPath is the slash separated path of a blob relative to the root of the layout
+func ociBlobPath(dig string) string {
+	return "blobs/" + strings.Replace(dig, ":", "/", 1)
+}
+
+// ociImagesFromIndex reads the tagged manifests of an image index
+func ociImagesFromIndex(content []byte) ([]ociImage, error) {
+	var index ociIndex
+	if err := json.Unmarshal(content, &index); err != nil {
+		return nil, err
+	}
+
+	images := make([]ociImage, 0, len(index.Manifests))
+	for _, manifest := range index.Manifests {
+		image := ociImage{digest: manifest.Digest}
+
+		refName := manifest.Annotations[ociRefNameAnnotation]
+		imageName := manifest.Annotations[containerdImageNameAnnotation]
+
+		switch {
+		case isFullReference(refName):
+			image.name, image.tag = nameAndTag(refName)
+		case imageName != "":
+			image.name, image.tag = nameAndTag(imageName)
+			if refName != "" {
+				image.tag = refName
+			}
+		default:
+			image.tag = refName
+		}
+
+		images = append(images, image)
+	}
+
+	return images, nil
+}
+
+// isFullReference distinguishes references like nginx:1.15 from plain tags like 1.15, which are also valid names
+func isFullReference(refName string) bool {
+	return strings.ContainsAny(refName, ":/@")
+}
+
+func nameAndTag(s string) (string, string) {
+	ref, err := Parse(s)
+	if err != nil || ref.Named() == nil {
+		return "", ""
+	}
+	return ref.Name(), ref.Tag()
+}
+
+// resolveFromImages finds the image referenced by tag or digest and returns all tags of that image
+func resolveFromImages(reference Reference, images []ociImage) ([]Reference, error) {
+	sameName := make([]ociImage, 0)
+	for _, image := range images {
+		if image.name == "" || reference.Named() == nil || image.name == reference.Name() {
+			sameName = append(sameName, image)
+		}
+	}
+
+	dig := reference.DigestString()
+	if dig == "" {
+		tag := reference.Tag()
+		if tag == "" {
+			tag = "latest"
+		}
+		for _, image := range sameName {
+			if image.tag == tag {
+				dig = image.digest
+				break
+			}
+		}
+	}
+
+	if dig == "" {
+		return nil, NotFoundError{Reference: reference.Original()}
+	}
+
+	refs := make([]Reference, 0)
+	found := false
+	for _, image := range sameName {
+		if image.digest != dig {
+			continue
+		}
+		found = true
+		if image.tag != "" && reference.Named() != nil {
+			refs = append(refs, reference.WithTag(image.tag).WithDigest(dig))
+		}
+	}
+
+	if !found {
+		return nil, NotFoundError{Reference: reference.Original()}
+	}
+
+	if len(refs) == 0 {
+		refs = append(refs, reference.WithDigest(dig))
+	}
+
+	return refs, nil
+}
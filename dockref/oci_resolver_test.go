@@ -0,0 +1,186 @@
+package dockref
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const (
+	ociTestDigestA = "sha256:31b8e90a349d1fce7621f5a5a08e4fc519b634f7d3feb09d53fac9b12aa4d991"
+	ociTestDigestB = "sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf"
+)
+
+const ociTestIndex = `{
+	"schemaVersion": 2,
+	"manifests": [
+		{"mediaType": "application/vnd.oci.image.manifest.v1+json", "digest": "` + ociTestDigestA + `", "size": 1,
+		 "annotations": {"org.opencontainers.image.ref.name": "docker.io/library/nginx:1.15.6"}},
+		{"mediaType": "application/vnd.oci.image.manifest.v1+json", "digest": "` + ociTestDigestA + `", "size": 1,
+		 "annotations": {"org.opencontainers.image.ref.name": "nginx:1.15"}},
+		{"mediaType": "application/vnd.oci.image.index.v1+json", "digest": "` + ociTestDigestB + `", "size": 1,
+		 "annotations": {"org.opencontainers.image.ref.name": "nginx:1.14"}},
+		{"mediaType": "application/vnd.oci.image.manifest.v1+json", "digest": "` + ociTestDigestB + `", "size": 1,
+		 "annotations": {"io.containerd.image.name": "docker.io/menedev/testimagea:1", "org.opencontainers.image.ref.name": "1"}}
+	]
+}`
+
+func tagsAndDigests(refs []Reference) []string {
+	result := make([]string, 0, len(refs))
+	for _, r := range refs {
+		result = append(result, r.Tag()+"@"+r.DigestString())
+	}
+	return result
+}
+
+func ociLayoutDir(t *testing.T, index string) string {
+	dir, err := ioutil.TempDir("", "dockmoor-oci")
+	assert.Nil(t, err)
+
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, ociLayoutFile), []byte(`{"imageLayoutVersion": "1.0.0"}`), 0644))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, ociIndexFile), []byte(index), 0644))
+	return dir
+}
+
+func TestOCILayoutResolver_Resolve(t *testing.T) {
+	dir := ociLayoutDir(t, ociTestIndex)
+	defer os.RemoveAll(dir)
+
+	resolver := OCILayoutResolverNew(dir)
+
+	refs, err := resolver.Resolve(context.Background(), MustParse("nginx:1.15"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1.15.6@" + ociTestDigestA, "1.15@" + ociTestDigestA}, tagsAndDigests(refs))
+
+	mostPrecise, err := MostPreciseTag(refs, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "1.15.6", mostPrecise.Tag())
+
+	refs, err = resolver.Resolve(context.Background(), MustParse("nginx:1.14"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1.14@" + ociTestDigestB}, tagsAndDigests(refs))
+
+	refs, err = resolver.Resolve(context.Background(), MustParse("menedev/testimagea:1"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1@" + ociTestDigestB}, tagsAndDigests(refs))
+}
+
+func TestOCILayoutResolver_ResolveByDigest(t *testing.T) {
+	dir := ociLayoutDir(t, ociTestIndex)
+	defer os.RemoveAll(dir)
+
+	resolver := OCILayoutResolverNew(dir)
+
+	refs, err := resolver.Resolve(context.Background(), MustParse("nginx@"+ociTestDigestB))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1.14@" + ociTestDigestB}, tagsAndDigests(refs))
+
+	refs, err = resolver.Resolve(context.Background(), MustParseAlgoDigest(ociTestDigestA))
+	assert.Nil(t, err)
+	assert.Equal(t, []Reference{MustParseAlgoDigest(ociTestDigestA)}, refs)
+}
+
+func TestOCILayoutResolver_TagOnlyAnnotationsMatchAnyName(t *testing.T) {
+	dir := ociLayoutDir(t, `{
+		"schemaVersion": 2,
+		"manifests": [
+			{"digest": "`+ociTestDigestA+`", "annotations": {"org.opencontainers.image.ref.name": "1.15.6"}},
+			{"digest": "`+ociTestDigestA+`", "annotations": {"org.opencontainers.image.ref.name": "latest"}}
+		]
+	}`)
+	defer os.RemoveAll(dir)
+
+	refs, err := OCILayoutResolverNew(dir).Resolve(context.Background(), MustParse("nginx"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1.15.6@" + ociTestDigestA, "latest@" + ociTestDigestA}, tagsAndDigests(refs))
+}
+
+func TestOCILayoutResolver_NotFound(t *testing.T) {
+	dir := ociLayoutDir(t, ociTestIndex)
+	defer os.RemoveAll(dir)
+
+	resolver := OCILayoutResolverNew(dir)
+
+	for _, r := range []string{"nginx:1.13", "alpine:1.15", "nginx@sha256:0000000000000000000000000000000000000000000000000000000000000000"} {
+		_, err := resolver.Resolve(context.Background(), MustParse(r))
+		assert.True(t, IsNotFound(err), r)
+	}
+}
+
+func TestOCILayoutResolver_InvalidLayout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dockmoor-oci")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	_, err = OCILayoutResolverNew(dir).Resolve(context.Background(), MustParse("nginx"))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "is not an OCI image layout")
+
+	dir = ociLayoutDir(t, "{ invalid")
+	defer os.RemoveAll(dir)
+
+	_, err = OCILayoutResolverNew(dir).Resolve(context.Background(), MustParse("nginx"))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid index.json")
+}
+
+// ociTestPlatformBlobs returns the blobs of a multi-platform image and the digests of its index and its arm64 manifest
+func ociTestPlatformBlobs() (blobs map[string]string, index string, arm64 string) {
+	amd64Manifest := `{"mediaType": "` + mediaTypeOCIManifest + `", "config": {"digest": "sha256:amd64"}}`
+	arm64Manifest := `{"mediaType": "` + mediaTypeOCIManifest + `", "config": {"digest": "sha256:arm64"}}`
+	indexContent := `{"mediaType": "` + mediaTypeOCIIndex + `", "manifests": [
+		{"digest": "` + manifestDigestOf(amd64Manifest) + `", "platform": {"os": "linux", "architecture": "amd64"}},
+		{"digest": "` + manifestDigestOf(arm64Manifest) + `", "platform": {"os": "linux", "architecture": "arm64"}}
+	]}`
+
+	blobs = make(map[string]string)
+	for _, content := range []string{amd64Manifest, arm64Manifest, indexContent} {
+		blobs[ociBlobPath(manifestDigestOf(content))] = content
+	}
+
+	return blobs, manifestDigestOf(indexContent), manifestDigestOf(arm64Manifest)
+}
+
+func ociTestPlatformIndex(index string) string {
+	return `{"schemaVersion": 2, "manifests": [
+		{"mediaType": "` + mediaTypeOCIIndex + `", "digest": "` + index + `", "annotations": {"org.opencontainers.image.ref.name": "nginx:1.15"}}
+	]}`
+}
+
+func TestOCILayoutResolver_ResolvePlatform(t *testing.T) {
+	blobs, index, arm64 := ociTestPlatformBlobs()
+
+	dir := ociLayoutDir(t, ociTestPlatformIndex(index))
+	defer os.RemoveAll(dir)
+	for name, content := range blobs {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.Nil(t, ioutil.WriteFile(path, []byte(content), 0644))
+	}
+
+	resolver := OCILayoutResolverNew(dir).(PlatformResolver)
+
+	refs, err := resolver.ResolvePlatform(context.Background(), MustParse("nginx:1.15"), MustParsePlatform("linux/arm64"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1.15@" + arm64}, tagsAndDigests(refs))
+
+	_, err = resolver.ResolvePlatform(context.Background(), MustParse("nginx:1.15"), MustParsePlatform("windows/amd64"))
+	assert.Error(t, err)
+}
+
+func TestOCILayoutResolver_ListTags(t *testing.T) {
+	dir := ociLayoutDir(t, ociTestIndex)
+	defer os.RemoveAll(dir)
+
+	resolver := OCILayoutResolverNew(dir)
+
+	tags, err := ListTags(context.Background(), resolver, MustParse("nginx:1.15"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1.15.6", "1.15", "1.14"}, tags)
+
+	_, err = ListTags(context.Background(), resolver, MustParse("alpine:3.8"))
+	assert.True(t, IsNotFound(err))
+}
@@ -0,0 +1,208 @@
+package dockref
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"strings"
+)
+
+// Platform of an image as used in manifest lists and OCI image indexes, e.g. linux/arm64
+type Platform struct {
+	OS           string `json:"os"`
+	Architecture string `json:"architecture"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+// PlatformResolver is implemented by Resolvers that can resolve the manifest of a single platform
+// instead of the manifest list or image index a tag refers to
+type PlatformResolver interface {
+	ResolvePlatform(ctx context.Context, reference Reference, platform Platform) ([]Reference, error)
+}
+
+// default variants as normalized by containerd, an image for linux/arm64 may declare the variant v8 or none at all
+var defaultVariants = map[string]string{
+	"arm":   "v7",
+	"arm64": "v8",
+}
+
+// architectures known by containerd, a platform without os like arm64 or arm64/v8 starts with one of them
+var knownArchitectures = map[string]bool{
+	"386": true, "amd64": true, "amd64p32": true, "arm": true, "armbe": true, "arm64": true, "arm64be": true,
+	"loong64": true, "mips": true, "mipsle": true, "mips64": true, "mips64le": true, "mips64p32": true, "mips64p32le": true,
+	"ppc": true, "ppc64": true, "ppc64le": true, "riscv": true, "riscv64": true, "s390": true, "s390x": true,
+	"sparc": true, "sparc64": true, "wasm": true,
+}
+
+// aliases of architectures as normalized by containerd
+var architectureAliases = map[string]string{
+	"i386":    "386",
+	"x86_64":  "amd64",
+	"x86-64":  "amd64",
+	"aarch64": "arm64",
+}
+
+// ParsePlatform parses platforms in the format [os/]architecture[/variant], e.g. linux/arm/v7.
+// Like containerd, a known architecture without os like arm64 or arm64/v8 is a linux platform.
+func ParsePlatform(s string) (Platform, error) {
+	parts := strings.Split(strings.ToLower(s), "/")
+	for _, part := range parts {
+		if part == "" {
+			return Platform{}, errors.Errorf("invalid platform '%s', expected [os/]architecture[/variant]", s)
+		}
+	}
+
+	if arch := normalizeArchitecture(parts[0]); knownArchitectures[arch] && len(parts) <= 2 {
+		parts = append([]string{"linux"}, parts...)
+	}
+	if len(parts) < 2 || len(parts) > 3 {
+		return Platform{}, errors.Errorf("invalid platform '%s', expected [os/]architecture[/variant]", s)
+	}
+
+	platform := Platform{
+		OS:           parts[0],
+		Architecture: normalizeArchitecture(parts[1]),
+	}
+	if len(parts) == 3 {
+		platform.Variant = parts[2]
+	}
+
+	return platform, nil
+}
+
+func normalizeArchitecture(arch string) string {
+	if alias, ok := architectureAliases[arch]; ok {
+		return alias
+	}
+	return arch
+}
+
+func MustParsePlatform(s string) Platform {
+	platform, e := ParsePlatform(s)
+	deliberatelyUnsued(e)
+
+	return platform
+}
+
+// IsZero reports whether no platform was chosen, i.e. the manifest list or image index is used
+func (p Platform) IsZero() bool {
+	return p == Platform{}
+}
+
+func (p Platform) String() string {
+	if p.IsZero() {
+		return ""
+	}
+
+	s := p.OS + "/" + p.Architecture
+	if p.Variant != "" {
+		s += "/" + p.Variant
+	}
+	return s
+}
+
+func (p Platform) variant() string {
+	if p.Variant != "" {
+		return p.Variant
+	}
+	return defaultVariants[p.Architecture]
+}
+
+// Matches reports whether an image for the other platform runs on p
+func (p Platform) Matches(other Platform) bool {
+	return p.OS == other.OS && p.Architecture == other.Architecture && p.variant() == other.variant()
+}
+
+// CanResolvePlatform reports whether the resolver supports platforms, decorators like the cache do when the decorated resolver does
+func CanResolvePlatform(resolver Resolver) bool {
+	_, ok := undecorated(resolver).(PlatformResolver)
+	return ok
+}
+
+// ResolveForPlatform resolves the manifest of the platform, the zero platform resolves the manifest list or image index
+func ResolveForPlatform(ctx context.Context, resolver Resolver, reference Reference, platform Platform) ([]Reference, error) {
+	if platform.IsZero() {
+		return resolver.Resolve(ctx, reference)
+	}
+
+	platformResolver, ok := resolver.(PlatformResolver)
+	if !ok || !CanResolvePlatform(resolver) {
+		return nil, errors.Errorf("cannot resolve %s for platform %s, the resolver does not support platforms", reference.Original(), platform)
+	}
+
+	return platformResolver.ResolvePlatform(ctx, reference, platform)
+}
+
+type platformDescriptor struct {
+	MediaType string    `json:"mediaType"`
+	Digest    string    `json:"digest"`
+	Platform  *Platform `json:"platform,omitempty"`
+}
+
+// manifestOrIndex holds the fields of image manifests and indexes needed to find the platform of an image
+type manifestOrIndex struct {
+	MediaType string               `json:"mediaType"`
+	Manifests []platformDescriptor `json:"manifests"`
+	Config    *platformDescriptor  `json:"config"`
+}
+
+// platformDigest returns the digest of the manifest for the platform.
+// Indexes are searched for a matching manifest, manifests of a single image are checked against the platform of their config.
+func platformDigest(reference Reference, dig string, platform Platform, fetchManifest func(dig string) ([]byte, error), fetchBlob func(dig string) ([]byte, error)) (string, error) {
+	content, err := fetchManifest(dig)
+	if err != nil {
+		return "", err
+	}
+
+	var manifest manifestOrIndex
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		return "", errors.Wrapf(err, "invalid manifest %s", dig)
+	}
+
+	if manifest.Manifests != nil {
+		for _, descriptor := range manifest.Manifests {
+			if descriptor.Platform != nil && platform.Matches(*descriptor.Platform) {
+				return descriptor.Digest, nil
+			}
+		}
+		return "", errors.Errorf("%s is not available for platform %s", reference.Original(), platform)
+	}
+
+	if manifest.Config == nil {
+		return "", errors.Errorf("manifest %s of %s neither lists manifests nor references a config", dig, reference.Original())
+	}
+
+	content, err = fetchBlob(manifest.Config.Digest)
+	if err != nil {
+		return "", err
+	}
+
+	var config Platform
+	if err := json.Unmarshal(content, &config); err != nil {
+		return "", errors.Wrapf(err, "invalid config %s", manifest.Config.Digest)
+	}
+
+	if !platform.Matches(config) {
+		return "", errors.Errorf("%s is only available for platform %s, not %s", reference.Original(), config, platform)
+	}
+
+	return dig, nil
+}
+
+// withPlatformDigest replaces the digests of the resolved references with the digest of the platform's manifest
+func withPlatformDigest(reference Reference, refs []Reference, platform Platform, fetchManifest func(dig string) ([]byte, error), fetchBlob func(dig string) ([]byte, error)) ([]Reference, error) {
+	if len(refs) == 0 || refs[0].DigestString() == "" {
+		return nil, errors.Errorf("cannot resolve %s for platform %s without digest", reference.Original(), platform)
+	}
+
+	dig, err := platformDigest(reference, refs[0].DigestString(), platform, fetchManifest, fetchBlob)
+	if err != nil {
+		return nil, err
+	}
+
+	platformRefs := make([]Reference, 0, len(refs))
+	for _, r := range refs {
+		platformRefs = append(platformRefs, r.WithDigest(dig))
+	}
+	return platformRefs, nil
+}
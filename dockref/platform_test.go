@@ -0,0 +1,59 @@
+package dockref
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParsePlatform(t *testing.T) {
+	for s, expected := range map[string]Platform{
+		"linux/amd64":    {OS: "linux", Architecture: "amd64"},
+		"linux/arm64/v8": {OS: "linux", Architecture: "arm64", Variant: "v8"},
+		"Linux/ARM/v7":   {OS: "linux", Architecture: "arm", Variant: "v7"},
+		"arm64":          {OS: "linux", Architecture: "arm64"},
+		"arm64/v8":       {OS: "linux", Architecture: "arm64", Variant: "v8"},
+		"amd64":          {OS: "linux", Architecture: "amd64"},
+		"x86_64":         {OS: "linux", Architecture: "amd64"},
+		"linux/aarch64":  {OS: "linux", Architecture: "arm64"},
+		"windows/amd64":  {OS: "windows", Architecture: "amd64"},
+	} {
+		platform, err := ParsePlatform(s)
+		assert.Nil(t, err, s)
+		assert.Equal(t, expected, platform, s)
+	}
+
+	for _, s := range []string{"", "linux", "linux/", "/amd64", "linux/arm/v7/extra", "amd65"} {
+		_, err := ParsePlatform(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestPlatform_String(t *testing.T) {
+	assert.Equal(t, "linux/arm64/v8", MustParsePlatform("linux/arm64/v8").String())
+	assert.Equal(t, "linux/amd64", MustParsePlatform("linux/amd64").String())
+	assert.Equal(t, "", Platform{}.String())
+}
+
+func TestPlatform_MatchesDefaultVariants(t *testing.T) {
+	assert.True(t, MustParsePlatform("linux/arm64").Matches(MustParsePlatform("linux/arm64/v8")))
+	assert.True(t, MustParsePlatform("linux/arm64/v8").Matches(MustParsePlatform("linux/arm64")))
+	assert.True(t, MustParsePlatform("linux/arm").Matches(MustParsePlatform("linux/arm/v7")))
+	assert.False(t, MustParsePlatform("linux/arm").Matches(MustParsePlatform("linux/arm/v6")))
+	assert.False(t, MustParsePlatform("linux/amd64").Matches(MustParsePlatform("windows/amd64")))
+}
+
+func TestResolveForPlatform_RequiresPlatformResolver(t *testing.T) {
+	resolver := funcResolver(func(reference Reference) ([]Reference, error) {
+		return []Reference{reference}, nil
+	})
+
+	refs, err := ResolveForPlatform(context.Background(), resolver, MustParse("nginx"), Platform{})
+	assert.Nil(t, err)
+	assert.Len(t, refs, 1)
+
+	_, err = ResolveForPlatform(context.Background(), resolver, MustParse("nginx"), MustParsePlatform("linux/arm64"))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "for platform linux/arm64")
+	}
+}
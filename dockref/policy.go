@@ -0,0 +1,123 @@
+package dockref
+
+import (
+	"github.com/blang/semver"
+	"github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// UpdatePolicy restricts the tags an update may choose and that make a reference outdated
+type UpdatePolicy struct {
+	// Allow lists semver ranges, e.g. ">=13.0.0 <14.0.0", or regular expressions of tags surrounded by '/'.
+	// When not empty, versions must match one of them.
+	Allow []string
+	// Ignore lists versions, ranges or regular expressions of tags that are never chosen
+	Ignore []string
+	// Prerelease allows tags like 1.16.0-rc1 or 2.0-beta.2
+	Prerelease bool
+	// MinAge is the time that must have passed since the image of a tag was created before the tag is chosen
+	MinAge time.Duration
+	// VariantLevel limits the newer versions of versioned variants, e.g. alpine3.18, nil means UpdateMinor
+	VariantLevel *UpdateLevel
+}
+
+// variantLevel returns the VariantLevel or its default UpdateMinor
+func (policy UpdatePolicy) variantLevel() UpdateLevel {
+	if policy.VariantLevel == nil {
+		return UpdateMinor
+	}
+	return *policy.VariantLevel
+}
+
+// Validate reports invalid ranges and regular expressions
+func (policy UpdatePolicy) Validate() error {
+	var result *multierror.Error
+	for _, pattern := range append(append([]string{}, policy.Allow...), policy.Ignore...) {
+		_, err := versionMatcherOf(pattern)
+		result = multierror.Append(result, err)
+	}
+	if policy.MinAge < 0 {
+		result = multierror.Append(result, errors.Errorf("negative minimum age %s", policy.MinAge))
+	}
+	return result.ErrorOrNil()
+}
+
+// compile returns a function that reports whether a version is allowed, invalid patterns match nothing
+func (policy UpdatePolicy) compile() func(candidate taggedVersion) bool {
+	allow := versionMatchersOf(policy.Allow)
+	ignore := versionMatchersOf(policy.Ignore)
+
+	return func(candidate taggedVersion) bool {
+		if candidate.prerelease && !policy.Prerelease {
+			return false
+		}
+		if len(policy.Allow) > 0 && !anyVersionMatcher(allow, candidate) {
+			return false
+		}
+		return !anyVersionMatcher(ignore, candidate)
+	}
+}
+
+type versionMatcher func(candidate taggedVersion) bool
+
+func versionMatcherOf(pattern string) (versionMatcher, error) {
+	if len(pattern) > 1 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		exp, err := regexp.Compile(pattern[1 : len(pattern)-1])
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid regular expression %s", pattern)
+		}
+		return func(candidate taggedVersion) bool {
+			return exp.MatchString(candidate.tag)
+		}, nil
+	}
+
+	versionRange, err := semver.ParseRange(pattern)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid version range '%s'", pattern)
+	}
+	return func(candidate taggedVersion) bool {
+		return versionRange(candidate.version)
+	}, nil
+}
+
+func versionMatchersOf(patterns []string) []versionMatcher {
+	matchers := make([]versionMatcher, 0, len(patterns))
+	for _, pattern := range patterns {
+		if matcher, err := versionMatcherOf(pattern); err == nil {
+			matchers = append(matchers, matcher)
+		}
+	}
+	return matchers
+}
+
+func anyVersionMatcher(matchers []versionMatcher, candidate taggedVersion) bool {
+	for _, matches := range matchers {
+		if matches(candidate) {
+			return true
+		}
+	}
+	return false
+}
+
+// UpdatePolicyRule applies a policy to the references its Matcher selects
+type UpdatePolicyRule struct {
+	// Matcher selects the references of this rule, nil matches all references
+	Matcher ReferenceMatcher
+	Policy  UpdatePolicy
+}
+
+// UpdatePolicies use the policy of the first matching rule, references without matching rule have no restrictions
+type UpdatePolicies []UpdatePolicyRule
+
+// For returns the policy of the reference
+func (policies UpdatePolicies) For(reference Reference) UpdatePolicy {
+	for _, rule := range policies {
+		if rule.Matcher == nil || rule.Matcher.Matches(reference) {
+			return rule.Policy
+		}
+	}
+	return UpdatePolicy{}
+}
@@ -0,0 +1,57 @@
+package dockref
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestUpdatePolicy_RestrictsVersions(t *testing.T) {
+	postgres := []string{"12.4", "13.1", "13.2", "13.3", "14.0", "14.1-rc1"}
+	node := []string{"14.17", "15.14", "16.3", "17.0", "18.2", "19.1"}
+
+	for name, c := range map[string]struct {
+		reference string
+		tags      []string
+		policy    UpdatePolicy
+		expected  []string
+	}{
+		"range":              {"postgres:13.1", postgres, UpdatePolicy{Allow: []string{">=13.0.0 <14.0.0"}}, []string{"13.3", "13.2"}},
+		"ignored version":    {"postgres:13.1", postgres, UpdatePolicy{Allow: []string{">=13.0.0 <14.0.0"}, Ignore: []string{"13.3.0"}}, []string{"13.2"}},
+		"ignored range":      {"postgres:13.1", postgres, UpdatePolicy{Ignore: []string{">=14.0.0"}}, []string{"13.3", "13.2"}},
+		"even majors":        {"node:14.17", node, UpdatePolicy{Allow: []string{`/^[0-9]*[02468]\./`}}, []string{"18.2", "16.3"}},
+		"prerelease opt-in":  {"postgres:14.0", postgres, UpdatePolicy{Prerelease: true}, []string{"14.1-rc1"}},
+		"prereleases hidden": {"postgres:14.0", postgres, UpdatePolicy{}, []string{}},
+	} {
+		assert.Equal(t, c.expected, UpdateCandidates(MustParse(c.reference), c.tags, UpdateMajor, c.policy), name)
+	}
+}
+
+func TestUpdatePolicy_Validate(t *testing.T) {
+	assert.Nil(t, UpdatePolicy{Allow: []string{">=13.0.0 <14.0.0", "/^1[0-9]\\./"}, Ignore: []string{"13.3.0"}, MinAge: time.Hour}.Validate())
+
+	for name, policy := range map[string]UpdatePolicy{
+		"invalid range":   {Allow: []string{">=13"}},
+		"invalid regex":   {Ignore: []string{"/a(b/"}},
+		"negative minage": {MinAge: -time.Hour},
+	} {
+		assert.Error(t, policy.Validate(), name)
+	}
+}
+
+type tagMatcher string
+
+func (m tagMatcher) Matches(reference Reference) bool {
+	return reference.Tag() == string(m)
+}
+
+func TestUpdatePolicies_For(t *testing.T) {
+	policies := UpdatePolicies{
+		{Matcher: tagMatcher("13"), Policy: UpdatePolicy{Prerelease: true}},
+		{Matcher: nil, Policy: UpdatePolicy{MinAge: time.Hour}},
+	}
+
+	assert.Equal(t, UpdatePolicy{Prerelease: true}, policies.For(MustParse("postgres:13")))
+	assert.Equal(t, UpdatePolicy{MinAge: time.Hour}, policies.For(MustParse("postgres:14")))
+	assert.Equal(t, UpdatePolicy{}, UpdatePolicies(nil).For(MustParse("postgres:14")))
+}
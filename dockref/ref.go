@@ -273,7 +273,13 @@ func (r dockref) WithTag(tag string) Reference {
 	return cpy
 }
 
+// FindRelevantTagsForReference uses the DefaultTagScheme, see TagScheme.FindRelevantTagsForReference
 func FindRelevantTagsForReference(ref Reference, refs []Reference, log *logrus.Logger) ([]Reference, error) {
+	return DefaultTagScheme.FindRelevantTagsForReference(ref, refs, log)
+}
+
+// FindRelevantTagsForReference returns the references with the name and the variant of ref
+func (scheme TagScheme) FindRelevantTagsForReference(ref Reference, refs []Reference, log *logrus.Logger) ([]Reference, error) {
 	// name and domain must match
 	sameName := make([]Reference, 0)
 	for _, r := range refs {
@@ -282,10 +288,10 @@ func FindRelevantTagsForReference(ref Reference, refs []Reference, log *logrus.L
 		}
 	}
 
-	_, refVariant := splitVersionAndVariant(ref.Tag())
+	_, refVariant := scheme.split(ref.Tag())
 	sameVariant := make([]Reference, 0)
 	for _, r := range sameName {
-		_, rVariant := splitVersionAndVariant(r.Tag())
+		_, rVariant := scheme.split(r.Tag())
 
 		if refVariant == rVariant {
 			sameVariant = append(sameVariant, r)
@@ -295,7 +301,13 @@ func FindRelevantTagsForReference(ref Reference, refs []Reference, log *logrus.L
 	return sameVariant, nil
 }
 
+// MostPreciseTag uses the DefaultTagScheme, see TagScheme.MostPreciseTag
 func MostPreciseTag(refs []Reference, log *logrus.Logger) (Reference, error) {
+	return DefaultTagScheme.MostPreciseTag(refs, log)
+}
+
+// MostPreciseTag chooses the reference with the highest version, falling back to the longest tag
+func (scheme TagScheme) MostPreciseTag(refs []Reference, log *logrus.Logger) (Reference, error) {
 	if refs == nil {
 		return nil, errors.New("refs is nil")
 	}
@@ -318,7 +330,7 @@ func MostPreciseTag(refs []Reference, log *logrus.Logger) (Reference, error) {
 		return refs[0], nil
 	}
 
-	nonSemVer, best := bestSemVer(refs)
+	nonSemVer, best := scheme.bestSemVer(refs)
 
 	if best != nil {
 		return best, nil
@@ -395,32 +407,53 @@ func removeEmpty(refs []Reference) []Reference {
 	return nonEmpty
 }
 
-func bestSemVer(refs []Reference) ([]Reference, Reference) {
-	var best Reference
-	var bestSemver semver.Version
+func (scheme TagScheme) bestSemVer(refs []Reference) ([]Reference, Reference) {
+	var best, bestDated Reference
+	var bestSemver, bestDate semver.Version
 	nonSemVer := make([]Reference, 0)
-	// look for semver first, semver wins
+	// look for semver first, semver wins, date stamps like bookworm-20231009 only win over tags without version
 	for _, r := range refs {
 		tag := r.Tag()
-		version, e := parseVeryTolerant(tag)
+		version, e := scheme.parseVeryTolerant(tag)
 		if e != nil {
 			nonSemVer = append(nonSemVer, r)
 			continue
 		}
-		if version.Compare(bestSemver) >= 0 {
+		if scheme.isDateStamped(tag) {
+			if compareVersions(version, bestDate) >= 0 {
+				bestDate = version
+				bestDated = r
+			}
+			continue
+		}
+		if compareVersions(version, bestSemver) >= 0 {
 			bestSemver = version
 			best = r
 		}
 	}
+	if best == nil {
+		best = bestDated
+	}
 	return nonSemVer, best
 }
 
 func splitVersionAndVariant(tag string) (version string, variant string) {
+	version, variant = splitSemVerAndVariant(tag)
+	if version == "" {
+		// date stamped tags like bookworm-20231009
+		if date, rest, ok := splitDateStamp(tag); ok {
+			return date, rest
+		}
+	}
+	return
+}
+
+func splitSemVerAndVariant(tag string) (version string, variant string) {
 	lastIndex := strings.Index(tag, "-")
 	if lastIndex >= 0 {
 		version = tag[0:lastIndex]
 		variant = tag[lastIndex+1:]
-		_, e := semver.ParseTolerant(version)
+		_, e := parseVersion(version)
 		if e != nil {
 			version = ""
 			variant = tag
@@ -428,7 +461,7 @@ func splitVersionAndVariant(tag string) (version string, variant string) {
 		}
 	} else {
 		// no variant
-		_, e := semver.ParseTolerant(tag)
+		_, e := parseVersion(tag)
 		if e != nil {
 			version = ""
 			variant = tag
@@ -442,21 +475,6 @@ func splitVersionAndVariant(tag string) (version string, variant string) {
 	return
 }
 
-func parseVeryTolerant(tag string) (semver.Version, error) {
-	version, variant := splitVersionAndVariant(tag)
-
-	parsed, e := semver.ParseTolerant(version)
-	if e != nil {
-		return parsed, e
-	}
-	if variant != "" {
-		str := parsed.String()
-		version = str + "-" + variant
-	}
-
-	return semver.ParseTolerant(version)
-}
-
 func deliberatelyUnsued(err error) {
 	// noop
 }
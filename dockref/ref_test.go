@@ -486,6 +486,9 @@ func TestMostPreciseTag(t *testing.T) {
 		{list: []string{"nginx:1.1-beta", "nginx:1.1-alpha"}, expected: "nginx:1.1-beta"},
 		{list: []string{"nginx:latest", "nginx:1.1-alpha"}, expected: "nginx:1.1-alpha"},
 		{list: []string{"img:20181120", "img:20181121", "img:20181119"}, expected: "img:20181121"},
+		{list: []string{"debian:bookworm", "debian:bookworm-20231009"}, expected: "debian:bookworm-20231009"},
+		{list: []string{"debian:bookworm-20231009", "debian:12", "debian:12.2", "debian:bookworm"}, expected: "debian:12.2"},
+		{list: []string{"ubuntu:22.04", "ubuntu:jammy", "ubuntu:jammy-20231004"}, expected: "ubuntu:22.04"},
 		{list: []string{"img:a", "img:aaa", "img:bb"}, expected: "img:aaa"},
 		{list: []string{"img:aaa", "img:aab"}, expected: "img:aab"},
 	}
@@ -706,4 +709,16 @@ func TestSplitVersionAndVariant(t *testing.T) {
 		assert.Equal(t, expVersion, version)
 		assert.Equal(t, expVar, variant)
 	})
+	for tag, expected := range map[string][]string{
+		"22.04":                {"22.04", ""},
+		"2023.10.09-alpine":    {"2023.10.09", "alpine"},
+		"bookworm-20231009":    {"20231009", "bookworm"},
+		"20231009":             {"20231009", ""},
+		"jammy-20231004-slim":  {"20231004", "jammy-slim"},
+		"bookworm-20231399":    {"", "bookworm-20231399"},
+		"1.21-alpine-20231009": {"1.21", "alpine-20231009"},
+	} {
+		version, variant := splitVersionAndVariant(tag)
+		assert.Equal(t, expected, []string{version, variant}, tag)
+	}
 }
@@ -0,0 +1,771 @@
+package dockref
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	mediaTypeManifestList   = "application/vnd.docker.distribution.manifest.list.v2+json"
+	mediaTypeManifestV2     = "application/vnd.docker.distribution.manifest.v2+json"
+	mediaTypeOCIIndex       = "application/vnd.oci.image.index.v1+json"
+	mediaTypeOCIManifest    = "application/vnd.oci.image.manifest.v1+json"
+	headerDockerDigest      = "Docker-Content-Digest"
+	dockerHubDomain         = "docker.io"
+	dockerHubRegistryDomain = "registry-1.docker.io"
+
+	// upper bound of manifest requests used to find a more precise tag for the same image
+	maxPreciseTagCandidates = 32
+)
+
+var manifestMediaTypes = []string{
+	mediaTypeManifestList,
+	mediaTypeOCIIndex,
+	mediaTypeManifestV2,
+	mediaTypeOCIManifest,
+}
+
+// NotFoundError is returned by resolvers when the reference does not exist
+type NotFoundError struct {
+	Reference string
+}
+
+func (e NotFoundError) Error() string {
+	return fmt.Sprintf("not found: %s", e.Reference)
+}
+
+// NotFound marks the error as not found, compatible with the errors of the docker client
+func (e NotFoundError) NotFound() bool {
+	return true
+}
+
+type notFound interface {
+	NotFound() bool
+}
+
+// errdefsNotFound is how newer docker errdefs mark not found errors
+type errdefsNotFound interface {
+	NotFound()
+}
+
+// IsNotFound reports whether err signals that a reference could not be found
+func IsNotFound(err error) bool {
+	switch nf := errors.Cause(err).(type) {
+	case notFound:
+		return nf.NotFound()
+	case errdefsNotFound:
+		return true
+	}
+	return false
+}
+
+type registryResolver struct {
+	client      *http.Client
+	credentials CredentialStore
+
+	authorizationsMutex sync.Mutex
+	authorizations      map[string]string
+
+	// mirrors by the domain they mirror
+	mirrors map[string]registryMirror
+	options RegistryOptions
+
+	// clients by registry domain, client is used for registries without configuration
+	clientsMutex sync.Mutex
+	clients      map[string]*http.Client
+
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+var _ Resolver = (*registryResolver)(nil)
+var _ PlatformResolver = (*registryResolver)(nil)
+var _ TagLister = (*registryResolver)(nil)
+var _ CreationTimeResolver = (*registryResolver)(nil)
+
+// RegistryOptions control how the registry resolver reaches the registries
+type RegistryOptions struct {
+	// Mirrors maps domains like docker.io to the endpoint of a mirror, e.g. mirror.corp:5000.
+	// Endpoints may use http:// and contain a path prefix, e.g. https://harbor.corp/dockerhub-proxy
+	Mirrors map[string]string
+	// RequestTimeout limits each request including reading the response, zero means no limit
+	RequestTimeout time.Duration
+	// Retries is the number of times a request is repeated after network errors, rate limits or 502, 503 and 504
+	Retries int
+	// Backoff is the delay before the first retry, it doubles with every further retry
+	Backoff time.Duration
+	// MaxRetryWait is the longest delay before a retry, e.g. longer Retry-After delays fail with a RateLimitError
+	MaxRetryWait time.Duration
+	// Registries configure CA certificates, client certificates, proxies and insecure access by registry domain
+	Registries map[string]RegistryConfig
+	// CertsDir is searched for certificates of registries like the certs.d directory of the docker daemon
+	CertsDir string
+	// TagSchemes split the tags of repositories when looking for a more precise tag
+	TagSchemes TagSchemes
+	// NoPreciseTags skips the search for a more precise tag of the same image, e.g. when the tag is kept anyway
+	NoPreciseTags bool
+	// Log receives debug messages like failed searches for a more precise tag, nil discards them
+	Log *logrus.Logger
+}
+
+// DefaultRegistryOptions are used by RegistryResolverNew
+func DefaultRegistryOptions() RegistryOptions {
+	return RegistryOptions{
+		RequestTimeout: 30 * time.Second,
+		Retries:        3,
+		Backoff:        time.Second,
+		MaxRetryWait:   time.Minute,
+		CertsDir:       "/etc/docker/certs.d",
+	}
+}
+
+// registryMirror is the parsed endpoint of a mirror
+type registryMirror struct {
+	scheme string
+	domain string
+	prefix string
+}
+
+// RegistryResolverNew creates a Resolver that queries the Docker Registry HTTP API v2
+func RegistryResolverNew() Resolver {
+	return registryResolverNew(DefaultRegistryOptions())
+}
+
+// RegistryResolverWithOptionsNew creates a Resolver that queries the Docker Registry HTTP API v2.
+// Resolved references keep the domain of the original reference, even when resolved by a mirror.
+func RegistryResolverWithOptionsNew(options RegistryOptions) (Resolver, error) {
+	resolver := registryResolverNew(options)
+
+	for domain, endpoint := range options.Mirrors {
+		mirror, err := parseMirror(endpoint)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid mirror for %s", domain)
+		}
+		resolver.mirrors[normalizeRegistryDomain(domain)] = mirror
+	}
+
+	resolver.options.Registries = make(map[string]RegistryConfig, len(options.Registries))
+	for domain, config := range options.Registries {
+		resolver.options.Registries[normalizeRegistryDomain(domain)] = config
+	}
+	// report invalid certificates and proxies right away instead of on first use
+	for domain := range resolver.options.Registries {
+		if _, err := resolver.clientFor(domain); err != nil {
+			return nil, err
+		}
+	}
+
+	return resolver, nil
+}
+
+func registryResolverNew(options RegistryOptions) *registryResolver {
+	return &registryResolver{
+		client:         &http.Client{Timeout: options.RequestTimeout},
+		credentials:    DockerConfigCredentialStoreNew(),
+		authorizations: make(map[string]string),
+		mirrors:        make(map[string]registryMirror),
+		options:        options,
+		clients:        make(map[string]*http.Client),
+		now:            time.Now,
+		sleep:          sleepContext,
+	}
+}
+
+func parseMirror(endpoint string) (registryMirror, error) {
+	mirror := registryMirror{scheme: "https"}
+
+	rest := endpoint
+	if i := strings.Index(rest, "://"); i >= 0 {
+		mirror.scheme = rest[:i]
+		rest = rest[i+len("://"):]
+	}
+	if mirror.scheme != "https" && mirror.scheme != "http" {
+		return mirror, errors.Errorf("unsupported scheme in '%s', use https or http", endpoint)
+	}
+
+	rest = strings.Trim(rest, "/")
+	if i := strings.Index(rest, "/"); i >= 0 {
+		mirror.domain, mirror.prefix = rest[:i], rest[i+1:]
+	} else {
+		mirror.domain = rest
+	}
+
+	if mirror.domain == "" {
+		return mirror, errors.Errorf("missing domain in '%s'", endpoint)
+	}
+
+	return mirror, nil
+}
+
+// normalizeRegistryDomain maps the aliases of Docker Hub to docker.io
+func normalizeRegistryDomain(domain string) string {
+	switch domain {
+	case "index.docker.io", dockerHubRegistryDomain:
+		return dockerHubDomain
+	}
+	return domain
+}
+
+func (repo *registryResolver) Resolve(ctx context.Context, reference Reference) ([]Reference, error) {
+	named := reference.Named()
+	if named == nil {
+		return nil, errors.Errorf("cannot resolve %s without repository name", reference.Original())
+	}
+
+	repository := repo.repositoryOf(reference)
+
+	tag := reference.Tag()
+	manifestRef := tag
+	if reference.DigestString() != "" {
+		manifestRef = reference.DigestString()
+	} else if tag == "" {
+		tag = "latest"
+		manifestRef = tag
+	}
+
+	dig, err := repo.manifestDigest(ctx, repository, manifestRef)
+	if err != nil {
+		return nil, err
+	}
+
+	resolved := reference.WithTag(tag).WithDigest(dig)
+	refs := []Reference{resolved}
+
+	// a digest already identifies the image, other tags are only wanted when the tag may change
+	if reference.DigestString() != "" || repo.options.NoPreciseTags {
+		return refs, nil
+	}
+
+	// the manifest is resolved, a more precise tag is only a bonus
+	precise, err := repo.morePreciseTag(ctx, repository, resolved)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		repo.debugf("Cannot find a more precise tag for %s: %s", reference.Original(), err.Error())
+	}
+	if precise != nil {
+		refs = append(refs, precise)
+	}
+
+	return refs, nil
+}
+
+func (repo *registryResolver) debugf(format string, args ...interface{}) {
+	if repo.options.Log != nil {
+		repo.options.Log.Debugf(format, args...)
+	}
+}
+
+// ResolvePlatform resolves the manifest list like Resolve, but returns the digest of the platform's manifest
+func (repo *registryResolver) ResolvePlatform(ctx context.Context, reference Reference, platform Platform) ([]Reference, error) {
+	refs, err := repo.Resolve(ctx, reference)
+	if err != nil {
+		return nil, err
+	}
+
+	fetchManifest, fetchBlob := repo.fetchers(ctx, repo.repositoryOf(reference))
+	return withPlatformDigest(reference, refs, platform, fetchManifest, fetchBlob)
+}
+
+// CreationTime reads the creation time from the config of the image the reference points to
+func (repo *registryResolver) CreationTime(ctx context.Context, reference Reference) (time.Time, error) {
+	if reference.Named() == nil {
+		return time.Time{}, errors.Errorf("cannot read the creation time of %s without repository name", reference.Original())
+	}
+
+	manifestRef := reference.Tag()
+	if reference.DigestString() != "" {
+		manifestRef = reference.DigestString()
+	} else if manifestRef == "" {
+		manifestRef = "latest"
+	}
+
+	fetchManifest, fetchBlob := repo.fetchers(ctx, repo.repositoryOf(reference))
+	return imageCreationTime(reference, manifestRef, fetchManifest, fetchBlob)
+}
+
+// fetchers return the content of manifests and blobs of the repository by digest or, for manifests, by tag
+func (repo *registryResolver) fetchers(ctx context.Context, repository registryRepository) (fetchManifest func(ref string) ([]byte, error), fetchBlob func(dig string) ([]byte, error)) {
+	fetchManifest = func(ref string) ([]byte, error) {
+		return repo.fetch(ctx, repository, repository.url("/manifests/%s", ref), manifestMediaTypes, repository.path+manifestSeparator(ref)+ref)
+	}
+	fetchBlob = func(dig string) ([]byte, error) {
+		return repo.fetch(ctx, repository, repository.url("/blobs/%s", dig), nil, repository.path+"@"+dig)
+	}
+	return fetchManifest, fetchBlob
+}
+
+func manifestSeparator(ref string) string {
+	if strings.Contains(ref, ":") {
+		return "@"
+	}
+	return ":"
+}
+
+// morePreciseTag looks for a tag with a more precise version of the same variant that refers to the same image
+func (repo *registryResolver) morePreciseTag(ctx context.Context, repository registryRepository, resolved Reference) (Reference, error) {
+	tags, err := repo.listTags(ctx, repository)
+	if err != nil {
+		return nil, err
+	}
+
+	scheme := repo.options.TagSchemes.For(resolved)
+	version, _ := scheme.split(resolved.Tag())
+
+	tagRefs := make([]Reference, 0)
+	for _, t := range tags {
+		if t == resolved.Tag() {
+			continue
+		}
+		tagVersion, _ := scheme.split(t)
+		if tagVersion == "" {
+			continue
+		}
+		if version != "" && !strings.HasPrefix(tagVersion, version+".") && !strings.HasPrefix(tagVersion, version+"+") {
+			continue
+		}
+		tagRefs = append(tagRefs, resolved.WithTag(t))
+	}
+
+	variantRef := resolved
+	if resolved.Tag() == "latest" {
+		// latest refers to the default variant
+		variantRef = resolved.WithTag("")
+	}
+
+	candidates, err := scheme.FindRelevantTagsForReference(variantRef, tagRefs, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, _ := scheme.parseVeryTolerant(candidates[i].Tag())
+		b, _ := scheme.parseVeryTolerant(candidates[j].Tag())
+		return compareVersions(a, b) > 0
+	})
+
+	if len(candidates) > maxPreciseTagCandidates {
+		candidates = candidates[:maxPreciseTagCandidates]
+	}
+
+	for _, candidate := range candidates {
+		dig, err := repo.manifestDigest(ctx, repository, candidate.Tag())
+		if IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if dig == resolved.DigestString() {
+			return candidate, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// registryRepository is the repository on the registry that is queried, i.e. on the mirror if there is one
+type registryRepository struct {
+	domain string
+	path   string
+	// plainHTTP is used for mirrors configured with http:// and registries configured for plain HTTP
+	plainHTTP bool
+}
+
+func repositoryOf(reference Reference) registryRepository {
+	return registryRepository{
+		domain: reference.Domain(),
+		path:   reference.Path(),
+	}
+}
+
+func (repo *registryResolver) repositoryOf(reference Reference) registryRepository {
+	repository := repositoryOf(reference)
+
+	if mirror, ok := repo.mirrors[repository.domain]; ok {
+		repository.plainHTTP = mirror.scheme == "http"
+		repository.domain = mirror.domain
+		if mirror.prefix != "" {
+			repository.path = mirror.prefix + "/" + repository.path
+		}
+	}
+
+	if repo.options.Registries[repository.domain].PlainHTTP {
+		repository.plainHTTP = true
+	}
+
+	return repository
+}
+
+func (r registryRepository) host() string {
+	if r.domain == dockerHubDomain {
+		return dockerHubRegistryDomain
+	}
+	return r.domain
+}
+
+func (r registryRepository) url(format string, a ...interface{}) string {
+	scheme := "https"
+	if r.plainHTTP {
+		scheme = "http"
+	}
+	return scheme + "://" + r.host() + "/v2/" + r.path + fmt.Sprintf(format, a...)
+}
+
+func (repo *registryResolver) manifestDigest(ctx context.Context, repository registryRepository, manifestRef string) (string, error) {
+	manifestURL := repository.url("/manifests/%s", manifestRef)
+
+	resp, err := repo.do(ctx, repository, http.MethodHead, manifestURL, manifestMediaTypes)
+	if err != nil {
+		return "", err
+	}
+	defer saveCloseBody(resp)
+
+	if err := checkResponse(resp, repository.path+":"+manifestRef); err != nil {
+		return "", err
+	}
+
+	if dig := resp.Header.Get(headerDockerDigest); dig != "" {
+		return dig, nil
+	}
+
+	// some registries only provide the digest for GET requests, so we compute it from the manifest itself
+	resp, err = repo.do(ctx, repository, http.MethodGet, manifestURL, manifestMediaTypes)
+	if err != nil {
+		return "", err
+	}
+	defer saveCloseBody(resp)
+
+	if err := checkResponse(resp, repository.path+":"+manifestRef); err != nil {
+		return "", err
+	}
+
+	if dig := resp.Header.Get(headerDockerDigest); dig != "" {
+		return dig, nil
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, resp.Body); err != nil {
+		return "", err
+	}
+
+	return string(digest.NewDigest(digest.SHA256, hash)), nil
+}
+
+func (repo *registryResolver) fetch(ctx context.Context, repository registryRepository, contentURL string, accept []string, name string) ([]byte, error) {
+	resp, err := repo.do(ctx, repository, http.MethodGet, contentURL, accept)
+	if err != nil {
+		return nil, err
+	}
+	defer saveCloseBody(resp)
+
+	if err := checkResponse(resp, name); err != nil {
+		return nil, err
+	}
+
+	return ioutil.ReadAll(resp.Body)
+}
+
+func (repo *registryResolver) ListTags(ctx context.Context, reference Reference) ([]string, error) {
+	return repo.listTags(ctx, repo.repositoryOf(reference))
+}
+
+type tagList struct {
+	Name string   `json:"name"`
+	Tags []string `json:"tags"`
+}
+
+func (repo *registryResolver) listTags(ctx context.Context, repository registryRepository) ([]string, error) {
+	tags := make([]string, 0)
+
+	tagsURL := repository.url("/tags/list")
+	for tagsURL != "" {
+		resp, err := repo.do(ctx, repository, http.MethodGet, tagsURL, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		err = checkResponse(resp, repository.path)
+		if err == nil {
+			var list tagList
+			err = json.NewDecoder(resp.Body).Decode(&list)
+			tags = append(tags, list.Tags...)
+		}
+		saveCloseBody(resp)
+
+		if err != nil {
+			return nil, err
+		}
+
+		tagsURL, err = nextLink(resp)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return tags, nil
+}
+
+// nextLink returns the absolute URL of the next page as announced by the Link header, if any
+func nextLink(resp *http.Response) (string, error) {
+	link := resp.Header.Get("Link")
+	if link == "" {
+		return "", nil
+	}
+
+	start := strings.Index(link, "<")
+	end := strings.Index(link, ">")
+	if start < 0 || end < start || !strings.Contains(link[end:], `rel="next"`) {
+		return "", nil
+	}
+
+	next, err := url.Parse(link[start+1 : end])
+	if err != nil {
+		return "", err
+	}
+
+	return resp.Request.URL.ResolveReference(next).String(), nil
+}
+
+func (repo *registryResolver) do(ctx context.Context, repository registryRepository, method string, requestURL string, accept []string) (*http.Response, error) {
+	key := repository.host() + " repository:" + repository.path + ":pull"
+
+	client, err := repo.clientFor(repository.domain)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := repo.doWithAuthorization(ctx, client, method, requestURL, accept, repo.authorization(key))
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusUnauthorized {
+		return resp, nil
+	}
+
+	challenge := resp.Header.Get("WWW-Authenticate")
+	saveCloseBody(resp)
+
+	authorization, err := repo.authorize(ctx, client, repository, challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	repo.authorizationsMutex.Lock()
+	repo.authorizations[key] = authorization
+	repo.authorizationsMutex.Unlock()
+
+	return repo.doWithAuthorization(ctx, client, method, requestURL, accept, authorization)
+}
+
+func (repo *registryResolver) doWithAuthorization(ctx context.Context, client *http.Client, method string, requestURL string, accept []string, authorization string) (*http.Response, error) {
+	req, err := http.NewRequest(method, requestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, mediaType := range accept {
+		req.Header.Add("Accept", mediaType)
+	}
+
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+
+	return repo.send(ctx, client, req)
+}
+
+func (repo *registryResolver) authorization(key string) string {
+	repo.authorizationsMutex.Lock()
+	defer repo.authorizationsMutex.Unlock()
+	return repo.authorizations[key]
+}
+
+// authorize answers the challenge of a registry and returns the value for the Authorization header
+func (repo *registryResolver) authorize(ctx context.Context, client *http.Client, repository registryRepository, challenge string) (string, error) {
+	credentials, err := repo.credentials.Credentials(repository.domain)
+	if err != nil {
+		return "", err
+	}
+
+	scheme, params := parseChallenge(challenge)
+	switch strings.ToLower(scheme) {
+	case "bearer":
+		token, err := repo.fetchToken(ctx, client, repository, params, credentials)
+		if err != nil {
+			return "", err
+		}
+		return "Bearer " + token, nil
+	case "basic":
+		if credentials.Username == "" {
+			return "", errors.Errorf("%s requires authentication, but no credentials found", repository.domain)
+		}
+		return "Basic " + basicAuth(credentials.Username, credentials.Password), nil
+	}
+
+	return "", errors.Errorf("unsupported authentication challenge '%s'", challenge)
+}
+
+func basicAuth(username, password string) string {
+	return base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+}
+
+type tokenResponse struct {
+	Token       string `json:"token"`
+	AccessToken string `json:"access_token"`
+}
+
+// fetchToken answers a bearer token challenge, anonymously when there are no credentials.
+// The client of the registry is used, token servers of private registries usually share its certificates.
+func (repo *registryResolver) fetchToken(ctx context.Context, client *http.Client, repository registryRepository, params map[string]string, credentials Credentials) (string, error) {
+	realm := params["realm"]
+	if realm == "" {
+		return "", errors.Errorf("authentication challenge without realm for %s", repository.domain)
+	}
+
+	scope := params["scope"]
+	if scope == "" {
+		scope = "repository:" + repository.path + ":pull"
+	}
+
+	var req *http.Request
+	var err error
+	if credentials.IdentityToken != "" {
+		// identity tokens are OAuth2 refresh tokens
+		form := url.Values{}
+		form.Set("grant_type", "refresh_token")
+		form.Set("refresh_token", credentials.IdentityToken)
+		form.Set("service", params["service"])
+		form.Set("scope", scope)
+		form.Set("client_id", "dockmoor")
+		req, err = http.NewRequest(http.MethodPost, realm, strings.NewReader(form.Encode()))
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		tokenURL, err := url.Parse(realm)
+		if err != nil {
+			return "", err
+		}
+
+		query := tokenURL.Query()
+		if service, ok := params["service"]; ok {
+			query.Set("service", service)
+		}
+		query.Set("scope", scope)
+		tokenURL.RawQuery = query.Encode()
+
+		req, err = http.NewRequest(http.MethodGet, tokenURL.String(), nil)
+		if err != nil {
+			return "", err
+		}
+		if credentials.Username != "" {
+			req.SetBasicAuth(credentials.Username, credentials.Password)
+		}
+	}
+
+	resp, err := repo.send(ctx, client, req)
+	if err != nil {
+		return "", err
+	}
+	defer saveCloseBody(resp)
+
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.Errorf("could not fetch token from %s: %s", realm, resp.Status)
+	}
+
+	var tr tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tr); err != nil {
+		return "", err
+	}
+
+	token := tr.Token
+	if token == "" {
+		token = tr.AccessToken
+	}
+
+	return token, nil
+}
+
+// parseChallenge parses a WWW-Authenticate header like `Bearer realm="...",service="..."`
+func parseChallenge(challenge string) (scheme string, params map[string]string) {
+	params = make(map[string]string)
+
+	challenge = strings.TrimSpace(challenge)
+	idx := strings.Index(challenge, " ")
+	if idx < 0 {
+		return challenge, params
+	}
+
+	scheme = challenge[:idx]
+	rest := challenge[idx+1:]
+
+	for rest != "" {
+		eq := strings.Index(rest, "=")
+		if eq < 0 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(rest[:eq]))
+		rest = strings.TrimSpace(rest[eq+1:])
+
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				value = rest[1:]
+				rest = ""
+			} else {
+				value = rest[1 : end+1]
+				rest = rest[end+2:]
+			}
+		} else {
+			end := strings.Index(rest, ",")
+			if end < 0 {
+				end = len(rest)
+			}
+			value = strings.TrimSpace(rest[:end])
+			rest = rest[end:]
+		}
+
+		params[key] = value
+		rest = strings.TrimPrefix(strings.TrimSpace(rest), ",")
+	}
+
+	return scheme, params
+}
+
+func checkResponse(resp *http.Response, what string) error {
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return NotFoundError{Reference: what}
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	}
+
+	return errors.Errorf("unexpected response for %s: %s", what, resp.Status)
+}
+
+func saveCloseBody(resp *http.Response) {
+	// drain, so the connection can be reused
+	_, err := io.Copy(ioutil.Discard, resp.Body)
+	deliberatelyUnsued(err)
+	err = resp.Body.Close()
+	deliberatelyUnsued(err)
+}
@@ -0,0 +1,694 @@
+package dockref
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testRegistry struct {
+	server *httptest.Server
+	// mutex serializes requests, a retried request may arrive while the timed out one is still served
+	mutex sync.Mutex
+
+	// repository path -> tag -> manifest
+	manifests map[string]map[string]string
+	// manifests referenced by digest only, e.g. the platform manifests of a manifest list
+	untagged map[string]string
+	blobs    map[string]string
+
+	requireToken  bool
+	requireBasic  string
+	omitDigest    bool
+	tagsPageSize  int
+	issuedTokens  int
+	headRequests  int
+	tokenRequests []*http.Request
+
+	// failures are answered in order before any other request is served
+	failures []testFailure
+	delay    time.Duration
+	requests int
+	sleeps   []time.Duration
+
+	// tagsStatus answers tags/list requests when set, e.g. registries that deny listing tags
+	tagsStatus   int
+	tagsRequests int
+}
+
+type testFailure struct {
+	status  int
+	headers map[string]string
+}
+
+func testRegistryNew() *testRegistry {
+	registry := testRegistryUnstartedNew()
+	registry.server.StartTLS()
+	return registry
+}
+
+// testRegistryUnstartedNew allows to configure TLS or to start a plain HTTP server
+func testRegistryUnstartedNew() *testRegistry {
+	registry := &testRegistry{
+		manifests: make(map[string]map[string]string),
+		untagged:  make(map[string]string),
+		blobs:     make(map[string]string),
+	}
+	registry.server = httptest.NewUnstartedServer(http.HandlerFunc(registry.serveHTTP))
+	return registry
+}
+
+func (r *testRegistry) Close() {
+	r.server.Close()
+}
+
+func (r *testRegistry) domain() string {
+	return strings.TrimPrefix(strings.TrimPrefix(r.server.URL, "https://"), "http://")
+}
+
+func (r *testRegistry) push(path string, manifest string, tags ...string) string {
+	if r.manifests[path] == nil {
+		r.manifests[path] = make(map[string]string)
+	}
+	for _, tag := range tags {
+		r.manifests[path][tag] = manifest
+	}
+	return manifestDigestOf(manifest)
+}
+
+func (r *testRegistry) pushUntagged(manifest string) string {
+	dig := manifestDigestOf(manifest)
+	r.untagged[dig] = manifest
+	return dig
+}
+
+func (r *testRegistry) pushBlob(content string) string {
+	dig := manifestDigestOf(content)
+	r.blobs[dig] = content
+	return dig
+}
+
+func manifestDigestOf(manifest string) string {
+	sum := sha256.Sum256([]byte(manifest))
+	return string(digest.NewDigestFromBytes(digest.SHA256, sum[:]))
+}
+
+func (r *testRegistry) resolver() *registryResolver {
+	resolver := RegistryResolverNew().(*registryResolver)
+	resolver.client = r.server.Client()
+	resolver.credentials = staticCredentialStore{}
+	resolver.sleep = func(ctx context.Context, d time.Duration) error {
+		r.sleeps = append(r.sleeps, d)
+		return nil
+	}
+	return resolver
+}
+
+func (r *testRegistry) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.requests++
+	time.Sleep(r.delay)
+
+	if len(r.failures) > 0 {
+		failure := r.failures[0]
+		r.failures = r.failures[1:]
+		for name, value := range failure.headers {
+			w.Header().Set(name, value)
+		}
+		w.WriteHeader(failure.status)
+		return
+	}
+
+	if req.URL.Path == "/token" {
+		err := req.ParseForm()
+		deliberatelyUnsued(err)
+		r.tokenRequests = append(r.tokenRequests, req)
+		r.issuedTokens++
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"token": "token-%d"}`, r.issuedTokens)
+		return
+	}
+
+	if r.requireToken && !strings.HasPrefix(req.Header.Get("Authorization"), "Bearer token-") {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test-registry"`, r.server.URL))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if r.requireBasic != "" && req.Header.Get("Authorization") != "Basic "+r.requireBasic {
+		w.Header().Set("WWW-Authenticate", `Basic realm="test-registry"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	path := strings.TrimPrefix(req.URL.Path, "/v2/")
+
+	if idx := strings.Index(path, "/manifests/"); idx >= 0 {
+		r.serveManifest(w, req, path[:idx], path[idx+len("/manifests/"):])
+		return
+	}
+
+	if idx := strings.Index(path, "/blobs/"); idx >= 0 {
+		blob, ok := r.blobs[path[idx+len("/blobs/"):]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, blob)
+		return
+	}
+
+	if strings.HasSuffix(path, "/tags/list") {
+		r.serveTags(w, req, strings.TrimSuffix(path, "/tags/list"))
+		return
+	}
+
+	w.WriteHeader(http.StatusNotFound)
+}
+
+func (r *testRegistry) serveManifest(w http.ResponseWriter, req *http.Request, path string, manifestRef string) {
+	if req.Method == http.MethodHead {
+		r.headRequests++
+	}
+
+	var manifest string
+	found := false
+	for tag, m := range r.manifests[path] {
+		if tag == manifestRef || manifestDigestOf(m) == manifestRef {
+			manifest = m
+			found = true
+		}
+	}
+	if m, ok := r.untagged[manifestRef]; ok {
+		manifest = m
+		found = true
+	}
+
+	if !found {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", mediaTypeManifestV2)
+	if !r.omitDigest || strings.HasPrefix(manifestRef, "sha256:") {
+		w.Header().Set(headerDockerDigest, manifestDigestOf(manifest))
+	}
+
+	if req.Method == http.MethodGet {
+		fmt.Fprint(w, manifest)
+	}
+}
+
+func (r *testRegistry) serveTags(w http.ResponseWriter, req *http.Request, path string) {
+	r.tagsRequests++
+	if r.tagsStatus != 0 {
+		w.WriteHeader(r.tagsStatus)
+		return
+	}
+
+	tags, ok := r.manifests[path]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	list := tagList{Name: path, Tags: make([]string, 0)}
+	for tag := range tags {
+		list.Tags = append(list.Tags, tag)
+	}
+	sort.Strings(list.Tags)
+
+	if r.tagsPageSize > 0 {
+		last := req.URL.Query().Get("last")
+		start := 0
+		for i, tag := range list.Tags {
+			if tag == last {
+				start = i + 1
+			}
+		}
+		end := start + r.tagsPageSize
+		if end < len(list.Tags) {
+			w.Header().Set("Link", fmt.Sprintf(`</v2/%s/tags/list?n=%d&last=%s>; rel="next"`, path, r.tagsPageSize, list.Tags[end-1]))
+		} else {
+			end = len(list.Tags)
+		}
+		list.Tags = list.Tags[start:end]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+func TestRegistryResolver_Resolve(t *testing.T) {
+	registry := testRegistryNew()
+	defer registry.Close()
+
+	dig1156 := registry.push("library/nginx", `{"nginx": "1.15.6"}`, "1", "1.15", "1.15.6", "latest")
+	dig1155 := registry.push("library/nginx", `{"nginx": "1.15.5"}`, "1.15.5")
+	digAlpine := registry.push("library/nginx", `{"nginx": "1.15.6-alpine"}`, "1.15-alpine", "1.15.6-alpine")
+
+	domain := registry.domain()
+
+	type T struct {
+		name     string
+		expected []string
+	}
+
+	tests := []T{
+		{name: "library/nginx:1.15.5", expected: []string{"1.15.5@" + dig1155}},
+		{name: "library/nginx:1.15", expected: []string{"1.15@" + dig1156, "1.15.6@" + dig1156}},
+		{name: "library/nginx:1", expected: []string{"1@" + dig1156, "1.15.6@" + dig1156}},
+		{name: "library/nginx:latest", expected: []string{"latest@" + dig1156, "1.15.6@" + dig1156}},
+		{name: "library/nginx", expected: []string{"latest@" + dig1156, "1.15.6@" + dig1156}},
+		{name: "library/nginx:1.15-alpine", expected: []string{"1.15-alpine@" + digAlpine, "1.15.6-alpine@" + digAlpine}},
+		{name: "library/nginx@" + dig1155, expected: []string{"@" + dig1155}},
+		{name: "library/nginx:1.15@" + dig1155, expected: []string{"1.15@" + dig1155}},
+	}
+
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			resolver := registry.resolver()
+
+			refs, e := resolver.Resolve(context.Background(), MustParse(domain+"/"+tst.name))
+			assert.Nil(t, e)
+
+			resolved := make([]string, 0)
+			for _, r := range refs {
+				assert.Equal(t, domain+"/library/nginx", r.Name())
+				resolved = append(resolved, r.Tag()+"@"+r.DigestString())
+			}
+			assert.Equal(t, tst.expected, resolved)
+		})
+	}
+}
+
+func TestRegistryResolver_Resolve_MostPreciseTag(t *testing.T) {
+	registry := testRegistryNew()
+	defer registry.Close()
+
+	dig := registry.push("library/nginx", `{"nginx": "1.15.6"}`, "1.15", "1.15.6")
+	registry.push("library/nginx", `{"nginx": "1.15.5"}`, "1.15.5")
+
+	refs, e := registry.resolver().Resolve(context.Background(), MustParse(registry.domain()+"/library/nginx:1.15"))
+	assert.Nil(t, e)
+
+	mostPrecise, e := MostPreciseTag(refs, nil)
+	assert.Nil(t, e)
+
+	pinned, e := mostPrecise.WithRequestedFormat(FormatHasName | FormatHasTag | FormatHasDigest)
+	assert.Nil(t, e)
+	assert.Equal(t, registry.domain()+"/library/nginx:1.15.6@"+dig, pinned.Formatted())
+}
+
+func TestRegistryResolver_Resolve_DateStampedTag(t *testing.T) {
+	registry := testRegistryNew()
+	defer registry.Close()
+
+	dig := registry.push("library/debian", `{"debian": "bookworm-20231009"}`, "bookworm", "bookworm-20231009")
+	registry.push("library/debian", `{"debian": "bookworm-20230904"}`, "bookworm-20230904")
+	registry.push("library/debian", `{"debian": "bullseye-20231009"}`, "bullseye", "bullseye-20231009")
+
+	refs, e := registry.resolver().Resolve(context.Background(), MustParse(registry.domain()+"/library/debian:bookworm"))
+	assert.Nil(t, e)
+
+	mostPrecise, e := MostPreciseTag(refs, nil)
+	assert.Nil(t, e)
+	assert.Equal(t, "bookworm-20231009", mostPrecise.Tag())
+	assert.Equal(t, dig, mostPrecise.DigestString())
+}
+
+func TestRegistryResolver_ResolvePlatform(t *testing.T) {
+	registry := testRegistryNew()
+	defer registry.Close()
+
+	amd64 := registry.pushUntagged(`{"mediaType": "` + mediaTypeManifestV2 + `", "config": {"digest": "sha256:amd64"}}`)
+	arm64 := registry.pushUntagged(`{"mediaType": "` + mediaTypeManifestV2 + `", "config": {"digest": "sha256:arm64"}}`)
+	armV6 := registry.pushUntagged(`{"mediaType": "` + mediaTypeManifestV2 + `", "config": {"digest": "sha256:armv6"}}`)
+	index := registry.push("library/nginx", `{
+		"mediaType": "`+mediaTypeManifestList+`",
+		"manifests": [
+			{"digest": "`+amd64+`", "platform": {"os": "linux", "architecture": "amd64"}},
+			{"digest": "`+armV6+`", "platform": {"os": "linux", "architecture": "arm", "variant": "v6"}},
+			{"digest": "`+arm64+`", "platform": {"os": "linux", "architecture": "arm64", "variant": "v8"}}
+		]}`, "1.15", "1.15.6")
+
+	resolver := registry.resolver()
+	reference := MustParse(registry.domain() + "/library/nginx:1.15")
+
+	refs, err := ResolveForPlatform(context.Background(), resolver, reference, Platform{})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1.15@" + index, "1.15.6@" + index}, tagsAndDigests(refs))
+
+	for platform, expected := range map[string]string{
+		"linux/amd64":    amd64,
+		"linux/arm64":    arm64,
+		"linux/arm64/v8": arm64,
+		"linux/arm/v6":   armV6,
+	} {
+		refs, err := ResolveForPlatform(context.Background(), resolver, reference, MustParsePlatform(platform))
+		assert.Nil(t, err, platform)
+		assert.Equal(t, []string{"1.15@" + expected, "1.15.6@" + expected}, tagsAndDigests(refs), platform)
+	}
+
+	_, err = ResolveForPlatform(context.Background(), resolver, reference, MustParsePlatform("linux/arm/v7"))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "not available for platform linux/arm/v7")
+	}
+}
+
+func TestRegistryResolver_ResolvePlatform_SinglePlatformImage(t *testing.T) {
+	registry := testRegistryNew()
+	defer registry.Close()
+
+	config := registry.pushBlob(`{"os": "linux", "architecture": "amd64"}`)
+	dig := registry.push("library/nginx", `{"mediaType": "`+mediaTypeManifestV2+`", "config": {"digest": "`+config+`"}}`, "1.15.6")
+
+	resolver := registry.resolver()
+	reference := MustParse(registry.domain() + "/library/nginx:1.15.6")
+
+	refs, err := resolver.ResolvePlatform(context.Background(), reference, MustParsePlatform("linux/amd64"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1.15.6@" + dig}, tagsAndDigests(refs))
+
+	_, err = resolver.ResolvePlatform(context.Background(), reference, MustParsePlatform("linux/arm64"))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "only available for platform linux/amd64, not linux/arm64")
+	}
+}
+
+func TestRegistryResolver_Resolve_Token(t *testing.T) {
+	registry := testRegistryNew()
+	defer registry.Close()
+	registry.requireToken = true
+
+	dig := registry.push("menedev/testimagea", `{"testimagea": "1.0.0"}`, "1.0.0")
+
+	resolver := registry.resolver()
+	refs, e := resolver.Resolve(context.Background(), MustParse(registry.domain()+"/menedev/testimagea:1.0.0"))
+	assert.Nil(t, e)
+	assert.Len(t, refs, 1)
+	if len(refs) > 0 {
+		assert.Equal(t, dig, refs[0].DigestString())
+	}
+
+	assert.Equal(t, 1, registry.issuedTokens, "token is reused for the same scope")
+	if assert.Len(t, registry.tokenRequests, 1) {
+		tokenRequest := registry.tokenRequests[0]
+		assert.Equal(t, http.MethodGet, tokenRequest.Method)
+		assert.Equal(t, "test-registry", tokenRequest.Form.Get("service"))
+		assert.Equal(t, "repository:menedev/testimagea:pull", tokenRequest.Form.Get("scope"))
+		_, _, hasBasicAuth := tokenRequest.BasicAuth()
+		assert.False(t, hasBasicAuth)
+	}
+}
+
+func TestRegistryResolver_Resolve_TokenWithCredentials(t *testing.T) {
+	registry := testRegistryNew()
+	defer registry.Close()
+	registry.requireToken = true
+
+	registry.push("menedev/private", `{"private": "1.0.0"}`, "1.0.0")
+
+	resolver := registry.resolver()
+	resolver.credentials = staticCredentialStore{
+		registry.domain(): {Username: "mene", Password: "secret"},
+	}
+
+	_, e := resolver.Resolve(context.Background(), MustParse(registry.domain()+"/menedev/private:1.0.0"))
+	assert.Nil(t, e)
+
+	if assert.Len(t, registry.tokenRequests, 1) {
+		username, password, ok := registry.tokenRequests[0].BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "mene", username)
+		assert.Equal(t, "secret", password)
+	}
+}
+
+func TestRegistryResolver_Resolve_TokenWithIdentityToken(t *testing.T) {
+	registry := testRegistryNew()
+	defer registry.Close()
+	registry.requireToken = true
+
+	registry.push("menedev/private", `{"private": "1.0.0"}`, "1.0.0")
+
+	resolver := registry.resolver()
+	resolver.credentials = staticCredentialStore{
+		registry.domain(): {IdentityToken: "refresh-me"},
+	}
+
+	_, e := resolver.Resolve(context.Background(), MustParse(registry.domain()+"/menedev/private:1.0.0"))
+	assert.Nil(t, e)
+
+	if assert.Len(t, registry.tokenRequests, 1) {
+		tokenRequest := registry.tokenRequests[0]
+		assert.Equal(t, http.MethodPost, tokenRequest.Method)
+		assert.Equal(t, "refresh_token", tokenRequest.PostForm.Get("grant_type"))
+		assert.Equal(t, "refresh-me", tokenRequest.PostForm.Get("refresh_token"))
+		assert.Equal(t, "repository:menedev/private:pull", tokenRequest.PostForm.Get("scope"))
+	}
+}
+
+func TestRegistryResolver_Resolve_Basic(t *testing.T) {
+	registry := testRegistryNew()
+	defer registry.Close()
+	registry.requireBasic = basicAuth("mene", "secret")
+
+	dig := registry.push("menedev/private", `{"private": "1.0.0"}`, "1.0.0")
+
+	t.Run("with credentials", func(t *testing.T) {
+		resolver := registry.resolver()
+		resolver.credentials = staticCredentialStore{
+			registry.domain(): {Username: "mene", Password: "secret"},
+		}
+
+		refs, e := resolver.Resolve(context.Background(), MustParse(registry.domain()+"/menedev/private:1.0.0"))
+		assert.Nil(t, e)
+		if assert.Len(t, refs, 1) {
+			assert.Equal(t, dig, refs[0].DigestString())
+		}
+	})
+
+	t.Run("without credentials", func(t *testing.T) {
+		refs, e := registry.resolver().Resolve(context.Background(), MustParse(registry.domain()+"/menedev/private:1.0.0"))
+		assert.Error(t, e)
+		assert.Nil(t, refs)
+	})
+}
+
+func TestRegistryResolver_Resolve_NotFound(t *testing.T) {
+	registry := testRegistryNew()
+	defer registry.Close()
+
+	registry.push("library/nginx", `{"nginx": "1.15.6"}`, "1.15.6")
+
+	t.Run("unknown tag", func(t *testing.T) {
+		refs, e := registry.resolver().Resolve(context.Background(), MustParse(registry.domain()+"/library/nginx:unknown"))
+		assert.Error(t, e)
+		assert.True(t, IsNotFound(e))
+		assert.Nil(t, refs)
+	})
+
+	t.Run("unknown repository", func(t *testing.T) {
+		refs, e := registry.resolver().Resolve(context.Background(), MustParse(registry.domain()+"/library/unknown:1.0"))
+		assert.Error(t, e)
+		assert.True(t, IsNotFound(e))
+		assert.Nil(t, refs)
+	})
+}
+
+func TestRegistryResolver_Resolve_DigestOnly(t *testing.T) {
+	resolver := RegistryResolverNew()
+
+	refs, e := resolver.Resolve(context.Background(), MustParse("3247732819d6cd7af0c45a05b30d0b147f05a25ee2e83d7b9707ee25fcdd0f58"))
+	assert.Error(t, e)
+	assert.Nil(t, refs)
+}
+
+func TestRegistryResolver_Resolve_ComputesMissingDigest(t *testing.T) {
+	registry := testRegistryNew()
+	defer registry.Close()
+	registry.omitDigest = true
+
+	dig := registry.push("library/nginx", `{"nginx": "1.15.6"}`, "1.15.6")
+
+	refs, e := registry.resolver().Resolve(context.Background(), MustParse(registry.domain()+"/library/nginx:1.15.6"))
+	assert.Nil(t, e)
+	if assert.Len(t, refs, 1) {
+		assert.Equal(t, dig, refs[0].DigestString())
+	}
+}
+
+func TestRegistryResolver_Resolve_IgnoresFailingTagList(t *testing.T) {
+	for _, status := range []int{http.StatusForbidden, http.StatusNotFound} {
+		t.Run(fmt.Sprint(status), func(t *testing.T) {
+			registry := testRegistryNew()
+			defer registry.Close()
+			registry.tagsStatus = status
+
+			dig := registry.push("library/nginx", `{"nginx": "1.15.6"}`, "1.15", "1.15.6")
+
+			refs, e := registry.resolver().Resolve(context.Background(), MustParse(registry.domain()+"/library/nginx:1.15"))
+			assert.Nil(t, e)
+			if assert.Len(t, refs, 1) {
+				assert.Equal(t, "1.15", refs[0].Tag())
+				assert.Equal(t, dig, refs[0].DigestString())
+			}
+		})
+	}
+}
+
+func TestRegistryResolver_Resolve_SkipsTagListForDigests(t *testing.T) {
+	registry := testRegistryNew()
+	defer registry.Close()
+
+	dig := registry.push("library/nginx", `{"nginx": "1.15.6"}`, "1.15", "1.15.6")
+
+	refs, e := registry.resolver().Resolve(context.Background(), MustParse(registry.domain()+"/library/nginx@"+dig))
+	assert.Nil(t, e)
+	assert.Len(t, refs, 1)
+	assert.Equal(t, 0, registry.tagsRequests)
+}
+
+func TestRegistryResolver_Resolve_NoPreciseTags(t *testing.T) {
+	registry := testRegistryNew()
+	defer registry.Close()
+
+	registry.push("library/nginx", `{"nginx": "1.15.6"}`, "1.15", "1.15.6")
+
+	resolver := registry.resolver()
+	resolver.options.NoPreciseTags = true
+
+	refs, e := resolver.Resolve(context.Background(), MustParse(registry.domain()+"/library/nginx:1.15"))
+	assert.Nil(t, e)
+	assert.Len(t, refs, 1)
+	assert.Equal(t, 0, registry.tagsRequests)
+}
+
+func TestRegistryResolver_listTags_FollowsLinks(t *testing.T) {
+	registry := testRegistryNew()
+	defer registry.Close()
+	registry.tagsPageSize = 2
+
+	registry.push("library/nginx", `{"nginx": "1.15.6"}`, "1", "1.15", "1.15.6", "latest", "mainline")
+
+	tags, e := registry.resolver().listTags(context.Background(), registryRepository{domain: registry.domain(), path: "library/nginx"})
+	assert.Nil(t, e)
+	assert.ElementsMatch(t, []string{"1", "1.15", "1.15.6", "latest", "mainline"}, tags)
+}
+
+func TestRegistryResolver_LimitsCandidates(t *testing.T) {
+	registry := testRegistryNew()
+	defer registry.Close()
+
+	registry.push("library/nginx", `{"nginx": "latest"}`, "latest")
+	for i := 0; i < 2*maxPreciseTagCandidates; i++ {
+		registry.push("library/nginx", fmt.Sprintf(`{"nginx": "1.%d"}`, i), fmt.Sprintf("1.%d", i))
+	}
+
+	refs, e := registry.resolver().Resolve(context.Background(), MustParse(registry.domain()+"/library/nginx"))
+	assert.Nil(t, e)
+	assert.Len(t, refs, 1)
+	assert.Equal(t, 1+maxPreciseTagCandidates, registry.headRequests)
+}
+
+func TestRegistryRepository_host(t *testing.T) {
+	assert.Equal(t, "registry-1.docker.io", repositoryOf(MustParse("nginx")).host())
+	assert.Equal(t, "quay.io", repositoryOf(MustParse("quay.io/coreos/etcd")).host())
+	assert.Equal(t, "localhost:5000", repositoryOf(MustParse("localhost:5000/app")).host())
+}
+
+func TestRegistryResolver_repositoryOf_Mirrors(t *testing.T) {
+	resolver, err := RegistryResolverWithOptionsNew(RegistryOptions{Mirrors: map[string]string{
+		"index.docker.io": "http://mirror.corp:5000",
+		"quay.io":         "https://harbor.corp/quay-proxy/",
+	}})
+	assert.Nil(t, err)
+	registry := resolver.(*registryResolver)
+
+	assert.Equal(t, "http://mirror.corp:5000/v2/library/nginx/tags/list", registry.repositoryOf(MustParse("nginx")).url("/tags/list"))
+	assert.Equal(t, "https://harbor.corp/v2/quay-proxy/coreos/etcd/tags/list", registry.repositoryOf(MustParse("quay.io/coreos/etcd")).url("/tags/list"))
+	assert.Equal(t, "https://localhost:5000/v2/app/tags/list", registry.repositoryOf(MustParse("localhost:5000/app")).url("/tags/list"))
+}
+
+func TestRegistryResolver_Resolve_Mirror(t *testing.T) {
+	registry := testRegistryNew()
+	defer registry.Close()
+
+	dig := registry.push("hub/library/nginx", `{"nginx": "1.15.6"}`, "1.15", "1.15.6")
+
+	resolver, err := RegistryResolverWithOptionsNew(RegistryOptions{Mirrors: map[string]string{
+		"docker.io": registry.domain() + "/hub",
+	}})
+	assert.Nil(t, err)
+	resolver.(*registryResolver).client = registry.server.Client()
+	resolver.(*registryResolver).credentials = staticCredentialStore{}
+
+	refs, err := resolver.Resolve(context.Background(), MustParse("nginx:1.15"))
+	assert.Nil(t, err)
+
+	mostPrecise, err := MostPreciseTag(refs, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "docker.io/library/nginx", mostPrecise.Name())
+
+	pinned, err := mostPrecise.WithRequestedFormat(FormatHasName | FormatHasTag | FormatHasDigest)
+	assert.Nil(t, err)
+	assert.Equal(t, "nginx:1.15.6@"+dig, pinned.Formatted())
+}
+
+func TestRegistryResolverWithOptionsNew_InvalidMirrors(t *testing.T) {
+	for _, endpoint := range []string{"ftp://mirror.corp", "https://", ""} {
+		_, err := RegistryResolverWithOptionsNew(RegistryOptions{Mirrors: map[string]string{"docker.io": endpoint}})
+		if assert.Error(t, err, endpoint) {
+			assert.Contains(t, err.Error(), "invalid mirror for docker.io")
+		}
+	}
+}
+
+func TestParseChallenge(t *testing.T) {
+	scheme, params := parseChallenge(`Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:library/nginx:pull"`)
+	assert.Equal(t, "Bearer", scheme)
+	assert.Equal(t, map[string]string{
+		"realm":   "https://auth.docker.io/token",
+		"service": "registry.docker.io",
+		"scope":   "repository:library/nginx:pull",
+	}, params)
+
+	scheme, params = parseChallenge(`Basic realm=Registry`)
+	assert.Equal(t, "Basic", scheme)
+	assert.Equal(t, map[string]string{"realm": "Registry"}, params)
+
+	scheme, params = parseChallenge(`Basic`)
+	assert.Equal(t, "Basic", scheme)
+	assert.Empty(t, params)
+}
+
+func TestIsNotFound(t *testing.T) {
+	assert.True(t, IsNotFound(NotFoundError{Reference: "nginx"}))
+	assert.False(t, IsNotFound(fmt.Errorf("other")))
+	assert.False(t, IsNotFound(nil))
+}
+
+func TestRegistryResolver_ListTags(t *testing.T) {
+	registry := testRegistryNew()
+	defer registry.Close()
+
+	registry.push("library/nginx", `{"nginx": "1.15.6"}`, "1.15", "1.15.6")
+	registry.push("library/nginx", `{"nginx": "1.16.0"}`, "1.16", "1.16.0")
+
+	tags, err := registry.resolver().ListTags(context.Background(), MustParse(registry.domain()+"/library/nginx:1.15"))
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{"1.15", "1.15.6", "1.16", "1.16.0"}, tags)
+}
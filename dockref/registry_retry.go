@@ -0,0 +1,159 @@
+package dockref
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	headerRetryAfter         = "Retry-After"
+	headerRateLimitLimit     = "RateLimit-Limit"
+	headerRateLimitRemaining = "RateLimit-Remaining"
+	headerRateLimitReset     = "RateLimit-Reset"
+)
+
+// RateLimitError is returned when a registry keeps rejecting requests because a rate limit is exceeded
+type RateLimitError struct {
+	Registry string
+	// RetryAfter is the delay requested by the registry, zero when unknown
+	RetryAfter time.Duration
+	// Limit is the value of the RateLimit-Limit header, e.g. 100;w=21600 on Docker Hub
+	Limit string
+}
+
+func (e RateLimitError) Error() string {
+	msg := fmt.Sprintf("rate limit of %s exceeded", e.Registry)
+	if e.Limit != "" {
+		msg += " (" + e.Limit + ")"
+	}
+	if e.RetryAfter > 0 {
+		msg += ", retry after " + e.RetryAfter.String()
+	}
+	return msg
+}
+
+// IsRateLimited reports whether err signals that a registry rate limit was exceeded
+func IsRateLimited(err error) bool {
+	_, ok := errors.Cause(err).(RateLimitError)
+	return ok
+}
+
+func rateLimitErrorOf(resp *http.Response, now time.Time) RateLimitError {
+	return RateLimitError{
+		Registry:   resp.Request.URL.Host,
+		RetryAfter: retryAfterOf(resp, now),
+		Limit:      resp.Header.Get(headerRateLimitLimit),
+	}
+}
+
+// isTemporaryStatus reports responses that may succeed when the request is repeated
+func isTemporaryStatus(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryAfterOf reads the Retry-After header in seconds or as HTTP date, or else the RateLimit-Reset header in seconds
+func retryAfterOf(resp *http.Response, now time.Time) time.Duration {
+	if value := resp.Header.Get(headerRetryAfter); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+			return time.Duration(seconds) * time.Second
+		}
+		if date, err := http.ParseTime(value); err == nil && date.After(now) {
+			return date.Sub(now)
+		}
+	}
+
+	// only wait for the reset when the limit is actually exhausted
+	remaining := strings.SplitN(resp.Header.Get(headerRateLimitRemaining), ";", 2)[0]
+	if value := resp.Header.Get(headerRateLimitReset); value != "" && strings.TrimSpace(remaining) == "0" {
+		if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+
+	return 0
+}
+
+// send performs the request and repeats it with exponential backoff after network errors and temporary failures.
+// Rate limits that don't allow a retry in time result in a RateLimitError.
+func (repo *registryResolver) send(ctx context.Context, client *http.Client, req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+
+		resp, err := client.Do(req.WithContext(ctx))
+		if err != nil && ctx.Err() != nil {
+			return nil, errors.Wrapf(ctx.Err(), "%s %s", req.Method, req.URL)
+		}
+
+		if err == nil && !isTemporaryStatus(resp.StatusCode) {
+			return resp, nil
+		}
+
+		delay, retry := repo.retryDelay(ctx, attempt, resp)
+		if !retry {
+			if err == nil && resp.StatusCode == http.StatusTooManyRequests {
+				saveCloseBody(resp)
+				return nil, rateLimitErrorOf(resp, repo.now())
+			}
+			return resp, err
+		}
+
+		if resp != nil {
+			saveCloseBody(resp)
+		}
+
+		if err := repo.sleep(ctx, delay); err != nil {
+			return nil, errors.Wrapf(err, "%s %s", req.Method, req.URL)
+		}
+	}
+}
+
+// retryDelay doubles the backoff for each attempt, unless the registry asks to wait longer
+func (repo *registryResolver) retryDelay(ctx context.Context, attempt int, resp *http.Response) (time.Duration, bool) {
+	if attempt >= repo.options.Retries {
+		return 0, false
+	}
+
+	delay := repo.options.Backoff << uint(attempt)
+	if resp != nil {
+		if retryAfter := retryAfterOf(resp, repo.now()); retryAfter > delay {
+			delay = retryAfter
+		}
+	}
+
+	if repo.options.MaxRetryWait > 0 && delay > repo.options.MaxRetryWait {
+		return 0, false
+	}
+
+	if deadline, ok := ctx.Deadline(); ok && repo.now().Add(delay).After(deadline) {
+		return 0, false
+	}
+
+	return delay, true
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,129 @@
+package dockref
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRegistryResolver_RetriesTemporaryFailuresWithBackoff(t *testing.T) {
+	registry := testRegistryNew()
+	defer registry.Close()
+
+	dig := registry.push("library/nginx", `{"nginx": "1.15.6"}`, "1.15.6")
+	registry.failures = []testFailure{{status: http.StatusServiceUnavailable}, {status: http.StatusBadGateway}}
+
+	refs, err := registry.resolver().Resolve(context.Background(), MustParse(registry.domain()+"/library/nginx:1.15.6"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1.15.6@" + dig}, tagsAndDigests(refs))
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second}, registry.sleeps)
+}
+
+func TestRegistryResolver_RespectsRetryAfter(t *testing.T) {
+	registry := testRegistryNew()
+	defer registry.Close()
+
+	registry.push("library/nginx", `{"nginx": "1.15.6"}`, "1.15.6")
+	registry.failures = []testFailure{{status: http.StatusTooManyRequests, headers: map[string]string{"Retry-After": "5"}}}
+
+	_, err := registry.resolver().Resolve(context.Background(), MustParse(registry.domain()+"/library/nginx:1.15.6"))
+	assert.Nil(t, err)
+	assert.Equal(t, []time.Duration{5 * time.Second}, registry.sleeps)
+}
+
+func TestRegistryResolver_RateLimitError(t *testing.T) {
+	registry := testRegistryNew()
+	defer registry.Close()
+
+	registry.push("library/nginx", `{"nginx": "1.15.6"}`, "1.15.6")
+	tooMany := testFailure{status: http.StatusTooManyRequests, headers: map[string]string{"RateLimit-Limit": "100;w=21600"}}
+	registry.failures = []testFailure{tooMany, tooMany, tooMany, tooMany}
+
+	_, err := registry.resolver().Resolve(context.Background(), MustParse(registry.domain()+"/library/nginx:1.15.6"))
+	assert.True(t, IsRateLimited(err))
+	assert.Equal(t, RateLimitError{Registry: registry.domain(), Limit: "100;w=21600"}, err)
+	assert.Equal(t, "rate limit of "+registry.domain()+" exceeded (100;w=21600)", err.Error())
+	assert.Len(t, registry.sleeps, 3)
+}
+
+func TestRegistryResolver_FailsFastWhenRetryAfterIsTooLong(t *testing.T) {
+	registry := testRegistryNew()
+	defer registry.Close()
+
+	registry.push("library/nginx", `{"nginx": "1.15.6"}`, "1.15.6")
+	registry.failures = []testFailure{{status: http.StatusTooManyRequests, headers: map[string]string{"Retry-After": "3600"}}}
+
+	_, err := registry.resolver().Resolve(context.Background(), MustParse(registry.domain()+"/library/nginx:1.15.6"))
+	if assert.True(t, IsRateLimited(err)) {
+		assert.Equal(t, time.Hour, err.(RateLimitError).RetryAfter)
+	}
+	assert.Empty(t, registry.sleeps)
+	assert.Equal(t, 1, registry.requests)
+}
+
+func TestRegistryResolver_RequestTimeout(t *testing.T) {
+	registry := testRegistryNew()
+	defer registry.Close()
+
+	registry.push("library/nginx", `{"nginx": "1.15.6"}`, "1.15.6")
+	registry.delay = 50 * time.Millisecond
+
+	resolver := registry.resolver()
+	resolver.client.Timeout = 10 * time.Millisecond
+	resolver.options.Retries = 1
+
+	_, err := resolver.Resolve(context.Background(), MustParse(registry.domain()+"/library/nginx:1.15.6"))
+	assert.Error(t, err)
+	assert.Equal(t, []time.Duration{time.Second}, registry.sleeps, "timeouts are retried")
+}
+
+func TestRegistryResolver_StopsWhenContextIsDone(t *testing.T) {
+	registry := testRegistryNew()
+	defer registry.Close()
+
+	registry.push("library/nginx", `{"nginx": "1.15.6"}`, "1.15.6")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := registry.resolver().Resolve(ctx, MustParse(registry.domain()+"/library/nginx:1.15.6"))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), context.Canceled.Error())
+	}
+	assert.Empty(t, registry.sleeps)
+}
+
+func TestRegistryResolver_DoesNotRetryBeyondDeadline(t *testing.T) {
+	registry := testRegistryNew()
+	defer registry.Close()
+
+	registry.failures = []testFailure{{status: http.StatusServiceUnavailable}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	_, err := registry.resolver().Resolve(ctx, MustParse(registry.domain()+"/library/nginx:1.15.6"))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "503")
+	}
+	assert.Empty(t, registry.sleeps)
+}
+
+func TestRetryAfterOf(t *testing.T) {
+	now := time.Date(2018, 11, 20, 12, 0, 0, 0, time.UTC)
+	response := func(headers map[string]string) *http.Response {
+		resp := &http.Response{Header: http.Header{}}
+		for name, value := range headers {
+			resp.Header.Set(name, value)
+		}
+		return resp
+	}
+
+	assert.Equal(t, 30*time.Second, retryAfterOf(response(map[string]string{"Retry-After": "30"}), now))
+	assert.Equal(t, 2*time.Minute, retryAfterOf(response(map[string]string{"Retry-After": "Tue, 20 Nov 2018 12:02:00 GMT"}), now))
+	assert.Equal(t, 10*time.Second, retryAfterOf(response(map[string]string{"RateLimit-Remaining": "0;w=21600", "RateLimit-Reset": "10"}), now))
+	assert.Equal(t, time.Duration(0), retryAfterOf(response(map[string]string{"RateLimit-Remaining": "5;w=21600", "RateLimit-Reset": "10"}), now))
+	assert.Equal(t, time.Duration(0), retryAfterOf(response(nil), now))
+}
@@ -0,0 +1,153 @@
+package dockref
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// RegistryConfig controls how a single registry is contacted
+type RegistryConfig struct {
+	// CAFiles are PEM encoded CA certificates trusted in addition to the system's
+	CAFiles []string
+	// ClientCert and ClientKey are the PEM encoded certificate and key used for mutual TLS
+	ClientCert string
+	ClientKey  string
+	// Proxy is the URL of the proxy for this registry, empty uses HTTPS_PROXY and NO_PROXY
+	Proxy string
+	// Insecure accepts self-signed and otherwise unverifiable certificates
+	Insecure bool
+	// PlainHTTP uses http instead of https
+	PlainHTTP bool
+}
+
+func (config RegistryConfig) isZero() bool {
+	return len(config.CAFiles) == 0 && config.ClientCert == "" && config.ClientKey == "" &&
+		config.Proxy == "" && !config.Insecure && !config.PlainHTTP
+}
+
+// clientFor returns the client for the domain of a registry, using its RegistryConfig and certificates of the certs dir
+func (repo *registryResolver) clientFor(domain string) (*http.Client, error) {
+	repo.clientsMutex.Lock()
+	defer repo.clientsMutex.Unlock()
+
+	if client, ok := repo.clients[domain]; ok {
+		return client, nil
+	}
+
+	config := repo.options.Registries[domain]
+	if err := addCertsDir(&config, repo.options.CertsDir, domain); err != nil {
+		return nil, errors.Wrapf(err, "cannot read certificates of %s", domain)
+	}
+
+	client := repo.client
+	if !config.isZero() {
+		transport, err := registryTransport(config)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid configuration of registry %s", domain)
+		}
+		client = &http.Client{Transport: transport, Timeout: repo.client.Timeout}
+	}
+
+	repo.clients[domain] = client
+	return client, nil
+}
+
+// addCertsDir adds the certificates of <certsDir>/<domain> the same way as the docker daemon reads /etc/docker/certs.d:
+// *.crt are CA certificates, *.cert are client certificates with the key in the *.key file of the same name
+func addCertsDir(config *RegistryConfig, certsDir string, domain string) error {
+	if certsDir == "" {
+		return nil
+	}
+
+	dir := filepath.Join(certsDir, domain)
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		path := filepath.Join(dir, file.Name())
+		switch filepath.Ext(file.Name()) {
+		case ".crt":
+			config.CAFiles = append(config.CAFiles, path)
+		case ".cert":
+			if config.ClientCert != "" {
+				continue
+			}
+			key := strings.TrimSuffix(path, ".cert") + ".key"
+			if _, err := os.Stat(key); err != nil {
+				return errors.Errorf("missing key %s for client certificate %s", key, path)
+			}
+			config.ClientCert, config.ClientKey = path, key
+		}
+	}
+
+	return nil
+}
+
+func registryTransport(config RegistryConfig) (*http.Transport, error) {
+	tlsConfig := &tls.Config{
+		// only set when explicitly configured, self-signed registries are common in private networks
+		InsecureSkipVerify: config.Insecure,
+	}
+
+	if len(config.CAFiles) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		for _, caFile := range config.CAFiles {
+			pem, err := ioutil.ReadFile(caFile)
+			if err != nil {
+				return nil, err
+			}
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, errors.Errorf("no certificates found in %s", caFile)
+			}
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if config.ClientCert != "" || config.ClientKey != "" {
+		certificate, err := tls.LoadX509KeyPair(config.ClientCert, config.ClientKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid client certificate")
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	proxy := http.ProxyFromEnvironment
+	if config.Proxy != "" {
+		proxyURL, err := url.Parse(config.Proxy)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid proxy")
+		}
+		proxy = http.ProxyURL(proxyURL)
+	}
+
+	// the same settings as http.DefaultTransport
+	return &http.Transport{
+		Proxy: proxy,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSClientConfig:       tlsConfig,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}, nil
+}
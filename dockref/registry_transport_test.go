@@ -0,0 +1,200 @@
+package dockref
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func transportTestDir(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "dockmoor-certs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir, func() {
+		deliberatelyUnsued(os.RemoveAll(dir))
+	}
+}
+
+func writeTestFile(t *testing.T, path string, content []byte) string {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// certificatePEM returns the self-signed certificate of the test server
+func (r *testRegistry) certificatePEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: r.server.Certificate().Raw})
+}
+
+func (r *testRegistry) keyPEM(t *testing.T) []byte {
+	key, err := x509.MarshalPKCS8PrivateKey(r.server.TLS.Certificates[0].PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key})
+}
+
+// resolverWithOptions doesn't use the client of the test server, which trusts its certificate
+func (r *testRegistry) resolverWithOptions(options RegistryOptions) (*registryResolver, error) {
+	resolver, err := RegistryResolverWithOptionsNew(options)
+	if err != nil {
+		return nil, err
+	}
+
+	registry := resolver.(*registryResolver)
+	registry.credentials = staticCredentialStore{}
+	registry.sleep = func(ctx context.Context, d time.Duration) error {
+		return nil
+	}
+	return registry, nil
+}
+
+func TestRegistryResolver_UntrustedCertificate(t *testing.T) {
+	registry := testRegistryNew()
+	defer registry.Close()
+
+	registry.push("library/nginx", `{"nginx": "1.15.6"}`, "1.15.6")
+
+	resolver, err := registry.resolverWithOptions(RegistryOptions{})
+	assert.Nil(t, err)
+
+	_, err = resolver.Resolve(context.Background(), MustParse(registry.domain()+"/library/nginx:1.15.6"))
+	assert.Error(t, err)
+}
+
+func TestRegistryResolver_CAFiles(t *testing.T) {
+	registry := testRegistryNew()
+	defer registry.Close()
+
+	dig := registry.push("library/nginx", `{"nginx": "1.15.6"}`, "1.15.6")
+
+	dir, cleanup := transportTestDir(t)
+	defer cleanup()
+	caFile := writeTestFile(t, filepath.Join(dir, "ca.pem"), registry.certificatePEM())
+
+	resolver, err := registry.resolverWithOptions(RegistryOptions{Registries: map[string]RegistryConfig{
+		registry.domain(): {CAFiles: []string{caFile}},
+	}})
+	assert.Nil(t, err)
+
+	refs, err := resolver.Resolve(context.Background(), MustParse(registry.domain()+"/library/nginx:1.15.6"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1.15.6@" + dig}, tagsAndDigests(refs))
+}
+
+func TestRegistryResolver_Insecure(t *testing.T) {
+	registry := testRegistryNew()
+	defer registry.Close()
+
+	dig := registry.push("library/nginx", `{"nginx": "1.15.6"}`, "1.15.6")
+
+	resolver, err := registry.resolverWithOptions(RegistryOptions{Registries: map[string]RegistryConfig{
+		registry.domain(): {Insecure: true},
+	}})
+	assert.Nil(t, err)
+
+	refs, err := resolver.Resolve(context.Background(), MustParse(registry.domain()+"/library/nginx:1.15.6"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1.15.6@" + dig}, tagsAndDigests(refs))
+}
+
+func TestRegistryResolver_PlainHTTP(t *testing.T) {
+	registry := testRegistryUnstartedNew()
+	registry.server.Start()
+	defer registry.Close()
+
+	dig := registry.push("library/nginx", `{"nginx": "1.15.6"}`, "1.15.6")
+
+	resolver, err := registry.resolverWithOptions(RegistryOptions{Registries: map[string]RegistryConfig{
+		registry.domain(): {PlainHTTP: true},
+	}})
+	assert.Nil(t, err)
+
+	refs, err := resolver.Resolve(context.Background(), MustParse(registry.domain()+"/library/nginx:1.15.6"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1.15.6@" + dig}, tagsAndDigests(refs))
+}
+
+func TestRegistryResolver_CertsDir(t *testing.T) {
+	registry := testRegistryUnstartedNew()
+	registry.server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	registry.server.StartTLS()
+	defer registry.Close()
+
+	dig := registry.push("library/nginx", `{"nginx": "1.15.6"}`, "1.15.6")
+
+	dir, cleanup := transportTestDir(t)
+	defer cleanup()
+
+	resolver, err := registry.resolverWithOptions(RegistryOptions{CertsDir: dir})
+	assert.Nil(t, err)
+	_, err = resolver.Resolve(context.Background(), MustParse(registry.domain()+"/library/nginx:1.15.6"))
+	assert.Error(t, err)
+
+	// the server's certificate doubles as client certificate
+	writeTestFile(t, filepath.Join(dir, registry.domain(), "ca.crt"), registry.certificatePEM())
+	writeTestFile(t, filepath.Join(dir, registry.domain(), "client.cert"), registry.certificatePEM())
+	writeTestFile(t, filepath.Join(dir, registry.domain(), "client.key"), registry.keyPEM(t))
+
+	resolver, err = registry.resolverWithOptions(RegistryOptions{CertsDir: dir})
+	assert.Nil(t, err)
+	refs, err := resolver.Resolve(context.Background(), MustParse(registry.domain()+"/library/nginx:1.15.6"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1.15.6@" + dig}, tagsAndDigests(refs))
+}
+
+func TestRegistryResolver_CertsDir_MissingKey(t *testing.T) {
+	dir, cleanup := transportTestDir(t)
+	defer cleanup()
+	writeTestFile(t, filepath.Join(dir, "registry.corp", "client.cert"), []byte{})
+
+	resolver := registryResolverNew(RegistryOptions{CertsDir: dir})
+	_, err := resolver.Resolve(context.Background(), MustParse("registry.corp/app:1.0"))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "missing key")
+	}
+}
+
+func TestRegistryResolverWithOptionsNew_InvalidRegistries(t *testing.T) {
+	dir, cleanup := transportTestDir(t)
+	defer cleanup()
+	invalid := writeTestFile(t, filepath.Join(dir, "invalid.pem"), []byte("no certificate"))
+
+	for name, config := range map[string]RegistryConfig{
+		"missing CA file":     {CAFiles: []string{filepath.Join(dir, "missing.pem")}},
+		"invalid CA file":     {CAFiles: []string{invalid}},
+		"invalid certificate": {ClientCert: invalid, ClientKey: invalid},
+		"missing client key":  {ClientCert: invalid},
+		"invalid proxy":       {Proxy: "http://proxy.corp:port"},
+	} {
+		_, err := RegistryResolverWithOptionsNew(RegistryOptions{Registries: map[string]RegistryConfig{"registry.corp": config}})
+		if assert.Error(t, err, name) {
+			assert.Contains(t, err.Error(), "invalid configuration of registry registry.corp", name)
+		}
+	}
+}
+
+func TestRegistryTransport_Proxy(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "https://registry.corp/v2/", nil)
+	assert.Nil(t, err)
+
+	transport, err := registryTransport(RegistryConfig{Proxy: "http://proxy.corp:3128"})
+	assert.Nil(t, err)
+
+	proxy, err := transport.Proxy(req)
+	assert.Nil(t, err)
+	assert.Equal(t, "http://proxy.corp:3128", proxy.String())
+}
@@ -0,0 +1,84 @@
+package dockref
+
+import (
+	"context"
+	"sync"
+)
+
+// Resolution is the outcome of resolving a single reference
+type Resolution struct {
+	References []Reference
+	Err        error
+}
+
+// ResolveRequest asks for a reference to be resolved for a platform, the zero platform resolves the manifest list
+type ResolveRequest struct {
+	Reference Reference
+	Platform  Platform
+}
+
+// Key identifies equal requests, it is the original string of the reference for the zero platform
+func (request ResolveRequest) Key() string {
+	if request.Platform.IsZero() {
+		return request.Reference.Original()
+	}
+	return request.Reference.Original() + " " + request.Platform.String()
+}
+
+// ResolveAll resolves every distinct reference once with at most jobs concurrent calls to the resolver.
+// The results are keyed by the original string of the references.
+func ResolveAll(ctx context.Context, resolver Resolver, references []Reference, jobs int) map[string]Resolution {
+	requests := make([]ResolveRequest, 0, len(references))
+	for _, r := range references {
+		requests = append(requests, ResolveRequest{Reference: r})
+	}
+
+	return ResolveAllRequests(ctx, resolver, requests, jobs)
+}
+
+// ResolveAllRequests resolves every distinct request once with at most jobs concurrent calls to the resolver.
+// The results are keyed by the Key of the requests.
+func ResolveAllRequests(ctx context.Context, resolver Resolver, requests []ResolveRequest, jobs int) map[string]Resolution {
+	distinct := make([]ResolveRequest, 0, len(requests))
+	seen := make(map[string]bool)
+	for _, r := range requests {
+		if !seen[r.Key()] {
+			seen[r.Key()] = true
+			distinct = append(distinct, r)
+		}
+	}
+
+	if jobs < 1 {
+		jobs = 1
+	}
+	if jobs > len(distinct) {
+		jobs = len(distinct)
+	}
+
+	results := make(map[string]Resolution, len(distinct))
+	var resultsMutex sync.Mutex
+
+	work := make(chan ResolveRequest)
+	var workers sync.WaitGroup
+	for i := 0; i < jobs; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for r := range work {
+				refs, err := ResolveForPlatform(ctx, resolver, r.Reference, r.Platform)
+
+				resultsMutex.Lock()
+				results[r.Key()] = Resolution{References: refs, Err: err}
+				resultsMutex.Unlock()
+			}
+		}()
+	}
+
+	for _, r := range distinct {
+		work <- r
+	}
+	close(work)
+	workers.Wait()
+
+	return results
+}
@@ -0,0 +1,83 @@
+package dockref
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+type concurrencyResolver struct {
+	mutex   sync.Mutex
+	calls   map[string]int
+	running int
+	maximum int
+}
+
+func (c *concurrencyResolver) Resolve(ctx context.Context, reference Reference) ([]Reference, error) {
+	c.mutex.Lock()
+	c.calls[reference.Original()]++
+	c.running++
+	if c.running > c.maximum {
+		c.maximum = c.running
+	}
+	c.mutex.Unlock()
+
+	time.Sleep(10 * time.Millisecond)
+
+	c.mutex.Lock()
+	c.running--
+	c.mutex.Unlock()
+
+	if reference.Original() == "broken" {
+		return nil, errors.New("broken")
+	}
+	return []Reference{reference.WithTag("resolved")}, nil
+}
+
+func TestResolveAll_ResolvesDistinctReferencesOnce(t *testing.T) {
+	resolver := &concurrencyResolver{calls: make(map[string]int)}
+	refs := []Reference{
+		MustParse("nginx:1.15"), MustParse("alpine"), MustParse("nginx:1.15"),
+		MustParse("broken"), MustParse("debian"), MustParse("nginx:1.15"), MustParse("redis"),
+	}
+
+	results := ResolveAll(context.Background(), resolver, refs, 2)
+
+	assert.Equal(t, map[string]int{"nginx:1.15": 1, "alpine": 1, "broken": 1, "debian": 1, "redis": 1}, resolver.calls)
+	assert.Equal(t, 2, resolver.maximum)
+
+	assert.Len(t, results, 5)
+	assert.Nil(t, results["nginx:1.15"].Err)
+	assert.Equal(t, "resolved", results["nginx:1.15"].References[0].Tag())
+	assert.EqualError(t, results["broken"].Err, "broken")
+}
+
+func TestResolveAll_AtLeastOneJob(t *testing.T) {
+	resolver := &concurrencyResolver{calls: make(map[string]int)}
+
+	results := ResolveAll(context.Background(), resolver, []Reference{MustParse("nginx"), MustParse("alpine")}, 0)
+
+	assert.Len(t, results, 2)
+	assert.Equal(t, 1, resolver.maximum)
+	assert.Empty(t, ResolveAll(context.Background(), resolver, nil, 4))
+}
+
+func TestResolveAllRequests_SeparatesPlatforms(t *testing.T) {
+	delegate := &countingResolver{results: map[string][]Reference{
+		"nginx":             {MustParse("nginx:latest@" + ociTestDigestA)},
+		"nginx-linux-arm64": {MustParse("nginx:latest@" + ociTestDigestB)},
+	}}
+	nginx := MustParse("nginx")
+	arm64 := MustParsePlatform("linux/arm64")
+
+	results := ResolveAllRequests(context.Background(), delegate, []ResolveRequest{
+		{Reference: nginx}, {Reference: nginx, Platform: arm64}, {Reference: nginx, Platform: arm64},
+	}, 1)
+
+	assert.Equal(t, 2, delegate.calls)
+	assert.Equal(t, []string{"latest@" + ociTestDigestA}, tagsAndDigests(results["nginx"].References))
+	assert.Equal(t, []string{"latest@" + ociTestDigestB}, tagsAndDigests(results[ResolveRequest{Reference: nginx, Platform: arm64}.Key()].References))
+}
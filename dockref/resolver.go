@@ -3,18 +3,41 @@ package dockref
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"github.com/docker/cli/cli/command"
 	"github.com/docker/cli/cli/flags"
 	"github.com/docker/cli/opts"
 	"github.com/docker/docker/api/types"
+	"github.com/pkg/errors"
 	"github.com/spf13/pflag"
 	"io"
 	"io/ioutil"
 	"os"
+	"strings"
+	"sync"
 )
 
+// Resolver finds the tags and digests of the image a reference refers to.
+// Resolvers must stop contacting daemons or registries when the context is done.
 type Resolver interface {
-	Resolve(reference Reference) ([]Reference, error)
+	Resolve(ctx context.Context, reference Reference) ([]Reference, error)
+}
+
+// decorator is implemented by Resolvers that decorate another Resolver, like the cache.
+// They implement the optional interfaces like TagLister even when the decorated Resolver doesn't.
+type decorator interface {
+	decorated() Resolver
+}
+
+// undecorated returns the Resolver below all decorators, it tells which optional interfaces are supported
+func undecorated(resolver Resolver) Resolver {
+	for {
+		d, ok := resolver.(decorator)
+		if !ok {
+			return resolver
+		}
+		resolver = d.decorated()
+	}
 }
 
 type dockerDaemonResolver struct {
@@ -22,6 +45,10 @@ type dockerDaemonResolver struct {
 	NewCli       func(in io.ReadCloser, out *bytes.Buffer, errWriter *bytes.Buffer, isTrusted bool) dockerCliInterface
 
 	osGetenv func(key string) string
+
+	clientOnce sync.Once
+	client     dockerAPIClient
+	clientErr  error
 }
 
 var _ Resolver = (*dockerDaemonResolver)(nil)
@@ -35,10 +62,8 @@ func DockerDaemonResolverNew() Resolver {
 	return repo
 }
 
-func (repo dockerDaemonResolver) imageInspect(reference Reference) (types.ImageInspect, error) {
-	ctx := context.Background()
-
-	client, err := repo.newClient()
+func (repo *dockerDaemonResolver) imageInspect(ctx context.Context, reference Reference) (types.ImageInspect, error) {
+	client, err := repo.sharedClient()
 	if err != nil {
 		return types.ImageInspect{}, err
 	}
@@ -48,7 +73,15 @@ func (repo dockerDaemonResolver) imageInspect(reference Reference) (types.ImageI
 	return imageInspect, err
 }
 
-func (repo dockerDaemonResolver) newClient() (dockerAPIClient, error) {
+// sharedClient creates the client once and reuses it for all references
+func (repo *dockerDaemonResolver) sharedClient() (dockerAPIClient, error) {
+	repo.clientOnce.Do(func() {
+		repo.client, repo.clientErr = repo.newClient()
+	})
+	return repo.client, repo.clientErr
+}
+
+func (repo *dockerDaemonResolver) newClient() (dockerAPIClient, error) {
 
 	dockerTLSVerify := repo.osGetenv("DOCKER_TLS_VERIFY") != ""
 	dockerTLS := repo.osGetenv("DOCKER_TLS") != ""
@@ -107,18 +140,64 @@ func newCli(in io.ReadCloser, out *bytes.Buffer, errWriter *bytes.Buffer, isTrus
 	return &dockerCli{command.NewDockerCli(in, out, errWriter, isTrusted, nil)}
 }
 
-func (repo dockerDaemonResolver) Resolve(reference Reference) ([]Reference, error) {
-	imageInspect, err := repo.imageInspect(reference)
+func (repo *dockerDaemonResolver) Resolve(ctx context.Context, reference Reference) ([]Reference, error) {
+	imageInspect, err := repo.imageInspect(ctx, reference)
+
+	if err != nil {
+		return nil, err
+	}
 
+	digs, err := repoDigestsOf(reference, imageInspect.RepoDigests)
 	if err != nil {
 		return nil, err
 	}
 
-	digs := imageInspect.RepoDigests
-	tags := imageInspect.RepoTags
+	return imageReferences(reference, imageInspect.RepoTags, digs, imageInspect.ID), nil
+}
+
+// AmbiguousDigestError is returned when an image has several digests in the repository of a reference
+type AmbiguousDigestError struct {
+	Reference string
+	Digests   []string
+}
+
+func (e AmbiguousDigestError) Error() string {
+	return fmt.Sprintf("%s refers to an image with several digests (%s), pin it by digest to choose one", e.Reference, strings.Join(e.Digests, ", "))
+}
+
+// repoDigestsOf keeps the repo digests of the reference's repository, e.g. an image pulled from a mirror and from Docker Hub
+// has a digest for each of them. References without name, i.e. image ids, keep all repo digests.
+// Several distinct digests are ambiguous, repo digests of other repositories only are not found.
+// Images without any repo digest, e.g. built locally, have no digest.
+func repoDigestsOf(reference Reference, repoDigests []string) ([]string, error) {
+	digs := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, repoDigest := range repoDigests {
+		digRef, err := Parse(repoDigest)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid repo digest %s of %s", repoDigest, reference.Original())
+		}
+		if reference.Named() != nil && digRef.Name() != reference.Name() {
+			continue
+		}
+		if !seen[digRef.DigestString()] {
+			seen[digRef.DigestString()] = true
+			digs = append(digs, repoDigest)
+		}
+	}
+
+	if len(digs) > 1 {
+		return nil, AmbiguousDigestError{Reference: reference.Original(), Digests: digs}
+	}
+	if len(digs) == 0 && len(repoDigests) > 0 {
+		return nil, errors.Wrapf(NotFoundError{Reference: reference.Original()}, "the image has repo digests of other repositories only (%s)", strings.Join(repoDigests, ", "))
+	}
+	return digs, nil
+}
 
+// imageReferences assembles the tag and digest pairs of a single image, falling back to the image id when it has neither
+func imageReferences(reference Reference, tags []string, digs []string, id string) []Reference {
 	refs := make([]Reference, 0)
-	// TODO why can there more than one digest?
 	for _, tag := range tags {
 		tagRef := MustParse(tag)
 		r := reference.WithTag(tagRef.Tag())
@@ -134,9 +213,9 @@ func (repo dockerDaemonResolver) Resolve(reference Reference) ([]Reference, erro
 	}
 
 	if len(digs) == 0 && len(tags) == 0 {
-		r := MustParseAlgoDigest(imageInspect.ID)
+		r := MustParseAlgoDigest(id)
 		refs = append(refs, r)
 	}
 
-	return refs, nil
+	return refs
 }
@@ -105,7 +105,7 @@ func TestDockerDaemonRegistry_Resolve(t *testing.T) {
 		Return(types.ImageInspect{}, nil, errors.New("Error"))
 
 	t.Run("invalid", func(t *testing.T) {
-		references, e := repo.Resolve(MustParse("unknown:unknown"))
+		references, e := repo.Resolve(context.Background(), MustParse("unknown:unknown"))
 		assert.Error(t, e)
 		assert.Nil(t, references)
 	})
@@ -132,7 +132,7 @@ func TestDockerDaemonRegistry_Resolve(t *testing.T) {
 			ref, e := Parse(tst.name)
 			assert.Nil(t, e)
 
-			resolve, e := repo.Resolve(ref)
+			resolve, e := repo.Resolve(context.Background(), ref)
 			assert.Nil(t, e)
 
 			assert.NotNil(t, resolve)
@@ -150,7 +150,7 @@ func TestDockerDaemonRegistry_Resolve(t *testing.T) {
 		t.Run("Resolves digest "+dig, func(t *testing.T) {
 			ref := MustParse(dig)
 
-			resolve, e := repo.Resolve(ref)
+			resolve, e := repo.Resolve(context.Background(), ref)
 			assert.Nil(t, e)
 
 			assert.NotNil(t, resolve)
@@ -246,7 +246,7 @@ func TestDockerDaemonRegistry_Resolve_IT(t *testing.T) {
 	println("DOCKER_TLS_VERIFY " + os.Getenv("DOCKER_TLS_VERIFY"))
 
 	t.Run("invalid", func(t *testing.T) {
-		references, e := repo.Resolve(MustParse("unknown:unknown"))
+		references, e := repo.Resolve(context.Background(), MustParse("unknown:unknown"))
 		assert.Error(t, e)
 		assert.Nil(t, references)
 	})
@@ -266,7 +266,7 @@ func TestDockerDaemonRegistry_Resolve_IT(t *testing.T) {
 			ref, e := Parse(tst.name)
 			assert.Nil(t, e)
 
-			resolve, e := repo.Resolve(ref)
+			resolve, e := repo.Resolve(context.Background(), ref)
 			assert.Nil(t, e)
 
 			assert.NotNil(t, resolve)
@@ -283,7 +283,7 @@ func TestDockerDaemonRegistry_Resolve_IT(t *testing.T) {
 		t.Run("Resolves digest "+dig, func(t *testing.T) {
 			ref := MustParse(dig)
 
-			resolve, e := repo.Resolve(ref)
+			resolve, e := repo.Resolve(context.Background(), ref)
 			assert.Nil(t, e)
 
 			assert.NotNil(t, resolve)
@@ -315,7 +315,7 @@ func TestDockerDaemonRegistry_Resolve_Error_in_Initialize(t *testing.T) {
 	mockCli.On("Initialize", mock.Anything).Return(expected)
 	mockCli.On("Client").Return(mockClient)
 
-	references, e := repo.Resolve(MustParse("nginx"))
+	references, e := repo.Resolve(context.Background(), MustParse("nginx"))
 	assert.Error(t, e)
 	assert.Equal(t, expected, e)
 	assert.Empty(t, references)
@@ -338,7 +338,7 @@ func TestDockerDaemonResolver_Resolve_DigestOnly(t *testing.T) {
 			ID: "sha256:3247732819d6cd7af0c45a05b30d0b147f05a25ee2e83d7b9707ee25fcdd0f58",
 		}, nil, nil)
 
-	references, e := repo.Resolve(MustParse("3247732819d6cd7af0c45a05b30d0b147f05a25ee2e83d7b9707ee25fcdd0f58"))
+	references, e := repo.Resolve(context.Background(), MustParse("3247732819d6cd7af0c45a05b30d0b147f05a25ee2e83d7b9707ee25fcdd0f58"))
 	assert.Nil(t, e)
 	assert.NotEmpty(t, references)
 
@@ -366,7 +366,7 @@ func TestDockerDaemonResolver_Resolve_LocalOnly_but_tagged(t *testing.T) {
 			RepoTags: []string{"test:tagged"},
 		}, nil, nil)
 
-	references, e := repo.Resolve(MustParse("3247732819d6cd7af0c45a05b30d0b147f05a25ee2e83d7b9707ee25fcdd0f58"))
+	references, e := repo.Resolve(context.Background(), MustParse("3247732819d6cd7af0c45a05b30d0b147f05a25ee2e83d7b9707ee25fcdd0f58"))
 	assert.Nil(t, e)
 	assert.NotEmpty(t, references)
 
@@ -375,3 +375,73 @@ func TestDockerDaemonResolver_Resolve_LocalOnly_but_tagged(t *testing.T) {
 		assert.Equal(t, reference.Formatted(), "3247732819d6cd7af0c45a05b30d0b147f05a25ee2e83d7b9707ee25fcdd0f58")
 	}
 }
+
+func TestDockerDaemonResolver_Resolve_RepoDigestsOfOtherRepositories(t *testing.T) {
+	repo := dockerDaemonResolverNewTest()
+	mockCli := &mockDockerCli{}
+	repo.NewCli = func(in io.ReadCloser, out *bytes.Buffer, errWriter *bytes.Buffer, isTrusted bool) dockerCliInterface {
+		return mockCli
+	}
+
+	mockClient := &mockDockerAPIClient{}
+
+	mockCli.On("Initialize", mock.Anything).Return(nil)
+	mockCli.On("Client").Return(mockClient)
+
+	inspect := types.ImageInspect{
+		ID: "sha256:3247732819d6cd7af0c45a05b30d0b147f05a25ee2e83d7b9707ee25fcdd0f58",
+		RepoDigests: []string{
+			"nginx@sha256:31b8e90a349d1fce7621f5a5a08e4fc519b634f7d3feb09d53fac9b12aa4d991",
+			"mirror.corp/library/nginx@sha256:b73f527d86e3461fd652f62cf47e7b375196063bbbd503e853af5be16597cb2e",
+		},
+		RepoTags: []string{"nginx:1.15.6", "mirror.corp/library/nginx:1.15.6"},
+	}
+	mockClient.On("ImageInspectWithRaw", mock.Anything, "nginx:1.15.6").Return(inspect, nil, nil)
+	mockClient.On("ImageInspectWithRaw", mock.Anything, "mirror.corp/library/nginx:1.15.6").Return(inspect, nil, nil)
+	mockClient.On("ImageInspectWithRaw", mock.Anything, "3247732819d6cd7af0c45a05b30d0b147f05a25ee2e83d7b9707ee25fcdd0f58").Return(inspect, nil, nil)
+
+	for name, expected := range map[string]string{
+		"nginx:1.15.6":                     "sha256:31b8e90a349d1fce7621f5a5a08e4fc519b634f7d3feb09d53fac9b12aa4d991",
+		"mirror.corp/library/nginx:1.15.6": "sha256:b73f527d86e3461fd652f62cf47e7b375196063bbbd503e853af5be16597cb2e",
+	} {
+		references, e := repo.Resolve(context.Background(), MustParse(name))
+		assert.Nil(t, e, name)
+		for _, reference := range references {
+			assert.Equal(t, expected, reference.DigestString(), name)
+		}
+	}
+
+	_, e := repo.Resolve(context.Background(), MustParse("3247732819d6cd7af0c45a05b30d0b147f05a25ee2e83d7b9707ee25fcdd0f58"))
+	assert.IsType(t, AmbiguousDigestError{}, e)
+
+	// the image was tagged locally, but never pulled from or pushed to this repository
+	mockClient.On("ImageInspectWithRaw", mock.Anything, "quay.io/nginx:1.15.6").Return(inspect, nil, nil)
+	_, e = repo.Resolve(context.Background(), MustParse("quay.io/nginx:1.15.6"))
+	assert.True(t, IsNotFound(e))
+}
+
+func TestRepoDigestsOf(t *testing.T) {
+	const (
+		digestA = "sha256:31b8e90a349d1fce7621f5a5a08e4fc519b634f7d3feb09d53fac9b12aa4d991"
+		digestB = "sha256:b73f527d86e3461fd652f62cf47e7b375196063bbbd503e853af5be16597cb2e"
+	)
+
+	digs, err := repoDigestsOf(MustParse("nginx:1.15"), []string{"nginx@" + digestA, "docker.io/library/nginx@" + digestA})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"nginx@" + digestA}, digs)
+
+	_, err = repoDigestsOf(MustParse("nginx:1.15"), []string{"mirror.corp/library/nginx@" + digestA})
+	assert.True(t, IsNotFound(err))
+	assert.Contains(t, err.Error(), "mirror.corp/library/nginx@"+digestA)
+
+	digs, err = repoDigestsOf(MustParse("nginx:1.15"), []string{})
+	assert.Nil(t, err, "locally built images have no repo digest")
+	assert.Empty(t, digs)
+
+	_, err = repoDigestsOf(MustParse("nginx:1.15"), []string{"nginx@" + digestA, "nginx@" + digestB})
+	assert.Equal(t, AmbiguousDigestError{Reference: "nginx:1.15", Digests: []string{"nginx@" + digestA, "nginx@" + digestB}}, err)
+	assert.Contains(t, err.Error(), "several digests")
+
+	_, err = repoDigestsOf(MustParse("nginx:1.15"), []string{"nginx@invalid"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,140 @@
+package dockref
+
+import (
+	"github.com/blang/semver"
+	"github.com/pkg/errors"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	tagSchemeVersionGroup = "version"
+	tagSchemeVariantGroup = "variant"
+	tagSchemeBuildGroup   = "build"
+)
+
+// TagScheme splits the tags of a repository into version and variant
+type TagScheme struct {
+	Name    string
+	pattern *regexp.Regexp
+}
+
+// DefaultTagScheme splits tags like 1.15.6-alpine at the first hyphen into a SemVer and a variant
+var DefaultTagScheme = TagScheme{Name: "semver"}
+
+// TagSchemeNew creates a scheme from a regular expression with the named group version and the optional groups variant and build,
+// e.g. ^(?P<version>[0-9.]+)_(?P<build>[0-9]+)-(?P<variant>.+)$ for 17.0.8_7-jdk-jammy.
+// Builds order equal versions, tags that don't match have no version.
+func TagSchemeNew(name string, pattern string) (TagScheme, error) {
+	exp, err := regexp.Compile(pattern)
+	if err != nil {
+		return TagScheme{}, errors.Wrapf(err, "invalid pattern of tag scheme %s", name)
+	}
+
+	hasVersion := false
+	for _, group := range exp.SubexpNames() {
+		hasVersion = hasVersion || group == tagSchemeVersionGroup
+	}
+	if !hasVersion {
+		return TagScheme{}, errors.Errorf("the pattern of tag scheme %s has no group named version, e.g. (?P<version>[0-9.]+)", name)
+	}
+
+	return TagScheme{Name: name, pattern: exp}, nil
+}
+
+// split returns the version, with the build as SemVer build metadata, and the variant of a tag.
+// Tags without a version are all variant, e.g. latest.
+func (scheme TagScheme) split(tag string) (version string, variant string) {
+	if scheme.pattern == nil {
+		return splitVersionAndVariant(tag)
+	}
+
+	match := scheme.pattern.FindStringSubmatch(tag)
+	if match == nil {
+		return "", tag
+	}
+
+	var build string
+	for i, group := range scheme.pattern.SubexpNames() {
+		switch group {
+		case tagSchemeVersionGroup:
+			version = match[i]
+		case tagSchemeVariantGroup:
+			variant = match[i]
+		case tagSchemeBuildGroup:
+			build = match[i]
+		}
+	}
+
+	if build != "" {
+		version += "+" + build
+	}
+
+	if _, err := parseVersion(version); version == "" || err != nil {
+		return "", tag
+	}
+	return version, variant
+}
+
+// parseVeryTolerant parses the variant as pre-release, a tag with variant is older than the same version without
+func (scheme TagScheme) parseVeryTolerant(tag string) (semver.Version, error) {
+	version, variant := scheme.split(tag)
+
+	parsed, e := parseVersion(version)
+	if e != nil || variant == "" {
+		return parsed, e
+	}
+
+	core := semver.Version{Major: parsed.Major, Minor: parsed.Minor, Patch: parsed.Patch}
+	withVariant, e := semver.ParseTolerant(core.String() + "-" + variant)
+	withVariant.Build = parsed.Build
+	return withVariant, e
+}
+
+// isDateStamped reports tags whose version is a date, e.g. bookworm-20231009
+func (scheme TagScheme) isDateStamped(tag string) bool {
+	version, _ := scheme.split(tag)
+	return isDateStamp(version)
+}
+
+// compareVersions compares like SemVer and equal versions by build, e.g. 17.0.8+7 is older than 17.0.8+10
+func compareVersions(a semver.Version, b semver.Version) int {
+	if c := a.Compare(b); c != 0 {
+		return c
+	}
+
+	aBuild, bBuild := strings.Join(a.Build, "."), strings.Join(b.Build, ".")
+	aNumber, aErr := strconv.ParseUint(aBuild, 10, 64)
+	bNumber, bErr := strconv.ParseUint(bBuild, 10, 64)
+	if aErr == nil && bErr == nil {
+		switch {
+		case aNumber < bNumber:
+			return -1
+		case aNumber > bNumber:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(aBuild, bBuild)
+}
+
+// TagSchemeRule applies a scheme to the references its Matcher selects
+type TagSchemeRule struct {
+	// Matcher selects the references of this rule, nil matches all references
+	Matcher ReferenceMatcher
+	Scheme  TagScheme
+}
+
+// TagSchemes use the scheme of the first matching rule, references without matching rule use the DefaultTagScheme
+type TagSchemes []TagSchemeRule
+
+// For returns the scheme of the reference's repository
+func (schemes TagSchemes) For(reference Reference) TagScheme {
+	for _, rule := range schemes {
+		if rule.Matcher == nil || rule.Matcher.Matches(reference) {
+			return rule.Scheme
+		}
+	}
+	return DefaultTagScheme
+}
@@ -0,0 +1,104 @@
+package dockref
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+const temurinPattern = `^(?P<version>[0-9]+(\.[0-9]+)*)(_(?P<build>[0-9]+))?-(?P<variant>.+)$`
+
+func temurinScheme(t *testing.T) TagScheme {
+	scheme, err := TagSchemeNew("temurin", temurinPattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return scheme
+}
+
+func TestTagSchemeNew_Invalid(t *testing.T) {
+	_, err := TagSchemeNew("broken", `^(?P<version>[0-9.]+`)
+	assert.Error(t, err)
+
+	_, err = TagSchemeNew("unnamed", `^([0-9.]+)-(?P<variant>.+)$`)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "no group named version")
+	}
+}
+
+func TestTagScheme_Split(t *testing.T) {
+	scheme := temurinScheme(t)
+
+	for tag, expected := range map[string][2]string{
+		"17.0.8_7-jdk-jammy": {"17.0.8+7", "jdk-jammy"},
+		"17-jdk-jammy":       {"17", "jdk-jammy"},
+		"latest":             {"", "latest"},
+		"jdk-jammy":          {"", "jdk-jammy"},
+	} {
+		version, variant := scheme.split(tag)
+		assert.Equal(t, expected, [2]string{version, variant}, tag)
+	}
+}
+
+func TestTagScheme_MostPreciseTag(t *testing.T) {
+	refs := []Reference{
+		MustParse("eclipse-temurin:17-jdk-jammy"),
+		MustParse("eclipse-temurin:17.0.8_7-jdk-jammy"),
+		MustParse("eclipse-temurin:17.0.8-jdk-jammy"),
+		MustParse("eclipse-temurin:latest"),
+	}
+
+	mostPrecise, err := temurinScheme(t).MostPreciseTag(refs, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "17.0.8_7-jdk-jammy", mostPrecise.Tag())
+}
+
+func TestTagScheme_FindRelevantTagsForReference(t *testing.T) {
+	refs := []Reference{
+		MustParse("eclipse-temurin:17.0.8_7-jdk-jammy"),
+		MustParse("eclipse-temurin:17.0.8_7-jre-jammy"),
+		MustParse("eclipse-temurin:17.0.8_7-jdk-focal"),
+	}
+
+	relevant, err := temurinScheme(t).FindRelevantTagsForReference(MustParse("eclipse-temurin:17-jdk-jammy"), refs, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, refs[:1], relevant)
+
+	relevant, err = FindRelevantTagsForReference(MustParse("eclipse-temurin:17-jdk-jammy"), refs, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []Reference{}, relevant)
+}
+
+func TestTagScheme_NewerTagsAndUpdateCandidates(t *testing.T) {
+	scheme := temurinScheme(t)
+	tags := []string{"17.0.8_7-jdk-jammy", "17.0.8_10-jdk-jammy", "17.0.9_9-jdk-jammy", "17.0.9_9-jre-jammy", "21.0.1_12-jdk-jammy"}
+
+	assert.Equal(t, []string{"17.0.8_10-jdk-jammy", "17.0.9_9-jdk-jammy", "21.0.1_12-jdk-jammy"},
+		scheme.NewerTags(MustParse("eclipse-temurin:17.0.8_7-jdk-jammy"), tags, UpdatePolicy{}))
+	assert.Equal(t, []string{"17.0.9_9-jdk-jammy", "21.0.1_12-jdk-jammy"},
+		scheme.NewerTags(MustParse("eclipse-temurin:17.0.8-jdk-jammy"), tags, UpdatePolicy{}))
+	assert.Equal(t, []string{"17.0.9_9-jdk-jammy", "17.0.8_10-jdk-jammy"},
+		scheme.UpdateCandidates(MustParse("eclipse-temurin:17.0.8_7-jdk-jammy"), tags, UpdateMinor, UpdatePolicy{}))
+}
+
+func TestTagSchemes_For(t *testing.T) {
+	scheme := temurinScheme(t)
+	schemes := TagSchemes{{Matcher: tagMatcher("17-jdk-jammy"), Scheme: scheme}}
+
+	assert.Equal(t, scheme, schemes.For(MustParse("eclipse-temurin:17-jdk-jammy")))
+	assert.Equal(t, DefaultTagScheme, schemes.For(MustParse("nginx:1.15")))
+}
+
+func TestRegistryResolver_MorePreciseTagOfScheme(t *testing.T) {
+	registry := testRegistryNew()
+	defer registry.Close()
+
+	dig := registry.push("library/eclipse-temurin", `{"temurin": "17.0.8_7"}`, "17-jdk-jammy", "17.0.8_7-jdk-jammy", "17.0.8_7-jre-jammy")
+
+	resolver := registry.resolver()
+	resolver.options.TagSchemes = TagSchemes{{Scheme: temurinScheme(t)}}
+
+	refs, err := resolver.Resolve(context.Background(), MustParse(registry.domain()+"/library/eclipse-temurin:17-jdk-jammy"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"17-jdk-jammy@" + dig, "17.0.8_7-jdk-jammy@" + dig}, tagsAndDigests(refs))
+}
@@ -0,0 +1,167 @@
+package dockref
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"strings"
+)
+
+// TagRequest is the input of a TagStrategy
+type TagRequest struct {
+	// Original is the reference that was resolved
+	Original Reference
+	// References are the resolved references of Original, i.e. its image with the known tags
+	References []Reference
+	// Scheme splits the tags of Original's repository
+	Scheme TagScheme
+	// ResolveTag resolves another tag of Original's repository, e.g. an alias like stable, nil when other tags are unknown
+	ResolveTag func(ctx context.Context, tag string) ([]Reference, error)
+	Log        *logrus.Logger
+}
+
+// TagStrategy chooses the reference to pin among the resolved references of a reference
+type TagStrategy func(ctx context.Context, request TagRequest) (Reference, error)
+
+// MostPreciseTagStrategy chooses the highest version, e.g. 1.15.6 for 1.15, see TagScheme.MostPreciseTag
+func MostPreciseTagStrategy(ctx context.Context, request TagRequest) (Reference, error) {
+	return request.Scheme.MostPreciseTag(request.References, request.Log)
+}
+
+// LeastPreciseTagStrategy chooses the version tag with the fewest components that is at least as precise as the original's tag,
+// e.g. keeps 1.15 rather than 1.15.6. Without version tags it chooses like MostPreciseTagStrategy.
+func LeastPreciseTagStrategy(ctx context.Context, request TagRequest) (Reference, error) {
+	scheme := request.Scheme
+	minPrecision := 0
+	if version, _ := scheme.split(request.Original.Tag()); version != "" {
+		minPrecision = versionPrecision(version)
+	}
+
+	var best Reference
+	var bestPrecision int
+	for _, r := range request.References {
+		if r == nil {
+			return nil, errors.New("refs contains nil element")
+		}
+
+		version, _ := scheme.split(r.Tag())
+		if version == "" || versionPrecision(version) < minPrecision {
+			continue
+		}
+
+		precision := versionPrecision(version)
+		if best == nil || precision < bestPrecision || precision == bestPrecision && scheme.newerTag(r.Tag(), best.Tag()) {
+			best = r
+			bestPrecision = precision
+		}
+	}
+
+	if best != nil {
+		return best, nil
+	}
+	return MostPreciseTagStrategy(ctx, request)
+}
+
+// KeepTagStrategy keeps the tag of the original and only adds the digest, references without tag stay without tag
+func KeepTagStrategy(ctx context.Context, request TagRequest) (Reference, error) {
+	for _, r := range request.References {
+		if r == nil {
+			return nil, errors.New("refs contains nil element")
+		}
+		if r.Tag() == request.Original.Tag() {
+			return r, nil
+		}
+	}
+
+	if len(request.References) == 0 {
+		return nil, errors.Errorf("%s resolved to no references", request.Original.Original())
+	}
+	return request.References[0].WithTag(request.Original.Tag()), nil
+}
+
+// PreferredTagStrategyNew chooses the first of the tags, e.g. stable or lts, that refers to the same image,
+// other references are chosen by the fallback
+func PreferredTagStrategyNew(tags []string, fallback TagStrategy) TagStrategy {
+	return func(ctx context.Context, request TagRequest) (Reference, error) {
+		for _, tag := range tags {
+			preferred, err := preferredTag(ctx, request, tag)
+			if err != nil {
+				return nil, err
+			}
+			if preferred != nil {
+				return preferred, nil
+			}
+		}
+
+		return fallback(ctx, request)
+	}
+}
+
+// preferredTag returns the reference with the tag when it refers to the same image as the request's references
+func preferredTag(ctx context.Context, request TagRequest, tag string) (Reference, error) {
+	digests := make(map[string]bool)
+	for _, r := range request.References {
+		if r == nil {
+			return nil, errors.New("refs contains nil element")
+		}
+		if r.Tag() == tag {
+			return r, nil
+		}
+		digests[r.DigestString()] = true
+	}
+
+	if request.ResolveTag == nil {
+		return nil, nil
+	}
+
+	refs, err := request.ResolveTag(ctx, tag)
+	if IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, r := range refs {
+		if r.Tag() == tag && digests[r.DigestString()] {
+			return r, nil
+		}
+	}
+	return nil, nil
+}
+
+// newerTag reports whether the version of tag a is newer than the version of tag b
+func (scheme TagScheme) newerTag(a string, b string) bool {
+	aVersion, aErr := scheme.parseVeryTolerant(a)
+	bVersion, bErr := scheme.parseVeryTolerant(b)
+	return aErr == nil && bErr == nil && compareVersions(aVersion, bVersion) > 0
+}
+
+const preferredTagStrategyPrefix = "prefer:"
+
+// ParseTagStrategy parses keep, most-precise, least-precise or prefer:<tag>[,<tag>...],
+// the preferred tags fall back to most-precise
+func ParseTagStrategy(s string) (TagStrategy, error) {
+	switch s {
+	case "keep":
+		return KeepTagStrategy, nil
+	case "most-precise":
+		return MostPreciseTagStrategy, nil
+	case "least-precise":
+		return LeastPreciseTagStrategy, nil
+	}
+
+	if strings.HasPrefix(s, preferredTagStrategyPrefix) {
+		tags := make([]string, 0)
+		for _, tag := range strings.Split(strings.TrimPrefix(s, preferredTagStrategyPrefix), ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+		if len(tags) > 0 {
+			return PreferredTagStrategyNew(tags, MostPreciseTagStrategy), nil
+		}
+	}
+
+	return nil, errors.Errorf("invalid tag strategy '%s', expected keep, most-precise, least-precise or prefer:<tag>[,<tag>...]", s)
+}
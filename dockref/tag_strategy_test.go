@@ -0,0 +1,101 @@
+package dockref
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+const strategyTestDigest = "sha256:31b8e90a349d1fce7621f5a5a08e4fc519b634f7d3feb09d53fac9b12aa4d991"
+
+func strategyTestRefs(tags ...string) []Reference {
+	refs := make([]Reference, 0)
+	for _, tag := range tags {
+		refs = append(refs, MustParse("nginx:"+tag+"@"+strategyTestDigest))
+	}
+	return refs
+}
+
+func chooseTag(t *testing.T, strategy TagStrategy, original string, refs []Reference) string {
+	chosen, err := strategy(context.Background(), TagRequest{Original: MustParse(original), References: refs, Scheme: DefaultTagScheme})
+	assert.Nil(t, err)
+	if chosen == nil {
+		return ""
+	}
+	return chosen.Tag()
+}
+
+func TestTagStrategies(t *testing.T) {
+	refs := strategyTestRefs("latest", "1", "1.15", "1.15.6", "mainline")
+
+	for _, c := range []struct {
+		strategy TagStrategy
+		original string
+		expected string
+	}{
+		{MostPreciseTagStrategy, "nginx:1.15", "1.15.6"},
+		{LeastPreciseTagStrategy, "nginx:1.15", "1.15"},
+		{LeastPreciseTagStrategy, "nginx:1.15.6", "1.15.6"},
+		{LeastPreciseTagStrategy, "nginx:latest", "1"},
+		{KeepTagStrategy, "nginx:mainline", "mainline"},
+		{KeepTagStrategy, "nginx", ""},
+		{PreferredTagStrategyNew([]string{"stable", "mainline"}, MostPreciseTagStrategy), "nginx:1.15", "mainline"},
+		{PreferredTagStrategyNew([]string{"stable"}, MostPreciseTagStrategy), "nginx:1.15", "1.15.6"},
+	} {
+		assert.Equal(t, c.expected, chooseTag(t, c.strategy, c.original, refs), c.original)
+	}
+
+	assert.Equal(t, "mainline", chooseTag(t, LeastPreciseTagStrategy, "nginx:mainline", strategyTestRefs("latest", "mainline")))
+}
+
+func TestPreferredTagStrategy_ResolvesTags(t *testing.T) {
+	strategy := PreferredTagStrategyNew([]string{"lts", "stable"}, MostPreciseTagStrategy)
+
+	resolved := make([]string, 0)
+	chosen, err := strategy(context.Background(), TagRequest{
+		Original:   MustParse("nginx:1.15"),
+		References: strategyTestRefs("1.15", "1.15.6"),
+		Scheme:     DefaultTagScheme,
+		ResolveTag: func(ctx context.Context, tag string) ([]Reference, error) {
+			resolved = append(resolved, tag)
+			if tag == "lts" {
+				return nil, NotFoundError{Reference: "nginx:lts"}
+			}
+			return []Reference{MustParse("nginx:stable@" + strategyTestDigest)}, nil
+		},
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "stable", chosen.Tag())
+	assert.Equal(t, strategyTestDigest, chosen.DigestString())
+	assert.Equal(t, []string{"lts", "stable"}, resolved)
+}
+
+func TestPreferredTagStrategy_IgnoresOtherImages(t *testing.T) {
+	strategy := PreferredTagStrategyNew([]string{"stable"}, MostPreciseTagStrategy)
+
+	chosen, err := strategy(context.Background(), TagRequest{
+		Original:   MustParse("nginx:1.15"),
+		References: strategyTestRefs("1.15", "1.15.6"),
+		Scheme:     DefaultTagScheme,
+		ResolveTag: func(ctx context.Context, tag string) ([]Reference, error) {
+			return []Reference{MustParse("nginx:stable@sha256:2c4269d573d9fc6e9e95d5e8f3de2dd0b07c19912551f25e848415b5dd783acf")}, nil
+		},
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "1.15.6", chosen.Tag())
+}
+
+func TestParseTagStrategy(t *testing.T) {
+	for _, s := range []string{"keep", "most-precise", "least-precise", "prefer:stable", "prefer:stable, lts"} {
+		strategy, err := ParseTagStrategy(s)
+		assert.Nil(t, err, s)
+		assert.NotNil(t, strategy, s)
+	}
+
+	for _, s := range []string{"", "newest", "prefer:", "prefer: , "} {
+		_, err := ParseTagStrategy(s)
+		assert.Error(t, err, s)
+	}
+}
@@ -0,0 +1,326 @@
+package dockref
+
+import (
+	"context"
+	"github.com/blang/semver"
+	"github.com/pkg/errors"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// TagLister is implemented by Resolvers that can list the tags of the repository a reference belongs to
+type TagLister interface {
+	ListTags(ctx context.Context, reference Reference) ([]string, error)
+}
+
+// CanListTags reports whether the resolver can list tags, decorators like the cache can when the decorated resolver can
+func CanListTags(resolver Resolver) bool {
+	_, ok := undecorated(resolver).(TagLister)
+	return ok
+}
+
+// ListTags lists the tags of the reference's repository, resolvers that cannot list tags fail
+func ListTags(ctx context.Context, resolver Resolver, reference Reference) ([]string, error) {
+	lister, ok := resolver.(TagLister)
+	if !ok || !CanListTags(resolver) {
+		return nil, errors.Errorf("cannot list the tags of %s, the resolver does not support listing tags", reference.Original())
+	}
+
+	return lister.ListTags(ctx, reference)
+}
+
+// NewerTags returns the tags of the same variant with a newer version than the tag of the reference that the policy allows.
+// Versions are compared with the precision of the reference's tag, e.g. 1.16 is newer than 1.15, but 1.15.7 is not.
+// Versioned variants, e.g. alpine3.18, are newer with a newer variant version allowed by the policy's VariantLevel.
+// Date stamped tags, e.g. bookworm-20231009, are only compared with date stamped tags.
+// References without a version in their tag, e.g. latest, have no newer tags.
+func NewerTags(reference Reference, tags []string, policy UpdatePolicy) []string {
+	return DefaultTagScheme.NewerTags(reference, tags, policy)
+}
+
+// NewerTags is the package function NewerTags for tags of this scheme
+func (scheme TagScheme) NewerTags(reference Reference, tags []string, policy UpdatePolicy) []string {
+	newer := make([]string, 0)
+
+	current, ok := scheme.parseTaggedVersion(reference.Tag())
+	if !ok {
+		return newer
+	}
+
+	allows := policy.compile()
+	variantLevel := policy.variantLevel()
+	for _, tag := range tags {
+		candidate, ok := scheme.parseTaggedVersion(tag)
+		if !ok || !current.sameLine(candidate) || !current.allowsVariantOf(candidate, variantLevel) || !allows(candidate) {
+			continue
+		}
+
+		c := compareVersions(candidate.truncated(current.precision), current.version)
+		if c > 0 || c == 0 && current.compareVariant(candidate) > 0 {
+			newer = append(newer, tag)
+		}
+	}
+
+	return newer
+}
+
+// UpdateLevel limits the versions an update may choose, e.g. UpdateMinor keeps the major version
+type UpdateLevel int
+
+const (
+	UpdatePatch UpdateLevel = iota
+	UpdateMinor
+	UpdateMajor
+)
+
+var updateLevelNames = []string{"patch", "minor", "major"}
+
+// ParseUpdateLevel parses patch, minor or major
+func ParseUpdateLevel(s string) (UpdateLevel, error) {
+	for i, name := range updateLevelNames {
+		if name == s {
+			return UpdateLevel(i), nil
+		}
+	}
+	return UpdatePatch, errors.Errorf("invalid update level '%s', expected patch, minor or major", s)
+}
+
+// UpdateNone keeps the version, e.g. the 3.18 of the variant alpine3.18
+const UpdateNone UpdateLevel = -1
+
+// ParseVariantLevel parses none, patch, minor or major
+func ParseVariantLevel(s string) (UpdateLevel, error) {
+	if s == "none" {
+		return UpdateNone, nil
+	}
+	level, err := ParseUpdateLevel(s)
+	if err != nil {
+		return UpdatePatch, errors.Errorf("invalid variant level '%s', expected none, patch, minor or major", s)
+	}
+	return level, nil
+}
+
+func (level UpdateLevel) String() string {
+	if level == UpdateNone {
+		return "none"
+	}
+	return updateLevelNames[level]
+}
+
+func (level UpdateLevel) allows(current semver.Version, candidate semver.Version) bool {
+	switch level {
+	case UpdateNone:
+		return compareVersions(candidate, current) == 0
+	case UpdatePatch:
+		return candidate.Major == current.Major && candidate.Minor == current.Minor
+	case UpdateMinor:
+		return candidate.Major == current.Major
+	}
+	return true
+}
+
+// UpdateCandidates returns the tags of the same variant with a version allowed by the level and the policy, newest first.
+// Candidates have a newer version than the reference's tag or the same version with more precision, e.g. 1.16.0 for 1.16.
+// Of equal versions the newest variant version, e.g. alpine3.18, and then the most precise tag comes first. The level does not restrict date stamped tags, e.g. bookworm-20231009. References without a version in their tag, e.g. latest, have no candidates.
+func UpdateCandidates(reference Reference, tags []string, level UpdateLevel, policy UpdatePolicy) []string {
+	return DefaultTagScheme.UpdateCandidates(reference, tags, level, policy)
+}
+
+// UpdateCandidates is the package function UpdateCandidates for tags of this scheme
+func (scheme TagScheme) UpdateCandidates(reference Reference, tags []string, level UpdateLevel, policy UpdatePolicy) []string {
+	current, ok := scheme.parseTaggedVersion(reference.Tag())
+	if !ok {
+		return []string{}
+	}
+
+	allows := policy.compile()
+	variantLevel := policy.variantLevel()
+	candidates := make([]taggedVersion, 0)
+	for _, tag := range tags {
+		candidate, ok := scheme.parseTaggedVersion(tag)
+		if !ok || !current.sameLine(candidate) || !current.date && !level.allows(current.version, candidate.version) ||
+			!current.allowsVariantOf(candidate, variantLevel) || !allows(candidate) {
+			continue
+		}
+
+		c := compareVersions(candidate.version, current.version)
+		if c == 0 {
+			c = current.compareVariant(candidate)
+		}
+		if c > 0 || c == 0 && candidate.precision > current.precision {
+			candidates = append(candidates, candidate)
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		c := compareVersions(a.version, b.version)
+		if c == 0 {
+			c = compareVersions(a.variantVersion, b.variantVersion)
+		}
+		return c > 0 || c == 0 && a.precision > b.precision
+	})
+
+	newest := make([]string, 0, len(candidates))
+	for _, candidate := range candidates {
+		newest = append(newest, candidate.tag)
+	}
+	return newest
+}
+
+// taggedVersion is a tag like 1.16.0-rc1-alpine split into its version including the pre-release, e.g. 1.16.0-rc.1, and its variant
+type taggedVersion struct {
+	tag        string
+	version    semver.Version
+	precision  int
+	variant    string
+	prerelease bool
+	// date is set for date stamps like 20231009 instead of a version
+	date bool
+	// variantName is the variant without its version, e.g. alpine for alpine3.18
+	variantName string
+	// variantVersion is the version of a versioned variant, e.g. 3.18 for alpine3.18, its precision is 0 for other variants
+	variantVersion   semver.Version
+	variantPrecision int
+}
+
+// sameLine reports whether the candidate is comparable, i.e. has the same variant apart from its version and is date stamped when the tag is
+func (tagged taggedVersion) sameLine(candidate taggedVersion) bool {
+	return candidate.variantName == tagged.variantName &&
+		(candidate.variantPrecision == 0) == (tagged.variantPrecision == 0) &&
+		candidate.date == tagged.date
+}
+
+// compareVariant compares the variant version of the candidate with the precision of the tag's variant version
+func (tagged taggedVersion) compareVariant(candidate taggedVersion) int {
+	if tagged.variantPrecision == 0 {
+		return 0
+	}
+	return compareVersions(truncateVersion(candidate.variantVersion, tagged.variantPrecision), tagged.variantVersion)
+}
+
+// allowsVariantOf reports whether the variant version of the candidate is allowed by the level and not older
+func (tagged taggedVersion) allowsVariantOf(candidate taggedVersion, level UpdateLevel) bool {
+	if tagged.variantPrecision == 0 {
+		return true
+	}
+	variantVersion := truncateVersion(candidate.variantVersion, tagged.variantPrecision)
+	return tagged.compareVariant(candidate) >= 0 && level.allows(tagged.variantVersion, variantVersion)
+}
+
+// variantVersionPattern matches a versioned part of a variant, e.g. alpine3.18
+var variantVersionPattern = regexp.MustCompile(`^([A-Za-z]+)([0-9]+(\.[0-9]+)*)$`)
+
+// splitVariantVersion returns the variant without its version and the version, e.g. jdk-alpine and 3.18 for jdk-alpine3.18.
+// The last versioned part of a variant is its version, variants without versioned part have no version.
+func splitVariantVersion(variant string) (name string, version string) {
+	parts := strings.Split(variant, "-")
+	for i := len(parts) - 1; i >= 0; i-- {
+		match := variantVersionPattern.FindStringSubmatch(parts[i])
+		if match == nil {
+			continue
+		}
+		if _, err := parseVersion(match[2]); err != nil {
+			continue
+		}
+		named := append(append(append([]string{}, parts[:i]...), match[1]), parts[i+1:]...)
+		return strings.Join(named, "-"), match[2]
+	}
+	return variant, ""
+}
+
+// prereleasePattern matches the first part of a variant that marks a pre-release, e.g. rc1 or beta.2
+var prereleasePattern = regexp.MustCompile(`^(?i)(alpha|beta|rc|pre|preview|dev|snapshot)\.?([0-9]*)$`)
+
+func (scheme TagScheme) parseTaggedVersion(tag string) (taggedVersion, bool) {
+	version, variant := scheme.split(tag)
+	if version == "" {
+		return taggedVersion{}, false
+	}
+
+	parsed, err := parseVersion(version)
+	if err != nil {
+		return taggedVersion{}, false
+	}
+
+	tagged := taggedVersion{
+		tag:       tag,
+		version:   parsed,
+		precision: versionPrecision(version),
+		variant:   variant,
+		date:      isDateStamp(version),
+	}
+
+	parts := strings.SplitN(variant, "-", 2)
+	if match := prereleasePattern.FindStringSubmatch(parts[0]); match != nil {
+		tagged.prerelease = true
+		tagged.version.Pre = []semver.PRVersion{{VersionStr: strings.ToLower(match[1])}}
+		if number, err := strconv.ParseUint(match[2], 10, 64); err == nil {
+			tagged.version.Pre = append(tagged.version.Pre, semver.PRVersion{VersionNum: number, IsNum: true})
+		}
+
+		tagged.variant = ""
+		if len(parts) == 2 {
+			tagged.variant = parts[1]
+		}
+	}
+
+	tagged.variantName = tagged.variant
+	if name, variantVersion := splitVariantVersion(tagged.variant); variantVersion != "" {
+		tagged.variantName = name
+		tagged.variantVersion, _ = parseVersion(variantVersion)
+		tagged.variantPrecision = versionPrecision(variantVersion)
+	}
+
+	return tagged, true
+}
+
+// truncated drops the components beyond the precision, the pre-release belongs to the last component and is only kept with it
+func (tagged taggedVersion) truncated(precision int) semver.Version {
+	if precision >= tagged.precision {
+		return tagged.version
+	}
+	return truncateVersion(tagged.version, precision)
+}
+
+// versionPrecision is the number of components of a version, e.g. 2 for 1.15, a build counts as fourth component
+func versionPrecision(version string) int {
+	if strings.Contains(version, "+") {
+		return 4
+	}
+
+	precision := strings.Count(version, ".") + 1
+	if precision > 3 {
+		return 3
+	}
+	return precision
+}
+
+func truncateVersion(version semver.Version, precision int) semver.Version {
+	truncated := semver.Version{Major: version.Major}
+	if precision > 1 {
+		truncated.Minor = version.Minor
+	}
+	if precision > 2 {
+		truncated.Patch = version.Patch
+	}
+	return truncated
+}
+
+// tagsOfImages lists the distinct tags of the images with the name of the reference
+func tagsOfImages(reference Reference, images []ociImage) []string {
+	tags := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, image := range images {
+		if image.tag == "" || seen[image.tag] {
+			continue
+		}
+		if image.name == "" || reference.Named() == nil || image.name == reference.Name() {
+			seen[image.tag] = true
+			tags = append(tags, image.tag)
+		}
+	}
+	return tags
+}
@@ -0,0 +1,162 @@
+package dockref
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type staticTagLister struct {
+	Resolver
+	tags []string
+}
+
+func (l staticTagLister) ListTags(ctx context.Context, reference Reference) ([]string, error) {
+	if l.tags == nil {
+		return nil, NotFoundError{Reference: reference.Original()}
+	}
+	return l.tags, nil
+}
+
+func TestNewerTags(t *testing.T) {
+	tags := []string{"latest", "1", "1.14", "1.15", "1.15.6", "1.15.7", "1.16", "1.16.0", "2", "1.16-alpine", "1.17-alpine-perl", "mainline"}
+
+	for tag, expected := range map[string][]string{
+		"1.15":        {"1.16", "1.16.0", "2"},
+		"1.15.6":      {"1.15.7", "1.16", "1.16.0", "2"},
+		"1":           {"2"},
+		"2":           {},
+		"v1.15":       {"1.16", "1.16.0", "2"},
+		"1.15-alpine": {"1.16-alpine"},
+		"latest":      {},
+		"mainline":    {},
+	} {
+		assert.Equal(t, expected, NewerTags(MustParse("nginx:"+tag), tags, UpdatePolicy{}), tag)
+	}
+
+	assert.Equal(t, []string{}, NewerTags(MustParse("nginx"), tags, UpdatePolicy{}))
+}
+
+func TestNewerTags_Prerelease(t *testing.T) {
+	tags := []string{"1.15", "1.16-rc1", "1.16.0-beta.2-alpine", "2.0.0-rc1", "2.0.0-rc2", "2.0.0"}
+
+	assert.Equal(t, []string{}, NewerTags(MustParse("nginx:1.15"), []string{"1.16-rc1", "2.0.0-rc1"}, UpdatePolicy{}))
+	assert.Equal(t, []string{"1.16-rc1", "2.0.0-rc1", "2.0.0-rc2", "2.0.0"}, NewerTags(MustParse("nginx:1.15"), tags, UpdatePolicy{Prerelease: true}))
+	assert.Equal(t, []string{"2.0.0-rc2", "2.0.0"}, NewerTags(MustParse("nginx:2.0.0-rc1"), tags, UpdatePolicy{Prerelease: true}))
+	assert.Equal(t, []string{"2.0.0"}, NewerTags(MustParse("nginx:2.0.0-rc1"), tags, UpdatePolicy{}))
+	assert.Equal(t, []string{"1.16.0-beta.2-alpine"}, NewerTags(MustParse("nginx:1.15-alpine"), tags, UpdatePolicy{Prerelease: true}))
+}
+
+func TestUpdateCandidates(t *testing.T) {
+	tags := []string{"latest", "1", "1.14", "1.15", "1.15.6", "1.15.7", "1.16", "1.16.0", "1.16.1", "2", "2.0", "2.0.0", "1.16-alpine", "1.17-alpine", "2.0-alpine-perl"}
+
+	for _, c := range []struct {
+		tag      string
+		level    UpdateLevel
+		expected string
+	}{
+		{"1.15.6", UpdatePatch, "1.15.7"},
+		{"1.15.6", UpdateMinor, "1.16.1"},
+		{"1.15.6", UpdateMajor, "2.0.0"},
+		{"1.15", UpdatePatch, "1.15.7"},
+		{"1", UpdateMinor, "1.16.1"},
+		{"1.15-alpine", UpdateMinor, "1.17-alpine"},
+		{"1.15-alpine", UpdatePatch, "1.15-alpine"},
+		{"2.0.0", UpdateMajor, "2.0.0"},
+		{"3", UpdateMajor, "3"},
+		{"latest", UpdateMajor, "latest"},
+		{"mainline", UpdateMajor, "mainline"},
+	} {
+		newest := c.tag
+		if candidates := UpdateCandidates(MustParse("nginx:"+c.tag), tags, c.level, UpdatePolicy{}); len(candidates) > 0 {
+			newest = candidates[0]
+		}
+		assert.Equal(t, c.expected, newest, c.tag+" "+c.level.String())
+	}
+
+	assert.Equal(t, []string{"1.16.1", "1.16.0", "1.16", "1.15.7"}, UpdateCandidates(MustParse("nginx:1.15.6"), tags, UpdateMinor, UpdatePolicy{}))
+}
+
+func TestNewerTags_CalVer(t *testing.T) {
+	tags := []string{"20.04", "22.04", "22.10", "23.04", "jammy", "jammy-20230816", "jammy-20231004", "focal-20231003", "20231009"}
+
+	assert.Equal(t, []string{"22.10", "23.04"}, NewerTags(MustParse("ubuntu:22.04"), tags, UpdatePolicy{}))
+	assert.Equal(t, []string{"jammy-20231004"}, NewerTags(MustParse("ubuntu:jammy-20230816"), tags, UpdatePolicy{}))
+	assert.Equal(t, []string{}, NewerTags(MustParse("ubuntu:jammy"), tags, UpdatePolicy{}))
+}
+
+func TestUpdateCandidates_DateStamps(t *testing.T) {
+	tags := []string{"12", "12.2", "bookworm", "bookworm-20230904", "bookworm-20231009", "bookworm-20231009-slim", "bullseye-20231009"}
+
+	assert.Equal(t, []string{"bookworm-20231009"}, UpdateCandidates(MustParse("debian:bookworm-20230904"), tags, UpdatePatch, UpdatePolicy{}))
+	assert.Equal(t, []string{"12.2"}, UpdateCandidates(MustParse("debian:12"), tags, UpdateMajor, UpdatePolicy{}))
+	assert.Equal(t, []string{"23.10", "23.04"}, UpdateCandidates(MustParse("ubuntu:22.04"), []string{"22.04", "23.04", "23.10"}, UpdateMajor, UpdatePolicy{}))
+	assert.Equal(t, []string{"22.10"}, UpdateCandidates(MustParse("ubuntu:22.04"), []string{"22.10", "23.04"}, UpdateMinor, UpdatePolicy{}))
+}
+
+func TestNewerTags_VersionedVariants(t *testing.T) {
+	tags := []string{"18-alpine", "18-alpine3.17", "18-alpine3.18", "18-alpine4.0", "20-alpine3.16", "20-alpine3.18", "18-bullseye"}
+
+	none := UpdateNone
+	assert.Equal(t, []string{"18-alpine3.18", "20-alpine3.18"}, NewerTags(MustParse("node:18-alpine3.17"), tags, UpdatePolicy{}))
+	assert.Equal(t, []string{}, NewerTags(MustParse("node:18-alpine3.17"), tags, UpdatePolicy{VariantLevel: &none}))
+	assert.Equal(t, []string{"20-alpine"}, NewerTags(MustParse("node:18-alpine"), []string{"20-alpine3.16", "20-alpine"}, UpdatePolicy{}))
+}
+
+func TestUpdateCandidates_VersionedVariants(t *testing.T) {
+	tags := []string{"18-alpine3.17", "18-alpine3.18", "18.18-alpine3.18", "18-alpine4.0", "20-alpine3.18", "1.21-jdk-alpine3.18"}
+	major := UpdateMajor
+	none := UpdateNone
+
+	assert.Equal(t, []string{"18.18-alpine3.18", "18-alpine3.18"}, UpdateCandidates(MustParse("node:18-alpine3.17"), tags, UpdateMinor, UpdatePolicy{}))
+	assert.Equal(t, []string{"18.18-alpine3.18", "18-alpine4.0", "18-alpine3.18"}, UpdateCandidates(MustParse("node:18-alpine3.17"), tags, UpdateMinor, UpdatePolicy{VariantLevel: &major}))
+	assert.Equal(t, []string{"20-alpine3.18", "18.18-alpine3.18"}, UpdateCandidates(MustParse("node:18-alpine3.18"), tags, UpdateMajor, UpdatePolicy{VariantLevel: &none}))
+}
+
+func TestSplitVariantVersion(t *testing.T) {
+	for variant, expected := range map[string][]string{
+		"alpine3.18":        {"alpine", "3.18"},
+		"jdk-alpine3.18":    {"jdk-alpine", "3.18"},
+		"jdk17-alpine":      {"jdk-alpine", "17"},
+		"windowsservercore": {"windowsservercore", ""},
+		"alpine":            {"alpine", ""},
+		"arm64v8":           {"arm64v8", ""},
+		"":                  {"", ""},
+		"python3.11-slim":   {"python-slim", "3.11"},
+	} {
+		name, version := splitVariantVersion(variant)
+		assert.Equal(t, expected, []string{name, version}, variant)
+	}
+}
+
+func TestParseVariantLevel(t *testing.T) {
+	level, err := ParseVariantLevel("none")
+	assert.Nil(t, err)
+	assert.Equal(t, UpdateNone, level)
+	assert.Equal(t, "none", level.String())
+
+	level, err = ParseVariantLevel("major")
+	assert.Nil(t, err)
+	assert.Equal(t, UpdateMajor, level)
+
+	_, err = ParseVariantLevel("build")
+	assert.Error(t, err)
+}
+
+func TestParseUpdateLevel(t *testing.T) {
+	for _, name := range []string{"patch", "minor", "major"} {
+		level, err := ParseUpdateLevel(name)
+		assert.Nil(t, err)
+		assert.Equal(t, name, level.String())
+	}
+
+	_, err := ParseUpdateLevel("build")
+	assert.Error(t, err)
+}
+
+func TestListTags_RequiresTagLister(t *testing.T) {
+	_, err := ListTags(context.Background(), resolvingTo("1.15"), MustParse("nginx:1.15"))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "does not support listing tags")
+	}
+}
@@ -1,17 +1,22 @@
 package dockreftst
 
 import (
+	"context"
 	"github.com/MeneDev/dockmoor/dockref"
 	"github.com/stretchr/testify/mock"
+	"time"
 )
 
 var _ dockref.Resolver = (*MockResolver)(nil)
+var _ dockref.PlatformResolver = (*MockResolver)(nil)
+var _ dockref.TagLister = (*MockResolver)(nil)
+var _ dockref.CreationTimeResolver = (*MockResolver)(nil)
 
 type MockResolver struct {
 	mock.Mock
 }
 
-func (m *MockResolver) Resolve(reference dockref.Reference) ([]dockref.Reference, error) {
+func (m *MockResolver) Resolve(ctx context.Context, reference dockref.Reference) ([]dockref.Reference, error) {
 	called := m.Called(reference)
 	i := called.Get(0)
 	refs := i.([]dockref.Reference)
@@ -23,6 +28,42 @@ func (m *MockResolver) OnResolve(reference interface{}) *mock.Call {
 	return m.On("Resolve", reference)
 }
 
+func (m *MockResolver) ResolvePlatform(ctx context.Context, reference dockref.Reference, platform dockref.Platform) ([]dockref.Reference, error) {
+	called := m.Called(reference, platform)
+	i := called.Get(0)
+	refs := i.([]dockref.Reference)
+	e := called.Error(1)
+	return refs, e
+}
+
+func (m *MockResolver) OnResolvePlatform(reference interface{}, platform interface{}) *mock.Call {
+	return m.On("ResolvePlatform", reference, platform)
+}
+
+func (m *MockResolver) ListTags(ctx context.Context, reference dockref.Reference) ([]string, error) {
+	called := m.Called(reference)
+	i := called.Get(0)
+	tags := i.([]string)
+	e := called.Error(1)
+	return tags, e
+}
+
+func (m *MockResolver) OnListTags(reference interface{}) *mock.Call {
+	return m.On("ListTags", reference)
+}
+
+func (m *MockResolver) CreationTime(ctx context.Context, reference dockref.Reference) (time.Time, error) {
+	called := m.Called(reference)
+	i := called.Get(0)
+	created := i.(time.Time)
+	e := called.Error(1)
+	return created, e
+}
+
+func (m *MockResolver) OnCreationTime(reference interface{}) *mock.Call {
+	return m.On("CreationTime", reference)
+}
+
 func MockResolverNew() *MockResolver {
 	return &MockResolver{}
 }